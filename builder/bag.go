@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	bagit "github.com/odia/juscaba/bagit"
+	shared "github.com/odia/juscaba/shared"
+	log "github.com/sirupsen/logrus"
+)
+
+// formatFecha formats the millisecond timestamps used by the api.
+func formatFecha(ms int) string {
+	if ms <= 0 {
+		return ""
+	}
+	return time.Unix(int64(ms/1000), 0).UTC().Format("2006-01-02")
+}
+
+func bagInfo(b *bagit.Bag, ficha *shared.Ficha) {
+	b.AddInfo("Source-Organization", "Poder Judicial de la Ciudad de Buenos Aires")
+	b.AddInfo("External-Description", ficha.Caratula)
+	b.AddInfo("External-Identifier", ficha.CUIJ)
+	b.AddInfo("Internal-Sender-Identifier", ficha.NumeroDeExpediente("/"))
+	b.AddInfo("Caratula", ficha.Caratula)
+	b.AddInfo("CUIJ", ficha.CUIJ)
+	b.AddInfo("Tipo-Expediente", ficha.TipoExpediente)
+	b.AddInfo("Organismo", ficha.Ubicacion.Organismo)
+	b.AddInfo("Dependencia", ficha.Ubicacion.Dependencia)
+	b.AddInfo("Organismo-Primera-Instancia", ficha.Radicaciones.OrganismoPrimeraInstancia)
+	b.AddInfo("Organismo-Segunda-Instancia", ficha.Radicaciones.OrganismoSegundaInstancia)
+	b.AddInfo("Fecha-Inicio", formatFecha(ficha.FechaInicio))
+	b.AddInfo("Ultimo-Movimiento", formatFecha(ficha.UltimoMovimiento))
+}
+
+func bag(argv []string) error {
+	var pdfsPath, jsonPath, out string
+	flags := newFlagSet("bag")
+	flags.StringVar(&pdfsPath, "pdfs", "", "pdfs path")
+	flags.StringVar(&jsonPath, "json", "", "expediente json path")
+	flags.StringVar(&out, "out", "", "bag destination, a directory or a .zip file")
+	flags.Parse(argv)
+
+	if jsonPath == "" || out == "" {
+		log.Error("bag needs -json and -out")
+		return errors.New("missing arguments")
+	}
+	exp, err := shared.ReadExpediente(jsonPath)
+	if err != nil {
+		return err
+	}
+	fm := &shared.FileManager{Directory: pdfsPath}
+	defer fm.Close()
+
+	b := bagit.New()
+	b.AddFile(path.Base(jsonPath), jsonPath)
+	missing := 0
+	for _, doc := range exp.Documentos() {
+		sf, err := fm.SavedFileForURL(doc.URL)
+		if err != nil {
+			missing++
+			continue
+		}
+		b.AddFile(path.Join("documentos", sf.DestinationFilename), fm.DestinationPath(sf))
+		base := strings.TrimSuffix(sf.DestinationFilename, path.Ext(sf.DestinationFilename))
+		if doc.Content != "" {
+			b.AddContent(path.Join("textos", base+".txt"), []byte(doc.Content))
+		}
+		for i, table := range doc.Tables {
+			name := fmt.Sprintf("%s.%d.csv", base, i+1)
+			b.AddContent(path.Join("tablas", name), table.CSV())
+		}
+	}
+	if exp.Ficha != nil {
+		bagInfo(b, exp.Ficha)
+	}
+	if missing > 0 {
+		b.AddInfo("Internal-Sender-Description", fmt.Sprintf("%d documentos no descargados", missing))
+	}
+
+	if strings.HasSuffix(out, ".zip") {
+		err = b.WriteZip(out)
+	} else {
+		err = b.WriteDir(out)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"out":   out,
+			"error": err.Error(),
+		}).Error("failed to write bag")
+		return err
+	}
+	log.WithFields(log.Fields{
+		"out":     out,
+		"missing": missing,
+	}).Info("wrote bag")
+	return nil
+}
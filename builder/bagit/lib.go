@@ -0,0 +1,238 @@
+package bagit
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const Version = "1.0"
+
+var ErrNotEmpty = errors.New("bag directory is not empty")
+
+type payloadFile struct {
+	name       string
+	sourcePath string
+	content    []byte
+}
+
+func (f *payloadFile) open() (io.ReadCloser, error) {
+	if f.sourcePath != "" {
+		return os.Open(f.sourcePath)
+	}
+	return io.NopCloser(bytes.NewReader(f.content)), nil
+}
+
+type infoField struct {
+	label string
+	value string
+}
+
+// Bag is a BagIt 1.0 bag (RFC 8493) built in memory and written as a
+// directory or a zip file.
+type Bag struct {
+	payload []*payloadFile
+	info    []infoField
+}
+
+func New() *Bag {
+	return &Bag{}
+}
+
+// AddFile adds a file on disk to the payload, under data/name.
+func (b *Bag) AddFile(name, sourcePath string) {
+	b.payload = append(b.payload, &payloadFile{name: name, sourcePath: sourcePath})
+}
+
+// AddContent adds content to the payload, under data/name.
+func (b *Bag) AddContent(name string, content []byte) {
+	b.payload = append(b.payload, &payloadFile{name: name, content: content})
+}
+
+// AddInfo adds a bag-info.txt field. Empty values are skipped.
+func (b *Bag) AddInfo(label, value string) {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		return
+	}
+	b.info = append(b.info, infoField{label: label, value: value})
+}
+
+// fileWriter creates the files of the bag, either on disk or in a zip.
+type fileWriter func(name string) (io.WriteCloser, error)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func writeFile(create fileWriter, name string, r io.Reader) (string, int64, error) {
+	w, err := create(name)
+	if err != nil {
+		return "", 0, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), r)
+	if err != nil {
+		w.Close()
+		return "", 0, err
+	}
+	err = w.Close()
+	if err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), n, nil
+}
+
+func manifestLines(hashes map[string]string) []byte {
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s  %s\n", hashes[name], name)
+	}
+	return buf.Bytes()
+}
+
+func (b *Bag) write(create fileWriter) error {
+	payloadHashes := map[string]string{}
+	var octets int64
+	for _, f := range b.payload {
+		name := path.Join("data", f.name)
+		if _, found := payloadHashes[name]; found {
+			continue
+		}
+		r, err := f.open()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"name":  f.name,
+				"error": err.Error(),
+			}).Error("failed to read payload file")
+			return err
+		}
+		hash, n, err := writeFile(create, name, r)
+		r.Close()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"name":  f.name,
+				"error": err.Error(),
+			}).Error("failed to write payload file")
+			return err
+		}
+		payloadHashes[name] = hash
+		octets += n
+	}
+
+	var info bytes.Buffer
+	for _, field := range b.info {
+		fmt.Fprintf(&info, "%s: %s\n", field.label, field.value)
+	}
+	fmt.Fprintf(&info, "Bagging-Date: %s\n", time.Now().Format("2006-01-02"))
+	fmt.Fprintf(&info, "Payload-Oxum: %d.%d\n", octets, len(payloadHashes))
+
+	tagHashes := map[string]string{}
+	for _, tag := range []struct {
+		name    string
+		content []byte
+	}{
+		{"bagit.txt", []byte(fmt.Sprintf("BagIt-Version: %s\nTag-File-Character-Encoding: UTF-8\n", Version))},
+		{"bag-info.txt", info.Bytes()},
+		{"manifest-sha256.txt", manifestLines(payloadHashes)},
+	} {
+		hash, _, err := writeFile(create, tag.name, bytes.NewReader(tag.content))
+		if err != nil {
+			return err
+		}
+		tagHashes[tag.name] = hash
+	}
+	_, _, err := writeFile(create, "tagmanifest-sha256.txt", bytes.NewReader(manifestLines(tagHashes)))
+	return err
+}
+
+// WriteDir writes the bag to a new directory. An existing directory must be
+// empty. The bag is written next to it first, so a failed write leaves no
+// partial bag behind.
+func (b *Bag) WriteDir(dir string) error {
+	files, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	exists := err == nil
+	if len(files) > 0 {
+		return fmt.Errorf("%w: %s", ErrNotEmpty, dir)
+	}
+	tmpDir, err := os.MkdirTemp(path.Dir(dir), path.Base(dir)+".tmp")
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  dir,
+			"error": err.Error(),
+		}).Error("failed to create bag directory")
+		return err
+	}
+	err = b.write(func(name string) (io.WriteCloser, error) {
+		p := path.Join(tmpDir, name)
+		err := os.MkdirAll(path.Dir(p), 0755)
+		if err != nil {
+			return nil, err
+		}
+		return os.Create(p)
+	})
+	if err == nil {
+		// MkdirTemp creates it only readable by the owner
+		err = os.Chmod(tmpDir, 0755)
+	}
+	if err == nil && exists {
+		err = os.Remove(dir)
+	}
+	if err == nil {
+		err = os.Rename(tmpDir, dir)
+	}
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	return nil
+}
+
+// WriteZip writes the bag to a zip file, inside a top level directory named
+// after the zip file as RFC 8493 serialization requires.
+func (b *Bag) WriteZip(p string) error {
+	fp, err := os.Create(p)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Error("failed to create zip file")
+		return err
+	}
+	defer fp.Close()
+	zw := zip.NewWriter(fp)
+	base := strings.TrimSuffix(path.Base(p), path.Ext(p))
+	err = b.write(func(name string) (io.WriteCloser, error) {
+		w, err := zw.Create(path.Join(base, name))
+		if err != nil {
+			return nil, err
+		}
+		return nopWriteCloser{w}, nil
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,209 @@
+package bagit
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func testBag(t *testing.T) *Bag {
+	t.Helper()
+	source := path.Join(t.TempDir(), "documento.pdf")
+	err := os.WriteFile(source, []byte("%PDF-1.4 documento"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := New()
+	b.AddContent("expediente.json", []byte(`{"numero": 1}`))
+	b.AddFile("documentos/documento.pdf", source)
+	b.AddContent("textos/documento.txt", []byte("texto extraído\n"))
+	// only the first file with a name is written
+	b.AddContent("expediente.json", []byte("otro"))
+	b.AddInfo("Source-Organization", "Poder Judicial\n de la Ciudad")
+	b.AddInfo("External-Description", " ")
+	return b
+}
+
+// readDir returns the content of every file under dir by relative path.
+func readDir(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files := map[string][]byte{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(dir, p)
+		files[filepath.ToSlash(name)] = content
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// readZip returns the content of every file of the zip by path.
+func readZip(t *testing.T, p string) map[string][]byte {
+	t.Helper()
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = content
+	}
+	return files
+}
+
+// checkManifest checks that the manifest lists exactly the given files with
+// their sha256.
+func checkManifest(t *testing.T, files map[string][]byte, manifest string, names []string) {
+	t.Helper()
+	names = append([]string{}, names...)
+	sort.Strings(names)
+	want := make([]string, 0, len(names))
+	for _, name := range names {
+		content, found := files[name]
+		if !found {
+			t.Errorf("%s is missing", name)
+			continue
+		}
+		want = append(want, fmt.Sprintf("%x  %s", sha256.Sum256(content), name))
+	}
+	got := strings.Split(strings.TrimSuffix(string(files[manifest]), "\n"), "\n")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s =\n%s\nwant\n%s", manifest, strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// checkBag checks the manifests and the payload oxum against the files of
+// the bag.
+func checkBag(t *testing.T, files map[string][]byte) {
+	t.Helper()
+	payload := make([]string, 0)
+	var octets int
+	for name, content := range files {
+		if strings.HasPrefix(name, "data/") {
+			payload = append(payload, name)
+			octets += len(content)
+		}
+	}
+	wantPayload := []string{"data/documentos/documento.pdf", "data/expediente.json", "data/textos/documento.txt"}
+	sort.Strings(payload)
+	if !reflect.DeepEqual(payload, wantPayload) {
+		t.Errorf("payload = %v, want %v", payload, wantPayload)
+	}
+	if string(files["data/expediente.json"]) != `{"numero": 1}` {
+		t.Errorf("data/expediente.json = %q, want the first file added", files["data/expediente.json"])
+	}
+	if want := "BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n"; string(files["bagit.txt"]) != want {
+		t.Errorf("bagit.txt = %q, want %q", files["bagit.txt"], want)
+	}
+	checkManifest(t, files, "manifest-sha256.txt", payload)
+	checkManifest(t, files, "tagmanifest-sha256.txt", []string{"bag-info.txt", "bagit.txt", "manifest-sha256.txt"})
+
+	info := string(files["bag-info.txt"])
+	if !strings.HasPrefix(info, "Source-Organization: Poder Judicial de la Ciudad\nBagging-Date: ") {
+		t.Errorf("bag-info.txt = %q, want the fields in order without empty ones", info)
+	}
+	if !regexp.MustCompile(`(?m)^Bagging-Date: \d{4}-\d{2}-\d{2}$`).MatchString(info) {
+		t.Errorf("bag-info.txt = %q, want the bagging date", info)
+	}
+	if want := fmt.Sprintf("Payload-Oxum: %d.%d\n", octets, len(payload)); !strings.HasSuffix(info, want) {
+		t.Errorf("bag-info.txt = %q, want %q", info, want)
+	}
+}
+
+func TestWriteDir(t *testing.T) {
+	tests := []struct {
+		name   string
+		create func(dir string) error
+	}{
+		{"new directory", func(dir string) error { return nil }},
+		{"empty directory", func(dir string) error { return os.Mkdir(dir, 0755) }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dir := path.Join(parent, "bolsa")
+			err := test.create(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = testBag(t).WriteDir(dir)
+			if err != nil {
+				t.Fatalf("WriteDir() error = %v", err)
+			}
+			checkBag(t, readDir(t, dir))
+			entries, _ := os.ReadDir(parent)
+			if len(entries) != 1 {
+				t.Errorf("%d entries next to the bag, want only the bag", len(entries))
+			}
+		})
+	}
+}
+
+func TestWriteDirNotEmpty(t *testing.T) {
+	parent := t.TempDir()
+	dir := path.Join(parent, "bolsa")
+	err := os.MkdirAll(path.Join(dir, "data"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path.Join(dir, "data", "viejo.txt"), []byte("viejo"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = testBag(t).WriteDir(dir)
+	if !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("WriteDir() error = %v, want %v", err, ErrNotEmpty)
+	}
+	if got := readDir(t, dir); !reflect.DeepEqual(got, map[string][]byte{"data/viejo.txt": []byte("viejo")}) {
+		t.Errorf("directory = %v, want it unchanged", got)
+	}
+	entries, _ := os.ReadDir(parent)
+	if len(entries) != 1 {
+		t.Errorf("%d entries next to the directory, want no temporary one", len(entries))
+	}
+}
+
+func TestWriteZip(t *testing.T) {
+	p := path.Join(t.TempDir(), "bolsa.zip")
+	err := testBag(t).WriteZip(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for name, content := range readZip(t, p) {
+		if !strings.HasPrefix(name, "bolsa/") {
+			t.Errorf("%s is not in the top level directory", name)
+		}
+		files[strings.TrimPrefix(name, "bolsa/")] = content
+	}
+	checkBag(t, files)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	description string
+	run         func(args []string) error
+}
+
+var commands = map[string]*command{
+	"migrate-index": {
+		description: "import per-url metadata files into the index",
+		run:         migrateIndex,
+	},
+	"verify": {
+		description: "check the integrity of the saved files",
+		run:         verify,
+	},
+	"bag": {
+		description: "package an expediente and its documents as a BagIt bag",
+		run:         bag,
+	},
+	"gc": {
+		description: "remove saved files not referenced by any expediente json",
+		run:         gc,
+	},
+	"manifest": {
+		description: "rebuild the mirror manifest from expediente jsons",
+		run:         manifest,
+	},
+	"verify-snapshot": {
+		description: "verify a signed snapshot or the inclusion proof of a file",
+		run:         verifySnapshot,
+	},
+	"compare-text": {
+		description: "compare the pure go pdf text backend with pdftotext",
+		run:         compareText,
+	},
+	"image-hash": {
+		description: "print the perceptual hash of images, to ignore them during ocr",
+		run:         imageHash,
+	},
+	"quality": {
+		description: "list documents with low ocr confidence or little text",
+		run:         quality,
+	},
+	"signatures": {
+		description: "report the digital signatures of documents and their firmantes",
+		run:         signatures,
+	},
+	"query": {
+		description: "list saved files matching a query",
+		run:         queryIndex,
+	},
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [command] [flags]\n\n", os.Args[0])
+	fmt.Fprintf(out, "Without a command, fetches an expediente and writes its json.\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-16s %s\n", name, commands[name].description)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(fmt.Sprintf("%s %s", os.Args[0], name), flag.ExitOnError)
+}
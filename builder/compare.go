@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	extracttext "github.com/odia/juscaba/extracttext"
+	shared "github.com/odia/juscaba/shared"
+	log "github.com/sirupsen/logrus"
+)
+
+// TextComparison is the difference between the text backends for a pdf.
+type TextComparison struct {
+	Path       string                  `json:"path"`
+	SourceURL  string                  `json:"sourceURL,omitempty"`
+	Error      string                  `json:"error,omitempty"`
+	Comparison *extracttext.Comparison `json:"comparison,omitempty"`
+}
+
+type TextComparisonReport struct {
+	Compared int `json:"compared"`
+	Failed   int `json:"failed"`
+	// WordErrorRate is the mean of the compared documents
+	WordErrorRate float64           `json:"wordErrorRate"`
+	Documents     []*TextComparison `json:"documents"`
+}
+
+// savedPDFs lists the saved pdfs. The type of entries without one is
+// detected from their content, and the ones that can not be read are
+// listed with their error.
+func savedPDFs(fm *shared.FileManager) ([]*TextComparison, error) {
+	idx, err := fm.Index()
+	if err != nil {
+		return nil, err
+	}
+	documents := make([]*TextComparison, 0)
+	for _, sf := range idx.All() {
+		doc := &TextComparison{
+			Path:      fm.DestinationPath(sf),
+			SourceURL: sf.SourceURL,
+		}
+		contentType, err := fm.ContentType(sf)
+		if err != nil {
+			doc.Error = err.Error()
+		} else if contentType != shared.PDFContentType {
+			continue
+		}
+		documents = append(documents, doc)
+	}
+	return documents, nil
+}
+
+func compareText(argv []string) error {
+	var pdfsPath, reportPath string
+	flags := newFlagSet("compare-text")
+	flags.StringVar(&pdfsPath, "pdfs", "", "pdfs path, compares every saved pdf")
+	flags.StringVar(&reportPath, "report", "", "json report destination path (default stdout)")
+	flags.Parse(argv)
+
+	documents := make([]*TextComparison, 0)
+	if pdfsPath != "" {
+		fm := &shared.FileManager{Directory: pdfsPath}
+		defer fm.Close()
+		saved, err := savedPDFs(fm)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"pdfs":  pdfsPath,
+				"error": err.Error(),
+			}).Error("failed to open index")
+			return err
+		}
+		documents = append(documents, saved...)
+	}
+	for _, p := range flags.Args() {
+		documents = append(documents, &TextComparison{Path: p})
+	}
+
+	options := extracttext.DefaultOptions()
+	report := &TextComparisonReport{Documents: documents}
+	for _, doc := range documents {
+		if doc.Error != "" {
+			report.Failed++
+			continue
+		}
+		comparison, err := extracttext.CompareBackends(context.Background(), options, doc.Path)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"path":  doc.Path,
+				"error": err.Error(),
+			}).Error("failed to compare text backends")
+			doc.Error = err.Error()
+			report.Failed++
+			continue
+		}
+		doc.Comparison = comparison
+		report.Compared++
+		report.WordErrorRate += comparison.WordErrorRate
+	}
+	if report.Compared > 0 {
+		report.WordErrorRate /= float64(report.Compared)
+	}
+
+	out := os.Stdout
+	if reportPath != "" {
+		var err error
+		out, err = os.Create(reportPath)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"report": reportPath,
+				"error":  err.Error(),
+			}).Error("failed to create report")
+			return err
+		}
+		defer out.Close()
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(report)
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"compared":      report.Compared,
+		"failed":        report.Failed,
+		"wordErrorRate": report.WordErrorRate,
+	}).Info("compared text backends")
+	return nil
+}
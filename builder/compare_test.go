@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	shared "github.com/odia/juscaba/shared"
+)
+
+func TestSavedPDFs(t *testing.T) {
+	dir := t.TempDir()
+	pdf := []byte("%PDF-1.4\n%%EOF\n")
+	writeLegacyFile(t, dir, "https://example.com/legacy.pdf", pdf)
+	writeLegacyFile(t, dir, "https://example.com/legacy-zip.pdf", testZip(t, "a.txt", "uno"))
+	writeLegacyFile(t, dir, "https://example.com/missing.pdf", pdf)
+	fm := &shared.FileManager{Directory: dir}
+	defer fm.Close()
+	missing, err := fm.SavedFileForURL("https://example.com/missing.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Remove(fm.DestinationPath(missing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for url, content := range map[string][]byte{
+		"https://example.com/new.pdf": pdf,
+		"https://example.com/new.txt": []byte("texto"),
+	} {
+		sf := shared.NewSavedFile(url, "")
+		sf.SetContent(content)
+		sf.DestinationFilename = sf.ContentHash + shared.ContentTypeExtension(sf.ContentType)
+		err := fm.SaveSavedFile(sf, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	documents, err := savedPDFs(fm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, doc := range documents {
+		got[doc.SourceURL] = doc.Error != ""
+		if filepath.Dir(doc.Path) != dir {
+			t.Errorf("Path = %s, want a file in %s", doc.Path, dir)
+		}
+	}
+	// the values tell whether the document failed
+	want := map[string]bool{
+		"https://example.com/legacy.pdf":  false,
+		"https://example.com/missing.pdf": true,
+		"https://example.com/new.pdf":     false,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("savedPDFs() = %v, want %v", got, want)
+	}
+}
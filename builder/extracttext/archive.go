@@ -0,0 +1,247 @@
+package extracttext
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	shared "github.com/odia/juscaba/shared"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrArchiveLimit is logged when an archive has more or bigger members than
+// allowed; the members within the limits are still extracted.
+var ErrArchiveLimit = errors.New("archive exceeds the limits")
+
+// ArchiveOptions limits what is unpacked from archives, so zip bombs and
+// huge uploads do not fill the disk.
+type ArchiveOptions struct {
+	// MaxFiles is the number of members extracted from each archive.
+	MaxFiles int `json:"maxFiles"`
+	// MaxFileSize is the size in bytes of the biggest member extracted.
+	MaxFileSize int64 `json:"maxFileSize"`
+	// MaxTotalSize is the size in bytes of all the members of an archive.
+	MaxTotalSize int64 `json:"maxTotalSize"`
+	// MaxDepth is how many archives, or pdfs with attachments, can be
+	// nested.
+	MaxDepth int `json:"maxDepth"`
+}
+
+// extractArchive has no text, the members of archives are extracted as
+// attachments.
+func extractArchive(ctx context.Context, r io.Reader, options *Options) (*Result, error) {
+	return &Result{Pages: make([]shared.Page, 0)}, nil
+}
+
+// memberName returns the path of an archive member, and false for
+// directories and paths that would escape the destination, e.g. "../x" or
+// "/etc/x".
+func memberName(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasSuffix(name, "/") || path.IsAbs(name) || (len(name) > 1 && name[1] == ':') {
+		return "", false
+	}
+	for _, element := range strings.Split(name, "/") {
+		if element == ".." {
+			return "", false
+		}
+	}
+	return path.Clean(name), true
+}
+
+// archiveLimiter keeps the count and size of the extracted members.
+type archiveLimiter struct {
+	options *ArchiveOptions
+	files   int
+	total   int64
+}
+
+// allow tells if a member of the given size can be extracted.
+func (l *archiveLimiter) allow(name string, size int64) bool {
+	var reason string
+	switch {
+	case l.files >= l.options.MaxFiles:
+		reason = "too many files"
+	case size > l.options.MaxFileSize:
+		reason = "file too big"
+	case l.total+size > l.options.MaxTotalSize:
+		reason = "archive too big"
+	default:
+		return true
+	}
+	log.WithFields(log.Fields{
+		"name":   name,
+		"size":   size,
+		"reason": reason,
+		"error":  ErrArchiveLimit.Error(),
+	}).Warn("skipping archive member")
+	return false
+}
+
+func (l *archiveLimiter) add(size int64) {
+	l.files++
+	l.total += size
+}
+
+// zipAttachments returns the members of a zip file.
+func zipAttachments(content []byte, options *ArchiveOptions) ([]*Attachment, error) {
+	r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+	limiter := &archiveLimiter{options: options}
+	attachments := make([]*Attachment, 0)
+	for _, f := range r.File {
+		name, ok := memberName(f.Name)
+		if !ok || f.Mode()&fs.ModeType != 0 {
+			if !strings.HasSuffix(f.Name, "/") {
+				log.WithFields(log.Fields{
+					"name": f.Name,
+				}).Warn("skipping unsafe archive member")
+			}
+			continue
+		}
+		if f.Flags&0x1 != 0 {
+			log.WithFields(log.Fields{
+				"name": f.Name,
+			}).Warn("skipping encrypted archive member")
+			continue
+		}
+		if !limiter.allow(name, int64(f.UncompressedSize64)) {
+			continue
+		}
+		fp, err := f.Open()
+		if err != nil {
+			continue
+		}
+		// the declared size may be a lie, never read more than allowed
+		data, err := io.ReadAll(io.LimitReader(fp, options.MaxFileSize+1))
+		fp.Close()
+		if err != nil || int64(len(data)) > options.MaxFileSize {
+			log.WithFields(log.Fields{
+				"name": f.Name,
+			}).Warn("skipping unreadable archive member")
+			continue
+		}
+		limiter.add(int64(len(data)))
+		attachments = append(attachments, &Attachment{
+			Name:    name,
+			ModTime: f.Modified,
+			Content: data,
+		})
+	}
+	return attachments, nil
+}
+
+// rarMember is a member of a rar file as listed by unrar lt.
+type rarMember struct {
+	Name      string
+	Type      string
+	Size      int64
+	ModTime   time.Time
+	Encrypted bool
+}
+
+// parseRarListing reads the technical listing of unrar lt, a block of
+// "key: value" lines per member.
+func parseRarListing(listing []byte) []*rarMember {
+	members := make([]*rarMember, 0)
+	var member *rarMember
+	for _, line := range strings.Split(string(listing), "\n") {
+		parts := strings.SplitN(strings.TrimLeft(line, " "), ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := parts[0], strings.TrimRight(parts[1], "\r")
+		if key == "Name" {
+			member = &rarMember{Name: value}
+			members = append(members, member)
+			continue
+		}
+		if member == nil {
+			continue
+		}
+		switch key {
+		case "Type":
+			member.Type = value
+		case "Size":
+			member.Size, _ = strconv.ParseInt(value, 10, 64)
+		case "mtime":
+			// fractions of a second are written after a comma
+			member.ModTime, _ = time.ParseInLocation("2006-01-02 15:04:05", strings.SplitN(value, ",", 2)[0], time.Local)
+		case "Flags":
+			member.Encrypted = strings.Contains(value, "encrypted")
+		}
+	}
+	return members
+}
+
+// rarAttachments extracts the members of a rar file with unrar, which must
+// be installed. The members are listed first and checked against the limits
+// by their declared size, then read one by one without touching the disk
+// and never beyond the limits, whatever their real size.
+func rarAttachments(content []byte, options *Options) ([]*Attachment, error) {
+	dir, p, err := writeToTempFile(bytes.NewReader(content))
+	defer os.RemoveAll(dir)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	// -p- never asks for passwords, -c- skips the comments
+	listing, err := runCommand(ctx, options.CommandTimeout, nil, "unrar", "lt", "-p-", "-c-", p)
+	if err != nil {
+		return nil, err
+	}
+	limiter := &archiveLimiter{options: &options.Archive}
+	attachments := make([]*Attachment, 0)
+	for _, member := range parseRarListing(listing) {
+		if member.Type != "File" {
+			continue
+		}
+		name, ok := memberName(member.Name)
+		// unrar reads names as wildcards and @name as a list of names
+		if !ok || strings.ContainsAny(member.Name, "*?") || strings.HasPrefix(member.Name, "@") {
+			log.WithFields(log.Fields{
+				"name": member.Name,
+			}).Warn("skipping unsafe archive member")
+			continue
+		}
+		if member.Encrypted {
+			log.WithFields(log.Fields{
+				"name": member.Name,
+			}).Warn("skipping encrypted archive member")
+			continue
+		}
+		if !limiter.allow(name, member.Size) {
+			continue
+		}
+		// the declared size may be a lie, never read more than allowed
+		limit := options.Archive.MaxFileSize
+		if left := options.Archive.MaxTotalSize - limiter.total; left < limit {
+			limit = left
+		}
+		data, err := runCommandLimit(ctx, options.CommandTimeout, limit, "unrar", "p", "-inul", "-p-", "-c-", p, member.Name)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"name":  member.Name,
+				"error": err.Error(),
+			}).Warn("skipping unreadable archive member")
+			continue
+		}
+		limiter.add(int64(len(data)))
+		attachments = append(attachments, &Attachment{
+			Name:    name,
+			ModTime: member.ModTime,
+			Content: data,
+		})
+	}
+	return attachments, nil
+}
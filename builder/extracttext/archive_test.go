@@ -0,0 +1,234 @@
+package extracttext
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemberName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"escrito.pdf", "escrito.pdf", true},
+		{"anexos/prueba.pdf", "anexos/prueba.pdf", true},
+		{"anexos\\prueba.pdf", "anexos/prueba.pdf", true},
+		{"./anexos//prueba.pdf", "anexos/prueba.pdf", true},
+		{"anexos/", "", false},
+		{"", "", false},
+		{"../etc/passwd", "", false},
+		{"anexos/../../etc/passwd", "", false},
+		{"..\\..\\windows\\win.ini", "", false},
+		{"/etc/passwd", "", false},
+		{"C:\\windows\\win.ini", "", false},
+		{"c:escrito.pdf", "", false},
+	}
+	for _, test := range tests {
+		got, ok := memberName(test.name)
+		if got != test.want || ok != test.ok {
+			t.Errorf("memberName(%q) = %q, %v, want %q, %v", test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+var testArchiveOptions = ArchiveOptions{
+	MaxFiles:     3,
+	MaxFileSize:  10,
+	MaxTotalSize: 25,
+	MaxDepth:     1,
+}
+
+func TestArchiveLimiter(t *testing.T) {
+	tests := []struct {
+		name  string
+		sizes []int64
+		want  []bool
+	}{
+		{"within the limits", []int64{10, 10, 5}, []bool{true, true, true}},
+		{"file too big", []int64{11, 10}, []bool{false, true}},
+		{"archive too big", []int64{10, 10, 10, 5}, []bool{true, true, false, true}},
+		{"too many files", []int64{1, 1, 1, 1}, []bool{true, true, true, false}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := testArchiveOptions
+			limiter := &archiveLimiter{options: &options}
+			got := make([]bool, 0)
+			for _, size := range test.sizes {
+				allowed := limiter.allow("member", size)
+				if allowed {
+					limiter.add(size)
+				}
+				got = append(got, allowed)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("allowed %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+type zipMember struct {
+	name    string
+	content string
+	flags   uint16
+}
+
+func buildZip(t *testing.T, members []zipMember) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for _, member := range members {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: member.name, Method: zip.Deflate, Flags: member.flags})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(member.content))
+	}
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestZipAttachments(t *testing.T) {
+	content := buildZip(t, []zipMember{
+		{name: "a.txt", content: "primero"},
+		{name: "../evil.txt", content: "fuera"},
+		{name: "dir/"},
+		{name: "big.txt", content: strings.Repeat("x", 11)},
+		{name: "secret.txt", content: "clave", flags: 0x1},
+		{name: "dir/b.txt", content: "segundo"},
+		{name: "c.txt", content: "tercero"},
+		{name: "d.txt", content: "cuarto"},
+	})
+	options := testArchiveOptions
+	attachments, err := zipAttachments(content, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, a := range attachments {
+		got[a.Name] = string(a.Content)
+	}
+	// d.txt is over the number of files
+	want := map[string]string{"a.txt": "primero", "dir/b.txt": "segundo", "c.txt": "tercero"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("zipAttachments() = %v, want %v", got, want)
+	}
+}
+
+const testRarListing = `
+UNRAR 6.00 freeware      Copyright (c) 1993-2020 Alexander Roshal
+
+Archive: test.rar
+Details: RAR 5
+
+        Name: anexos/escrito: final.pdf
+        Type: File
+        Size: 1234
+ Packed size: 1000
+       Ratio: 81%
+       mtime: 2024-01-02 03:04:05,123456789
+  Attributes: -rw-r--r--
+       CRC32: 1234ABCD
+     Host OS: Unix
+ Compression: RAR 5.0(v50) -m3 -md=128K
+
+        Name: anexos
+        Type: Directory
+       mtime: 2024-01-02 03:04:05,000000000
+
+        Name: secreto.txt
+        Type: File
+        Size: 10
+       Flags: encrypted
+`
+
+func TestParseRarListing(t *testing.T) {
+	got := parseRarListing([]byte(testRarListing))
+	want := []*rarMember{
+		{Name: "anexos/escrito: final.pdf", Type: "File", Size: 1234, ModTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)},
+		{Name: "anexos", Type: "Directory", ModTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)},
+		{Name: "secreto.txt", Type: "File", Size: 10, Encrypted: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		for _, m := range got {
+			t.Logf("%+v", m)
+		}
+		t.Errorf("parseRarListing() did not return the expected members")
+	}
+}
+
+// fakeUnrar lists five members and prints "content" for each, except for
+// bomb.txt, which declares 5 bytes and writes without end.
+const fakeUnrar = `#!/bin/sh
+case "$1" in
+lt)
+	printf '        Name: a.txt\n        Type: File\n        Size: 7\n\n'
+	printf '        Name: bomb.txt\n        Type: File\n        Size: 5\n\n'
+	printf '        Name: big.txt\n        Type: File\n        Size: 100\n\n'
+	printf '        Name: ../evil.txt\n        Type: File\n        Size: 5\n\n'
+	printf '        Name: b.txt\n        Type: File\n        Size: 7\n'
+	;;
+p)
+	eval "name=\${$#}"
+	case "$name" in
+	bomb.txt) exec yes ;;
+	*) printf 'content' ;;
+	esac
+	;;
+esac
+`
+
+func TestRarAttachments(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	bin := t.TempDir()
+	err := os.WriteFile(filepath.Join(bin, "unrar"), []byte(fakeUnrar), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	options := DefaultOptions()
+	options.Archive = testArchiveOptions
+	options.CommandTimeout = 10 * time.Second
+	attachments, err := rarAttachments([]byte("Rar!\x1a\x07\x01\x00"), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0)
+	for _, a := range attachments {
+		got = append(got, a.Name+"="+string(a.Content))
+	}
+	want := []string{"a.txt=content", "b.txt=content"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rarAttachments() = %v, want %v", got, want)
+	}
+}
+
+func TestRunCommandLimit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs yes")
+	}
+	_, err := runCommandLimit(context.Background(), 10*time.Second, 1000, "yes")
+	if !errors.Is(err, ErrOutputLimit) {
+		t.Errorf("runCommandLimit() error = %v, want %v", err, ErrOutputLimit)
+	}
+	out, err := runCommandLimit(context.Background(), 10*time.Second, 1000, "echo", "hola")
+	if err != nil || string(out) != "hola\n" {
+		t.Errorf("runCommandLimit() = %q, %v", out, err)
+	}
+}
@@ -0,0 +1,47 @@
+package extracttext
+
+import (
+	"time"
+
+	"github.com/odia/juscaba/pdf"
+	shared "github.com/odia/juscaba/shared"
+)
+
+// Attachment is a file contained in a document, extracted as a child
+// document of its own.
+type Attachment struct {
+	Name        string
+	Description string
+	ModTime     time.Time
+	Content     []byte
+}
+
+// Attachments returns the files contained in a document, e.g. the embedded
+// files of a pdf or the members of an archive. Types without attachments
+// return none.
+func Attachments(contentType string, content []byte, options *Options) (attachments []*Attachment, err error) {
+	switch contentType {
+	case shared.ZipContentType:
+		return zipAttachments(content, &options.Archive)
+	case shared.RARContentType:
+		return rarAttachments(content, options)
+	case shared.PDFContentType:
+	default:
+		return nil, nil
+	}
+	defer recoverPDF(&err)
+	r, err := pdf.Open(content)
+	if err != nil {
+		return nil, err
+	}
+	attachments = make([]*Attachment, 0)
+	for _, file := range r.EmbeddedFiles() {
+		attachments = append(attachments, &Attachment{
+			Name:        file.Name,
+			Description: file.Description,
+			ModTime:     file.ModDate,
+			Content:     file.Data,
+		})
+	}
+	return attachments, nil
+}
@@ -0,0 +1,48 @@
+package extracttext
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/odia/juscaba/pdf"
+	shared "github.com/odia/juscaba/shared"
+)
+
+func TestPDFAttachments(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("testdata", "attached.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachments, err := Attachments(shared.PDFContentType, content, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Attachments() error = %v", err)
+	}
+	if len(attachments) != 1 {
+		t.Fatalf("got %d attachments, want 1", len(attachments))
+	}
+	a := attachments[0]
+	if a.Name != "anexo.txt" || string(a.Content) != "Anexo I: planilla de gastos\n" {
+		t.Errorf("attachment = %q %q", a.Name, a.Content)
+	}
+}
+
+func TestPDFAttachmentsMalformed(t *testing.T) {
+	_, err := Attachments(shared.PDFContentType, []byte("%PDF-1.4\n1 0 obj\n<<"), DefaultOptions())
+	if !errors.Is(err, pdf.ErrSyntax) {
+		t.Errorf("Attachments() error = %v, want %v", err, pdf.ErrSyntax)
+	}
+}
+
+func TestRecoverPDF(t *testing.T) {
+	read := func() (err error) {
+		defer recoverPDF(&err)
+		var r *pdf.Reader
+		r.EmbeddedFiles()
+		return nil
+	}
+	if err := read(); !errors.Is(err, pdf.ErrSyntax) {
+		t.Errorf("recoverPDF() error = %v, want %v", err, pdf.ErrSyntax)
+	}
+}
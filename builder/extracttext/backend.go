@@ -0,0 +1,92 @@
+package extracttext
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/odia/juscaba/pdf"
+	log "github.com/sirupsen/logrus"
+)
+
+// PopplerBackend runs pdftotext, GoBackend uses the pure go parser of the
+// pdf package and AutoBackend picks poppler when pdftotext is installed.
+const AutoBackend = "auto"
+const PopplerBackend = "poppler"
+const GoBackend = "go"
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// Backend returns the backend that reads the text layer of pdfs, resolving
+// AutoBackend.
+func (options *Options) Backend() string {
+	if options.PDFBackend == AutoBackend || options.PDFBackend == "" {
+		if hasCommand("pdftotext") {
+			return PopplerBackend
+		}
+		return GoBackend
+	}
+	return options.PDFBackend
+}
+
+// canRasterize tells if the command that turns pages into images is
+// installed.
+func (options *Options) canRasterize() bool {
+	if options.Rasterizer == PdftohtmlRasterizer {
+		return hasCommand("pdftohtml")
+	}
+	return hasCommand("pdftoppm")
+}
+
+// goPlainText reads the text layer with the pure go parser, ending every
+// page with a form feed like pdftotext.
+func goPlainText(p string, layout bool) (text string, err error) {
+	defer recoverPDF(&err)
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	reader, err := pdf.Open(content)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Error("failed to open pdf")
+		return "", err
+	}
+	texts := reader.Text()
+	if layout {
+		texts = reader.LayoutText()
+	}
+	var b strings.Builder
+	for _, text := range texts {
+		b.WriteString(text)
+		b.WriteString("\f")
+	}
+	return b.String(), nil
+}
+
+// getDocumentText reads the text layer of every page, with its physical
+// layout when layout is set.
+func getDocumentText(ctx context.Context, options *Options, p string, layout bool) (string, error) {
+	if options.Backend() == GoBackend {
+		return goPlainText(p, layout)
+	}
+	args := []string{p, "-"}
+	if layout {
+		args = []string{"-layout", p, "-"}
+	}
+	stdout, err := runCommand(ctx, options.CommandTimeout, nil, "pdftotext", args...)
+	if err != nil {
+		return "", err
+	}
+	return string(stdout), nil
+}
+
+func getDocumentPlainText(ctx context.Context, options *Options, p string) (string, error) {
+	return getDocumentText(ctx, options, p, options.PreserveLayout)
+}
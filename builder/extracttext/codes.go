@@ -0,0 +1,92 @@
+package extracttext
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"unicode/utf8"
+
+	shared "github.com/odia/juscaba/shared"
+	log "github.com/sirupsen/logrus"
+)
+
+// zbarNoSymbols is the exit status of zbarimg when it finds no codes.
+const zbarNoSymbols = 4
+
+// zbarResult is the xml output of zbarimg.
+type zbarResult struct {
+	Sources []struct {
+		Indexes []struct {
+			Number  int `xml:"num,attr"`
+			Symbols []struct {
+				Type string `xml:"type,attr"`
+				Data struct {
+					Format string `xml:"format,attr"`
+					Value  string `xml:",chardata"`
+				} `xml:"data"`
+			} `xml:"symbol"`
+		} `xml:"index"`
+	} `xml:"source"`
+}
+
+// canDecodeCodes tells if the commands that render pages and decode their
+// codes are installed.
+func canDecodeCodes() bool {
+	return hasCommand("pdftoppm") && hasCommand("zbarimg")
+}
+
+// zbarimg decodes the codes of an image. Images with several pages, like
+// tiffs, number their codes from page.
+func zbarimg(ctx context.Context, options *Options, image string, page int) ([]shared.Code, error) {
+	stdout, err := runCommandStatus(ctx, options.CommandTimeout, nil, []int{zbarNoSymbols}, "zbarimg", "--quiet", "--xml", image)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]shared.Code, 0)
+	if len(stdout) == 0 {
+		return codes, nil
+	}
+	res := &zbarResult{}
+	err = xml.Unmarshal(stdout, res)
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range res.Sources {
+		for _, index := range source.Indexes {
+			for _, symbol := range index.Symbols {
+				data := symbol.Data.Value
+				// binary payloads are base64 encoded, the ones that are
+				// text are decoded
+				if symbol.Data.Format == "base64" {
+					decoded, err := base64.StdEncoding.DecodeString(data)
+					if err == nil && utf8.Valid(decoded) {
+						data = string(decoded)
+					}
+				}
+				codes = append(codes, shared.Code{
+					Page: page + index.Number,
+					Type: symbol.Type,
+					Data: data,
+				})
+			}
+		}
+	}
+	return codes, nil
+}
+
+// imageCodes decodes the codes of an image adjunto.
+func imageCodes(ctx context.Context, options *Options, image string) []shared.Code {
+	if !hasCommand("zbarimg") {
+		log.Warn("zbarimg is not installed, skipping codes")
+		return nil
+	}
+	codes, err := zbarimg(ctx, options, image, 1)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"image": image,
+			"error": err.Error(),
+		}).Warn("failed to decode image codes")
+		return nil
+	}
+	return codes
+}
@@ -0,0 +1,95 @@
+package extracttext
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	shared "github.com/odia/juscaba/shared"
+)
+
+// fakeTools write every call to calls.log next to them. pdftoppm renders
+// blank pages, zbarimg finds a code in each and tesseract reads "texto
+// escaneado".
+var fakeTools = map[string]string{
+	"pdftoppm": `#!/bin/sh
+echo pdftoppm "$@" >> "$(dirname "$0")/calls.log"
+eval "out=\${$#}"
+printf 'png' > "$out.png"
+`,
+	"zbarimg": `#!/bin/sh
+echo zbarimg "$@" >> "$(dirname "$0")/calls.log"
+printf "<barcodes><source href='$3'><index num='0'><symbol type='QR-Code'><data><![CDATA[https://example.com/verificar]]></data></symbol></index></source></barcodes>"
+`,
+	"tesseract": `#!/bin/sh
+echo tesseract "$@" >> "$(dirname "$0")/calls.log"
+printf 'texto escaneado\n' > "$2.txt"
+printf '<html><body></body></html>' > "$2.hocr"
+`,
+}
+
+func installFakeTools(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	bin := t.TempDir()
+	for name, script := range fakeTools {
+		err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return filepath.Join(bin, "calls.log")
+}
+
+func TestZbarimg(t *testing.T) {
+	installFakeTools(t)
+	codes, err := zbarimg(context.Background(), DefaultOptions(), "page.png", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []shared.Code{{Page: 3, Type: "QR-Code", Data: "https://example.com/verificar"}}
+	if !reflect.DeepEqual(codes, want) {
+		t.Errorf("zbarimg() = %v, want %v", codes, want)
+	}
+}
+
+// TestPDFCodes checks that a page that is ocr'd and has its codes decoded
+// is rendered only once.
+func TestPDFCodes(t *testing.T) {
+	calls := installFakeTools(t)
+	content, err := os.ReadFile(filepath.Join("testdata", "signed.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultOptions()
+	options.PDFBackend = GoBackend
+	options.Codes = true
+	options.Workers = 2
+	options.CommandTimeout = 10 * time.Second
+	result, err := pdfExtractor{}.Extract(context.Background(), strings.NewReader(string(content)), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []shared.Code{{Page: 1, Type: "QR-Code", Data: "https://example.com/verificar"}}
+	if !reflect.DeepEqual(result.Codes, want) {
+		t.Errorf("Codes = %v, want %v", result.Codes, want)
+	}
+	if len(result.Pages) != 1 || result.Pages[0].Source != shared.OCRSource {
+		t.Errorf("Pages = %v, want one ocr'd page", result.Pages)
+	}
+	log, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(log), "pdftoppm "); n != 1 {
+		t.Errorf("the page was rendered %d times, want 1:\n%s", n, log)
+	}
+}
@@ -0,0 +1,111 @@
+package extracttext
+
+import (
+	"context"
+	"strings"
+)
+
+// PageComparison is the difference between the text of a page read with
+// pdftotext and with the go backend.
+type PageComparison struct {
+	Number int `json:"number"`
+	// Words is the number of words pdftotext reads
+	Words int `json:"words"`
+	// Distance is the number of words inserted, deleted or replaced to get
+	// the go backend text from the pdftotext text
+	Distance int `json:"distance"`
+	// WordErrorRate is Distance divided by Words
+	WordErrorRate float64 `json:"wordErrorRate"`
+}
+
+type Comparison struct {
+	PopplerPages  int               `json:"popplerPages"`
+	GoPages       int               `json:"goPages"`
+	Words         int               `json:"words"`
+	Distance      int               `json:"distance"`
+	WordErrorRate float64           `json:"wordErrorRate"`
+	Pages         []*PageComparison `json:"pages"`
+}
+
+// wordDistance is the levenshtein distance between two lists of words.
+func wordDistance(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(prev[j]+1, current[j-1]+1, prev[j-1]+cost)
+		}
+		prev, current = current, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func errorRate(distance, words int) float64 {
+	if words == 0 {
+		if distance == 0 {
+			return 0
+		}
+		return 1
+	}
+	return float64(distance) / float64(words)
+}
+
+// CompareBackends reads the text layer of the pdf at p with pdftotext and
+// with the go backend, and measures how far apart they are.
+func CompareBackends(ctx context.Context, options *Options, p string) (*Comparison, error) {
+	popplerOptions := *options
+	popplerOptions.PDFBackend = PopplerBackend
+	popplerText, err := getDocumentPlainText(ctx, &popplerOptions, p)
+	if err != nil {
+		return nil, err
+	}
+	goText, err := goPlainText(p, options.PreserveLayout)
+	if err != nil {
+		return nil, err
+	}
+	popplerPages := splitPages(popplerText)
+	goPages := splitPages(goText)
+	comparison := &Comparison{
+		PopplerPages: len(popplerPages),
+		GoPages:      len(goPages),
+		Pages:        make([]*PageComparison, 0, len(popplerPages)),
+	}
+	for i := 0; i < len(popplerPages) || i < len(goPages); i++ {
+		var expected, actual []string
+		if i < len(popplerPages) {
+			expected = strings.Fields(popplerPages[i])
+		}
+		if i < len(goPages) {
+			actual = strings.Fields(goPages[i])
+		}
+		page := &PageComparison{
+			Number:   i + 1,
+			Words:    len(expected),
+			Distance: wordDistance(expected, actual),
+		}
+		page.WordErrorRate = errorRate(page.Distance, page.Words)
+		comparison.Words += page.Words
+		comparison.Distance += page.Distance
+		comparison.Pages = append(comparison.Pages, page)
+	}
+	comparison.WordErrorRate = errorRate(comparison.Distance, comparison.Words)
+	return comparison, nil
+}
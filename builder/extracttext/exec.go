@@ -0,0 +1,126 @@
+package extracttext
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrOutputLimit is returned when a command writes more than allowed.
+var ErrOutputLimit = errors.New("command output exceeds the limit")
+
+// runCommand runs a command and returns its output. The command and every
+// process it starts are killed when the timeout or the context expire.
+func runCommand(ctx context.Context, timeout time.Duration, env []string, name string, args ...string) ([]byte, error) {
+	return runCommandStatus(ctx, timeout, env, nil, name, args...)
+}
+
+// runCommandStatus is runCommand for commands that also exit with the
+// statuses in ok when they succeed, e.g. zbarimg when it finds no codes.
+func runCommandStatus(ctx context.Context, timeout time.Duration, env []string, ok []int, name string, args ...string) ([]byte, error) {
+	var stdout bytes.Buffer
+	err := runCommandOutput(ctx, timeout, env, ok, &stdout, name, args...)
+	if err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// limitedBuffer keeps up to limit bytes, and cancels the command writing to
+// it when it writes more. The buffer is not embedded, its ReadFrom would
+// bypass the limit.
+type limitedBuffer struct {
+	b        bytes.Buffer
+	limit    int64
+	exceeded bool
+	cancel   context.CancelFunc
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if int64(b.b.Len()+len(p)) > b.limit {
+		b.exceeded = true
+		b.cancel()
+		return 0, ErrOutputLimit
+	}
+	return b.b.Write(p)
+}
+
+// runCommandLimit is runCommand for commands whose output may be huge, like
+// the members of an archive: the command is killed as soon as it writes more
+// than limit bytes, and ErrOutputLimit is returned.
+func runCommandLimit(ctx context.Context, timeout time.Duration, limit int64, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stdout := &limitedBuffer{limit: limit, cancel: cancel}
+	err := runCommandOutput(ctx, timeout, nil, nil, stdout, name, args...)
+	if err != nil {
+		return nil, err
+	}
+	return stdout.b.Bytes(), nil
+}
+
+// runCommandOutput runs a command writing its output to stdout.
+func runCommandOutput(ctx context.Context, timeout time.Duration, env []string, ok []int, stdout io.Writer, name string, args ...string) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Start()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Errorf("failed to run %s", name)
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-done:
+		}
+	}()
+	err = cmd.Wait()
+	close(done)
+	if limited, isLimited := stdout.(*limitedBuffer); isLimited && limited.exceeded {
+		return ErrOutputLimit
+	}
+	if ctx.Err() != nil {
+		log.WithFields(log.Fields{
+			"args":  args,
+			"error": ctx.Err().Error(),
+		}).Errorf("%s timed out", name)
+		return ctx.Err()
+	}
+	if exitErr, isExit := err.(*exec.ExitError); isExit {
+		for _, status := range ok {
+			if exitErr.ExitCode() == status {
+				return nil
+			}
+		}
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"stderr": stderr.String(),
+			"error":  err.Error(),
+		}).Errorf("failed to wait for %s", name)
+		return err
+	}
+	return nil
+}
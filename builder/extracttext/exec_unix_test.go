@@ -0,0 +1,60 @@
+//go:build !windows
+// +build !windows
+
+package extracttext
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// processRunning tells whether the process exists and is not a zombie
+// waiting for a parent that may never reap it.
+func processRunning(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		// without procfs, zombies are reaped by init soon enough
+		return true
+	}
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
+
+// TestRunCommandTimeout checks that a command that leaves a child behind is
+// killed with the child when the timeout expires, instead of waiting for the
+// child to close the output.
+func TestRunCommandTimeout(t *testing.T) {
+	var stdout bytes.Buffer
+	start := time.Now()
+	err := runCommandOutput(context.Background(), 100*time.Millisecond, nil, nil, &stdout, "sh", "-c", "sleep 10 & echo $!; sleep 10")
+	elapsed := time.Since(start)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("runCommandOutput() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("runCommandOutput() returned after %v", elapsed)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(stdout.String()))
+	if err != nil {
+		t.Fatalf("background child pid = %q", stdout.String())
+	}
+	deadline := time.Now().Add(time.Second)
+	for processRunning(pid) {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("the background child %d is still running", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package extracttext
+
+import (
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,89 @@
+package extracttext
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/odia/juscaba/shared"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrUnsupportedType is returned when no extractor is registered for the
+// type of a document.
+var ErrUnsupportedType = errors.New("no extractor for document type")
+
+// Extractor gets the text of a document of a given type. It must stop when
+// ctx is done.
+type Extractor interface {
+	Extract(ctx context.Context, r io.Reader, options *Options) (*Result, error)
+}
+
+// ExtractorFunc adapts a function to the Extractor interface.
+type ExtractorFunc func(ctx context.Context, r io.Reader, options *Options) (*Result, error)
+
+func (f ExtractorFunc) Extract(ctx context.Context, r io.Reader, options *Options) (*Result, error) {
+	return f(ctx, r, options)
+}
+
+var extractorsMutex sync.RWMutex
+var extractors = map[string]Extractor{
+	shared.PDFContentType:  pdfExtractor{},
+	shared.DOCXContentType: ExtractorFunc(extractDOCX),
+	shared.ODTContentType:  ExtractorFunc(extractODT),
+	shared.RTFContentType:  ExtractorFunc(extractRTF),
+	shared.HTMLContentType: ExtractorFunc(extractHTML),
+	shared.TextContentType: ExtractorFunc(extractPlainText),
+	shared.PNGContentType:  ExtractorFunc(extractImage),
+	shared.JPEGContentType: ExtractorFunc(extractImage),
+	shared.GIFContentType:  ExtractorFunc(extractImage),
+	shared.BMPContentType:  ExtractorFunc(extractImage),
+	shared.TIFFContentType: ExtractorFunc(extractImage),
+	shared.ZipContentType:  ExtractorFunc(extractArchive),
+	shared.RARContentType:  ExtractorFunc(extractArchive),
+}
+
+// Register sets the extractor of a MIME type, replacing the built-in one.
+func Register(contentType string, extractor Extractor) {
+	extractorsMutex.Lock()
+	defer extractorsMutex.Unlock()
+	extractors[contentType] = extractor
+}
+
+// ExtractorFor returns the extractor registered for a MIME type.
+func ExtractorFor(contentType string) (Extractor, bool) {
+	extractorsMutex.RLock()
+	defer extractorsMutex.RUnlock()
+	extractor, found := extractors[contentType]
+	return extractor, found
+}
+
+// Extract gets the text of a document with the extractor of its MIME type.
+// When the type is empty it is detected from the content. The extraction is
+// limited to options.DocumentTimeout.
+func Extract(contentType string, r io.Reader, options *Options) (*Result, error) {
+	if contentType == "" {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		contentType = shared.DetectContentType(content)
+		r = bytes.NewReader(content)
+	}
+	extractor, found := ExtractorFor(contentType)
+	if !found {
+		log.WithFields(log.Fields{
+			"contentType": contentType,
+		}).Warn("no extractor for document type")
+		return nil, ErrUnsupportedType
+	}
+	ctx := context.Background()
+	if options.DocumentTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.DocumentTimeout)
+		defer cancel()
+	}
+	return extractor.Extract(ctx, r, options)
+}
@@ -0,0 +1,345 @@
+package extracttext
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"github.com/odia/juscaba/shared"
+	log "github.com/sirupsen/logrus"
+)
+
+const wordNamespace = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+const odtTextNamespace = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+
+// textResult splits a text on form feeds into text layer pages.
+func textResult(text string) *Result {
+	pages := make([]shared.Page, 0)
+	for i, t := range splitPages(text) {
+		pages = append(pages, shared.Page{
+			Number: i + 1,
+			Text:   t,
+			Source: shared.TextLayerSource,
+		})
+	}
+	return &Result{Pages: pages}
+}
+
+// windows1252 are the characters of the bytes 0x80 to 0x9f, where it differs
+// from latin 1.
+var windows1252 = [32]rune{
+	'€', '�', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '�', 'Ž', '�',
+	'�', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '�', 'ž', 'Ÿ',
+}
+
+func windows1252Rune(b byte) rune {
+	if b >= 0x80 && b < 0xa0 {
+		return windows1252[b-0x80]
+	}
+	return rune(b)
+}
+
+// decodeText returns the content as utf-8, reading it as windows-1252 when it
+// is not valid utf-8.
+func decodeText(content []byte) string {
+	if utf8.Valid(content) {
+		return string(content)
+	}
+	var b strings.Builder
+	for _, c := range content {
+		b.WriteRune(windows1252Rune(c))
+	}
+	return b.String()
+}
+
+func readZipEntry(content []byte, name string) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range r.File {
+		if f.Name != name {
+			continue
+		}
+		fp, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+		return io.ReadAll(fp)
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// readOfficeEntry reads the xml file with the body of an office document.
+func readOfficeEntry(r io.Reader, name string) ([]byte, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := readZipEntry(content, name)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"entry": name,
+			"error": err.Error(),
+		}).Error("failed to read office document")
+	}
+	return entry, err
+}
+
+// docxText reads word/document.xml. Explicit and last rendered page breaks
+// split the pages.
+func docxText(content []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	var b strings.Builder
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteString("\t")
+			case "cr":
+				b.WriteString("\n")
+			case "br":
+				if attr(t, "type") == "page" {
+					b.WriteString("\f")
+				} else {
+					b.WriteString("\n")
+				}
+			case "lastRenderedPageBreak":
+				b.WriteString("\f")
+			}
+		case xml.EndElement:
+			if t.Name.Space != wordNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+func extractDOCX(ctx context.Context, r io.Reader, options *Options) (*Result, error) {
+	content, err := readOfficeEntry(r, "word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	text, err := docxText(content)
+	if err != nil {
+		return nil, err
+	}
+	return textResult(text), nil
+}
+
+// odtText reads the paragraphs and headings of content.xml. Soft page breaks
+// split the pages.
+func odtText(content []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	var b strings.Builder
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space != odtTextNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "p", "h":
+				depth++
+			case "s":
+				count, err := strconv.Atoi(attr(t, "c"))
+				if err != nil || count < 1 {
+					count = 1
+				}
+				b.WriteString(strings.Repeat(" ", count))
+			case "tab":
+				b.WriteString("\t")
+			case "line-break":
+				b.WriteString("\n")
+			case "soft-page-break":
+				b.WriteString("\f")
+			}
+		case xml.EndElement:
+			if t.Name.Space == odtTextNamespace && (t.Name.Local == "p" || t.Name.Local == "h") {
+				depth--
+				if depth == 0 {
+					b.WriteString("\n")
+				}
+			}
+		case xml.CharData:
+			if depth > 0 {
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+func extractODT(ctx context.Context, r io.Reader, options *Options) (*Result, error) {
+	content, err := readOfficeEntry(r, "content.xml")
+	if err != nil {
+		return nil, err
+	}
+	text, err := odtText(content)
+	if err != nil {
+		return nil, err
+	}
+	return textResult(text), nil
+}
+
+var htmlSkippedRegexp = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)\s*>|<!--.*?-->`)
+var htmlBlocks = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "br": true, "dd": true,
+	"div": true, "dl": true, "dt": true, "footer": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "header": true, "hr": true, "li": true, "main": true,
+	"nav": true, "ol": true, "p": true, "pre": true, "section": true, "table": true, "tr": true,
+	"ul": true,
+}
+var spacesRegexp = regexp.MustCompile(`[ \t\r\n\f\v]+`)
+var blankLinesRegexp = regexp.MustCompile(` *\n[ \n]*`)
+
+// htmlText returns the visible text of an html document, a line per block.
+func htmlText(content string) string {
+	decoder := newLayoutDecoder(strings.NewReader(htmlSkippedRegexp.ReplaceAllString(content, "")))
+	var b strings.Builder
+	skip := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err != io.EOF {
+				log.WithFields(log.Fields{
+					"error": err.Error(),
+				}).Warn("failed to parse the whole html document")
+			}
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			switch {
+			case name == "head" || name == "title":
+				skip++
+			case name == "td" || name == "th":
+				b.WriteString("\t")
+			case htmlBlocks[name]:
+				b.WriteString("\n")
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			switch {
+			case name == "head" || name == "title":
+				if skip > 0 {
+					skip--
+				}
+			case htmlBlocks[name]:
+				b.WriteString("\n")
+			}
+		case xml.CharData:
+			if skip == 0 {
+				b.WriteString(spacesRegexp.ReplaceAllString(string(t), " "))
+			}
+		}
+	}
+	lines := strings.Split(blankLinesRegexp.ReplaceAllString(b.String(), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func extractHTML(ctx context.Context, r io.Reader, options *Options) (*Result, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return textResult(htmlText(decodeText(content))), nil
+}
+
+func extractPlainText(ctx context.Context, r io.Reader, options *Options) (*Result, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return textResult(decodeText(content)), nil
+}
+
+// extractImage ocrs an image, every page of it for multi page tiffs.
+func extractImage(ctx context.Context, r io.Reader, options *Options) (*Result, error) {
+	if !options.Images && !options.Codes {
+		log.Info("skipping image text")
+		return &Result{Pages: make([]shared.Page, 0)}, nil
+	}
+	dir, p, err := writeToTempFile(r)
+	defer os.RemoveAll(dir)
+	if err != nil {
+		return nil, err
+	}
+	var codes []shared.Code
+	if options.Codes {
+		codes = imageCodes(ctx, options, p)
+	}
+	if !options.Images {
+		return &Result{Pages: make([]shared.Page, 0), Codes: codes}, nil
+	}
+	image, err := preprocessImage(p, options)
+	if err != nil {
+		// formats the image package does not decode are ocr'd as they are
+		image = p
+	}
+	text, layout, err := runTesseract(ctx, options, image)
+	if err != nil {
+		return nil, err
+	}
+	result := textResult(text)
+	result.Codes = codes
+	for i := range result.Pages {
+		result.Pages[i].Source = shared.OCRSource
+		if i < len(layout.Pages) {
+			layout.Pages[i].Number = i + 1
+			result.Pages[i].Confidence = layout.Pages[i].Confidence()
+		}
+	}
+	if options.Layout != "" {
+		result.Layout = layout
+	}
+	return result, nil
+}
@@ -0,0 +1,132 @@
+package extracttext
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/odia/juscaba/shared"
+)
+
+func TestExtractFormats(t *testing.T) {
+	docx := buildZip(t, []zipMember{
+		{name: "[Content_Types].xml", content: `<Types/>`},
+		{name: "word/document.xml", content: `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>Autos</w:t></w:r><w:r><w:tab/><w:t xml:space="preserve"> y vistos</w:t></w:r></w:p>
+<w:p><w:r><w:t>Línea</w:t><w:br/><w:t>dos</w:t><w:cr/><w:t>tres</w:t></w:r></w:p>
+<w:p><w:r><w:br w:type="page"/><w:t>Segunda página</w:t></w:r></w:p>
+<w:p><w:del><w:r><w:delText>borrado</w:delText></w:r></w:del><w:r><w:lastRenderedPageBreak/><w:t>Tercera &amp; última</w:t></w:r></w:p>
+</w:body></w:document>`},
+	})
+	odt := buildZip(t, []zipMember{
+		{name: "mimetype", content: shared.ODTContentType},
+		{name: "content.xml", content: `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+<office:body><office:text>
+<text:sequence-decls><text:sequence-decl text:name="Tabla"/></text:sequence-decls>
+<text:h text:outline-level="1">Sentencia</text:h>
+<text:p>Uno<text:s text:c="3"/>dos<text:tab/>tres<text:line-break/>cuatro<text:s/>cinco</text:p>
+<text:p><text:span>anidado <text:a>enlace</text:a></text:span></text:p>
+<text:p><text:soft-page-break/>Segunda</text:p>
+</office:text></office:body></office:document-content>`},
+	})
+	rtf := strings.Join([]string{
+		`{\rtf1\ansi\ansicpg1252\deff0{\fonttbl{\f0\froman Times New Roman;}}`,
+		`{\colortbl;\red0\green0\blue0;}{\*\generator Microsoft Word;}{\info{\title T\'edtulo}}`,
+		`\pard Se\'f1or \'93Juez\'94\par`,
+		`\uc1\u8220?cita\u8221?\par`,
+		`{\uc2\u8364 XXeuros}\par`,
+		`\u241\'f1o \u-3913?\par`,
+		`{\*\bkmkstart x}texto{\*\bkmkend x}\tab col\par`,
+		`{\field{\*\fldinst HYPERLINK "http://example.com"}{\fldrslt enlace}}\par`,
+		`\page Segunda\~p\'e1gina \{llaves\}\par}`,
+	}, "\n")
+	html := `<!DOCTYPE html>
+<html><head><title>Título</title><style>p { color: red }</style></head><body>
+<h1>Sentencia</h1><!-- <p>comentario</p> -->
+<p>Autos   y
+  vistos&nbsp;&amp; otros</p><script>var x = "<p>no</p>";</script>
+<table><tr><td>Capital</td><td>1.000</td></tr><tr><th>Costas</th><td>80</td></tr></table>
+<p>Fin<br>del <b>texto</b></p></body></html>`
+
+	tests := []struct {
+		name        string
+		contentType string
+		content     []byte
+		want        []string
+	}{
+		{
+			"docx", shared.DOCXContentType, docx,
+			[]string{"Autos\t y vistos\nLínea\ndos\ntres\n", "Segunda página\n", "Tercera & última\n"},
+		},
+		{
+			"odt", shared.ODTContentType, odt,
+			[]string{"Sentencia\nUno   dos\ttres\ncuatro cinco\nanidado enlace\n", "Segunda\n"},
+		},
+		{
+			"rtf", shared.RTFContentType, []byte(rtf),
+			[]string{"Señor “Juez”\n“cita”\n€euros\nño \uf0b7\ntexto\tcol\nenlace\n", "Segunda\u00a0página {llaves}\n"},
+		},
+		{
+			"html", shared.HTMLContentType, []byte(html),
+			[]string{"Sentencia\nAutos y vistos\u00a0& otros\nCapital\t1.000\nCostas\t80\nFin\ndel texto"},
+		},
+		{
+			"windows-1252 html", shared.HTMLContentType, []byte("<html><body><p>Se\xf1or \x93Juez\x94</p></body></html>"),
+			[]string{"Señor “Juez”"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// with the type declared and detected from the content
+			for _, contentType := range []string{test.contentType, ""} {
+				result, err := Extract(contentType, bytes.NewReader(test.content), testOptions())
+				if err != nil {
+					t.Fatalf("Extract(%q) error = %v", contentType, err)
+				}
+				got := make([]string, len(result.Pages))
+				for i, page := range result.Pages {
+					got[i] = page.Text
+					if page.Number != i+1 || page.Source != shared.TextLayerSource {
+						t.Errorf("page %d = number %d source %q", i, page.Number, page.Source)
+					}
+				}
+				if !reflect.DeepEqual(got, test.want) {
+					t.Errorf("Extract(%q) pages = %q, want %q", contentType, got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRTFText(t *testing.T) {
+	tests := []struct {
+		name string
+		rtf  string
+		want string
+	}{
+		{"hex escape", `{\rtf1 a\'e1\'80b}`, "aá€b"},
+		{"hex escape at the end", `{\rtf1 a\'e`, "a"},
+		{"unicode with the default fallback", `{\rtf1 \u225?b}`, "áb"},
+		{"unicode with a hex escape fallback", `{\rtf1 \u225\'e1b}`, "áb"},
+		{"unicode without fallback", `{\rtf1\uc0 \u225 b}`, "áb"},
+		{"longer fallback", `{\rtf1\uc3 \u225 abcd}`, "ád"},
+		{"fallback ends with the group", `{\rtf1{\uc3 \u225 a}b}`, "áb"},
+		{"fallback count is restored after the group", `{\rtf1{\uc3 x}\u225?b}`, "xáb"},
+		{"negative unicode", `{\rtf1 \u-3913?}`, "\uf0b7"},
+		{"skipped destination", `{\rtf1 a{\*\unknown {nested} text}b}`, "ab"},
+		{"skipped known destination", `{\rtf1 a{\fonttbl{\f0 Arial;}}b}`, "ab"},
+		{"control symbols", `{\rtf1 a\~b\_c\\d\{e\}}`, "a\u00a0b-c\\d{e}"},
+		{"line breaks are not text", "{\\rtf1 a\r\nb\\\nc}", "ab\nc"},
+		{"control word delimiter", `{\rtf1\b bold\b0 normal}`, "boldnormal"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := rtfText([]byte(test.rtf)); got != test.want {
+				t.Errorf("rtfText(%q) = %q, want %q", test.rtf, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,388 @@
+package extracttext
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const HOCRLayout = "hocr"
+const ALTOLayout = "alto"
+
+// BBox is a rectangle in pixels of the rendered page, from the top left
+// corner (X0, Y0) to the bottom right one (X1, Y1).
+type BBox struct {
+	X0 int `json:"x0"`
+	Y0 int `json:"y0"`
+	X1 int `json:"x1"`
+	Y1 int `json:"y1"`
+}
+
+type LayoutWord struct {
+	Text string `json:"text"`
+	BBox BBox   `json:"bbox"`
+	// Confidence is tesseract's confidence in the word, from 0 to 100.
+	Confidence float64 `json:"confidence"`
+}
+
+type LayoutLine struct {
+	BBox  BBox         `json:"bbox"`
+	Words []LayoutWord `json:"words"`
+}
+
+type LayoutPage struct {
+	Number int          `json:"number"`
+	Width  int          `json:"width"`
+	Height int          `json:"height"`
+	Lines  []LayoutLine `json:"lines"`
+}
+
+// Layout has the position of every word ocr'd in a document. Only the pages
+// that were ocr'd are included.
+type Layout struct {
+	Pages []LayoutPage `json:"pages"`
+}
+
+// LayoutExtension returns the file extension of a layout format.
+func LayoutExtension(format string) string {
+	if format == ALTOLayout {
+		return ".alto.xml"
+	}
+	return ".hocr"
+}
+
+func hasClass(class, name string) bool {
+	for _, c := range strings.Fields(class) {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isLineClass(class string) bool {
+	for _, name := range []string{"ocr_line", "ocrx_line", "ocr_caption", "ocr_textfloat", "ocr_header"} {
+		if hasClass(class, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTitle reads the properties of an hOCR title, e.g.
+// "bbox 10 20 30 40; x_wconf 93".
+func parseTitle(title string) map[string][]string {
+	properties := map[string][]string{}
+	for _, property := range strings.Split(title, ";") {
+		fields := strings.Fields(property)
+		if len(fields) > 0 {
+			properties[fields[0]] = fields[1:]
+		}
+	}
+	return properties
+}
+
+func parseBBox(values []string) BBox {
+	coords := [4]int{}
+	for i := 0; i < 4 && i < len(values); i++ {
+		coords[i], _ = strconv.Atoi(values[i])
+	}
+	return BBox{X0: coords[0], Y0: coords[1], X1: coords[2], Y1: coords[3]}
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func newLayoutDecoder(r io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	return decoder
+}
+
+// ParseHOCR reads the pages, lines and words of an hOCR document.
+func ParseHOCR(r io.Reader) (*Layout, error) {
+	decoder := newLayoutDecoder(r)
+	layout := &Layout{Pages: make([]LayoutPage, 0)}
+	var page *LayoutPage
+	var line *LayoutLine
+	var word *LayoutWord
+	classes := make([]string, 0)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			class := attr(t, "class")
+			classes = append(classes, class)
+			properties := parseTitle(attr(t, "title"))
+			switch {
+			case hasClass(class, "ocr_page"):
+				bbox := parseBBox(properties["bbox"])
+				number := len(layout.Pages) + 1
+				if ppageno, found := properties["ppageno"]; found && len(ppageno) > 0 {
+					if n, err := strconv.Atoi(ppageno[0]); err == nil {
+						number = n + 1
+					}
+				}
+				layout.Pages = append(layout.Pages, LayoutPage{
+					Number: number,
+					Width:  bbox.X1 - bbox.X0,
+					Height: bbox.Y1 - bbox.Y0,
+					Lines:  make([]LayoutLine, 0),
+				})
+				page = &layout.Pages[len(layout.Pages)-1]
+			case isLineClass(class) && page != nil:
+				page.Lines = append(page.Lines, LayoutLine{
+					BBox:  parseBBox(properties["bbox"]),
+					Words: make([]LayoutWord, 0),
+				})
+				line = &page.Lines[len(page.Lines)-1]
+			case hasClass(class, "ocrx_word") && line != nil:
+				confidence := 0.0
+				if values, found := properties["x_wconf"]; found && len(values) > 0 {
+					confidence, _ = strconv.ParseFloat(values[0], 64)
+				}
+				line.Words = append(line.Words, LayoutWord{
+					BBox:       parseBBox(properties["bbox"]),
+					Confidence: confidence,
+				})
+				word = &line.Words[len(line.Words)-1]
+			}
+		case xml.EndElement:
+			if len(classes) == 0 {
+				continue
+			}
+			class := classes[len(classes)-1]
+			classes = classes[:len(classes)-1]
+			switch {
+			case hasClass(class, "ocr_page"):
+				page, line, word = nil, nil, nil
+			case isLineClass(class):
+				line, word = nil, nil
+			case hasClass(class, "ocrx_word"):
+				word = nil
+			}
+		case xml.CharData:
+			if word != nil {
+				word.Text += string(t)
+			}
+		}
+	}
+	return layout, nil
+}
+
+func altoInt(e xml.StartElement, name string) int {
+	v, _ := strconv.ParseFloat(attr(e, name), 64)
+	return int(v)
+}
+
+func altoBBox(e xml.StartElement) BBox {
+	x, y := altoInt(e, "HPOS"), altoInt(e, "VPOS")
+	return BBox{X0: x, Y0: y, X1: x + altoInt(e, "WIDTH"), Y1: y + altoInt(e, "HEIGHT")}
+}
+
+// ParseALTO reads the pages, lines and words of an ALTO document measured
+// in pixels.
+func ParseALTO(r io.Reader) (*Layout, error) {
+	decoder := newLayoutDecoder(r)
+	layout := &Layout{Pages: make([]LayoutPage, 0)}
+	var page *LayoutPage
+	var line *LayoutLine
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "Page":
+				number := altoInt(t, "PHYSICAL_IMG_NR")
+				if number == 0 {
+					number = len(layout.Pages) + 1
+				}
+				layout.Pages = append(layout.Pages, LayoutPage{
+					Number: number,
+					Width:  altoInt(t, "WIDTH"),
+					Height: altoInt(t, "HEIGHT"),
+					Lines:  make([]LayoutLine, 0),
+				})
+				page = &layout.Pages[len(layout.Pages)-1]
+			case "TextLine":
+				if page == nil {
+					continue
+				}
+				page.Lines = append(page.Lines, LayoutLine{
+					BBox:  altoBBox(t),
+					Words: make([]LayoutWord, 0),
+				})
+				line = &page.Lines[len(page.Lines)-1]
+			case "String":
+				if line == nil {
+					continue
+				}
+				// WC goes from 0 to 1 with two decimals
+				confidence, _ := strconv.ParseFloat(attr(t, "WC"), 64)
+				line.Words = append(line.Words, LayoutWord{
+					Text:       attr(t, "CONTENT"),
+					BBox:       altoBBox(t),
+					Confidence: math.Round(confidence * 100),
+				})
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "Page":
+				page, line = nil, nil
+			case "TextLine":
+				line = nil
+			}
+		}
+	}
+	return layout, nil
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// HOCR serializes the layout as an hOCR document.
+func (layout *Layout) HOCR() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="es" lang="es">
+ <head>
+  <title></title>
+  <meta http-equiv="Content-Type" content="text/html;charset=utf-8"/>
+  <meta name="ocr-system" content="tesseract"/>
+  <meta name="ocr-capabilities" content="ocr_page ocr_line ocrx_word"/>
+ </head>
+ <body>
+`)
+	for _, page := range layout.Pages {
+		fmt.Fprintf(&buf, "  <div class=\"ocr_page\" id=\"page_%d\" title=\"bbox 0 0 %d %d; ppageno %d\">\n",
+			page.Number, page.Width, page.Height, page.Number-1)
+		for i, line := range page.Lines {
+			b := line.BBox
+			fmt.Fprintf(&buf, "   <span class=\"ocr_line\" id=\"line_%d_%d\" title=\"bbox %d %d %d %d\">",
+				page.Number, i+1, b.X0, b.Y0, b.X1, b.Y1)
+			for j, word := range line.Words {
+				b := word.BBox
+				if j > 0 {
+					buf.WriteString(" ")
+				}
+				fmt.Fprintf(&buf, "<span class=\"ocrx_word\" id=\"word_%d_%d_%d\" title=\"bbox %d %d %d %d; x_wconf %.0f\">%s</span>",
+					page.Number, i+1, j+1, b.X0, b.Y0, b.X1, b.Y1, word.Confidence, escape(word.Text))
+			}
+			buf.WriteString("</span>\n")
+		}
+		buf.WriteString("  </div>\n")
+	}
+	buf.WriteString(" </body>\n</html>\n")
+	return buf.Bytes()
+}
+
+// ALTO serializes the layout as an ALTO v4 document.
+func (layout *Layout) ALTO() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<alto xmlns="http://www.loc.gov/standards/alto/ns-v4#" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.loc.gov/standards/alto/ns-v4# http://www.loc.gov/alto/v4/alto-4-2.xsd">
+ <Description>
+  <MeasurementUnit>pixel</MeasurementUnit>
+  <OCRProcessing ID="ocr_0">
+   <ocrProcessingStep>
+    <processingSoftware>
+     <softwareName>tesseract</softwareName>
+    </processingSoftware>
+   </ocrProcessingStep>
+  </OCRProcessing>
+ </Description>
+ <Layout>
+`)
+	for _, page := range layout.Pages {
+		fmt.Fprintf(&buf, "  <Page ID=\"page_%d\" PHYSICAL_IMG_NR=\"%d\" WIDTH=\"%d\" HEIGHT=\"%d\">\n",
+			page.Number, page.Number, page.Width, page.Height)
+		fmt.Fprintf(&buf, "   <PrintSpace HPOS=\"0\" VPOS=\"0\" WIDTH=\"%d\" HEIGHT=\"%d\">\n", page.Width, page.Height)
+		fmt.Fprintf(&buf, "    <TextBlock ID=\"block_%d\">\n", page.Number)
+		for i, line := range page.Lines {
+			b := line.BBox
+			fmt.Fprintf(&buf, "     <TextLine ID=\"line_%d_%d\" HPOS=\"%d\" VPOS=\"%d\" WIDTH=\"%d\" HEIGHT=\"%d\">\n",
+				page.Number, i+1, b.X0, b.Y0, b.X1-b.X0, b.Y1-b.Y0)
+			for j, word := range line.Words {
+				b := word.BBox
+				if j > 0 {
+					buf.WriteString("      <SP/>\n")
+				}
+				fmt.Fprintf(&buf, "      <String ID=\"word_%d_%d_%d\" CONTENT=\"%s\" HPOS=\"%d\" VPOS=\"%d\" WIDTH=\"%d\" HEIGHT=\"%d\" WC=\"%.2f\"/>\n",
+					page.Number, i+1, j+1, escape(word.Text), b.X0, b.Y0, b.X1-b.X0, b.Y1-b.Y0, word.Confidence/100)
+			}
+			buf.WriteString("     </TextLine>\n")
+		}
+		buf.WriteString("    </TextBlock>\n   </PrintSpace>\n  </Page>\n")
+	}
+	buf.WriteString(" </Layout>\n</alto>\n")
+	return buf.Bytes()
+}
+
+// Encode serializes the layout in the given format.
+func (layout *Layout) Encode(format string) []byte {
+	if format == ALTOLayout {
+		return layout.ALTO()
+	}
+	return layout.HOCR()
+}
+
+// Text returns the words of the page, a line of text per layout line.
+func (page *LayoutPage) Text() string {
+	lines := make([]string, len(page.Lines))
+	for i, line := range page.Lines {
+		words := make([]string, len(line.Words))
+		for j, word := range line.Words {
+			words[j] = word.Text
+		}
+		lines[i] = strings.Join(words, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Confidence is the mean confidence of the words of the page, or 0 if it has
+// no words.
+func (page *LayoutPage) Confidence() float64 {
+	total := 0.0
+	count := 0
+	for _, line := range page.Lines {
+		for _, word := range line.Words {
+			if strings.TrimSpace(word.Text) == "" {
+				continue
+			}
+			total += word.Confidence
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
@@ -0,0 +1,114 @@
+package extracttext
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testLayout has pages that are not consecutive, an empty page, lines with
+// several words and text that has to be escaped.
+var testLayout = &Layout{Pages: []LayoutPage{
+	{Number: 1, Width: 2480, Height: 3508, Lines: []LayoutLine{
+		{BBox: BBox{200, 300, 1400, 360}, Words: []LayoutWord{
+			{Text: "Buenos", BBox: BBox{200, 300, 420, 360}, Confidence: 96},
+			{Text: "Aires,", BBox: BBox{440, 302, 640, 358}, Confidence: 91},
+			{Text: "Año", BBox: BBox{660, 300, 800, 360}, Confidence: 88},
+		}},
+		{BBox: BBox{200, 400, 900, 455}, Words: []LayoutWord{
+			{Text: "\"Perez", BBox: BBox{200, 400, 420, 455}, Confidence: 73},
+			{Text: "c/", BBox: BBox{440, 400, 500, 455}, Confidence: 57},
+			{Text: "GCBA<>&", BBox: BBox{520, 400, 900, 455}, Confidence: 7},
+		}},
+	}},
+	{Number: 3, Width: 1240, Height: 1754, Lines: []LayoutLine{
+		{BBox: BBox{100, 120, 300, 160}, Words: []LayoutWord{
+			{Text: "Notifíquese.", BBox: BBox{100, 120, 300, 160}, Confidence: 99},
+		}},
+	}},
+	{Number: 4, Width: 1240, Height: 1754, Lines: []LayoutLine{}},
+}}
+
+func TestLayoutRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		encode func(l *Layout) []byte
+		parse  func(b []byte) (*Layout, error)
+	}{
+		{"hocr", (*Layout).HOCR, func(b []byte) (*Layout, error) { return ParseHOCR(bytes.NewReader(b)) }},
+		{"alto", (*Layout).ALTO, func(b []byte) (*Layout, error) { return ParseALTO(bytes.NewReader(b)) }},
+		{"hocr to alto", (*Layout).HOCR, func(b []byte) (*Layout, error) {
+			l, err := ParseHOCR(bytes.NewReader(b))
+			if err != nil {
+				return nil, err
+			}
+			return ParseALTO(bytes.NewReader(l.ALTO()))
+		}},
+		{"alto to hocr", (*Layout).ALTO, func(b []byte) (*Layout, error) {
+			l, err := ParseALTO(bytes.NewReader(b))
+			if err != nil {
+				return nil, err
+			}
+			return ParseHOCR(bytes.NewReader(l.HOCR()))
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.parse(test.encode(testLayout))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, testLayout) {
+				t.Errorf("round trip = %+v, want %+v", got, testLayout)
+			}
+		})
+	}
+}
+
+// TestParseHOCRTesseract reads the nesting and attributes tesseract writes:
+// areas and paragraphs around the lines and tags inside the words.
+func TestParseHOCRTesseract(t *testing.T) {
+	hocr := strings.Join([]string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<html><body>`,
+		`<div class='ocr_page' id='page_1' title='image "p.png"; bbox 0 0 1000 2000; ppageno 1'>`,
+		` <div class='ocr_carea' title="bbox 10 10 500 100">`,
+		`  <p class='ocr_par' lang='spa' title="bbox 10 10 500 100">`,
+		`   <span class='ocr_line' title="bbox 10 10 500 50; baseline 0 -5; x_size 40">`,
+		`    <span class='ocrx_word' title='bbox 10 10 200 50; x_wconf 95'><strong>Autos</strong></span>`,
+		`    <span class='ocrx_word' title='bbox 220 10 500 50; x_wconf 87'>y&nbsp;vistos</span>`,
+		`   </span>`,
+		`   <span class='ocr_caption' title="bbox 10 60 300 100">`,
+		`    <span class='ocrx_word' title='bbox 10 60 300 100; x_wconf 12'>&amp;</span>`,
+		`   </span>`,
+		`  </p>`,
+		` </div>`,
+		`</div>`,
+		`</body></html>`,
+	}, "\n")
+	got, err := ParseHOCR(strings.NewReader(hocr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Layout{Pages: []LayoutPage{
+		{Number: 2, Width: 1000, Height: 2000, Lines: []LayoutLine{
+			{BBox: BBox{10, 10, 500, 50}, Words: []LayoutWord{
+				{Text: "Autos", BBox: BBox{10, 10, 200, 50}, Confidence: 95},
+				{Text: "y\u00a0vistos", BBox: BBox{220, 10, 500, 50}, Confidence: 87},
+			}},
+			{BBox: BBox{10, 60, 300, 100}, Words: []LayoutWord{
+				{Text: "&", BBox: BBox{10, 60, 300, 100}, Confidence: 12},
+			}},
+		}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseHOCR() = %+v, want %+v", got, want)
+	}
+	if text := got.Pages[0].Text(); text != "Autos y\u00a0vistos\n&" {
+		t.Errorf("Text() = %q", text)
+	}
+	if confidence := got.Pages[0].Confidence(); confidence != (95.0+87+12)/3 {
+		t.Errorf("Confidence() = %v", confidence)
+	}
+}
@@ -1,17 +1,27 @@
-package crawler
+package extracttext
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode"
 
+	"github.com/odia/juscaba/shared"
 	log "github.com/sirupsen/logrus"
 )
 
+// ErrIncomplete is returned along with the pages when the document ran out
+// of time before every page could be ocr'd.
+var ErrIncomplete = errors.New("document extraction is incomplete")
+
 func writeToTempFile(r io.Reader) (string, string, error) {
 	dir, err := ioutil.TempDir("", "extracttext")
 	if err != nil {
@@ -39,174 +49,448 @@ func writeToTempFile(r io.Reader) (string, string, error) {
 	return dir, p, nil
 }
 
-func getDocumentPlainText(p string) (string, error) {
-	cmd := exec.Command("pdftotext", p, "-")
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err.Error(),
-		}).Error("failed to pipe pdftotext")
-		return "", err
+// pdftohtml writes the images of a page to dir.
+func pdftohtml(ctx context.Context, options *Options, p, dir string, page int) error {
+	_, err := runCommand(ctx, options.CommandTimeout, nil, "pdftohtml",
+		"-c", "-f", strconv.Itoa(page), "-l", strconv.Itoa(page),
+		p, path.Join(dir, "content"),
+	)
+	return err
+}
+
+// pdftoppm renders a page to dir/page.png.
+func pdftoppm(ctx context.Context, options *Options, p, dir string, page int) (string, error) {
+	args := []string{
+		"-f", strconv.Itoa(page), "-l", strconv.Itoa(page),
+		"-r", strconv.Itoa(options.DPI),
+		"-png", "-singlefile",
 	}
-	stdout, err := cmd.StdoutPipe()
+	if options.Grayscale {
+		args = append(args, "-gray")
+	}
+	out := path.Join(dir, "page")
+	_, err := runCommand(ctx, options.CommandTimeout, nil, "pdftoppm", append(args, p, out)...)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err.Error(),
-		}).Error("failed to pipe pdftotext")
 		return "", err
 	}
+	return out + ".png", nil
+}
 
-	err = cmd.Start()
+// runTesseract ocrs an image, that may have several pages, and returns its
+// text along with the position and confidence of every word.
+func runTesseract(ctx context.Context, options *Options, filename string) (string, *Layout, error) {
+	// tesseract is already run in parallel, one thread per process avoids
+	// oversubscribing the cpus.
+	out := strings.TrimSuffix(filename, path.Ext(filename)) + "-ocr"
+	args := append([]string{filename, out}, options.Tesseract.args()...)
+	_, err := runCommand(ctx, options.CommandTimeout, []string{"OMP_THREAD_LIMIT=1"}, "tesseract", append(args, "txt", "hocr")...)
 	if err != nil {
-		stderrBytes, _ := ioutil.ReadAll(stderr)
-		log.WithFields(log.Fields{
-			"stderr": string(stderrBytes),
-			"error":  err.Error(),
-		}).Error("failed to run pdftotext")
-		return "", err
+		return "", nil, err
 	}
-
-	stdoutBytes, err := ioutil.ReadAll(stdout)
+	text, err := ioutil.ReadFile(out + ".txt")
 	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err.Error(),
-		}).Error("failed to read pdftotext's output")
-		return "", err
+		return "", nil, err
 	}
-	err = cmd.Wait()
+	fp, err := os.Open(out + ".hocr")
 	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err.Error(),
-		}).Error("failed to wait for pdftotext")
-		return "", err
+		return "", nil, err
 	}
-	return string(stdoutBytes), nil
-}
-
-func pdftohtml(p string) error {
-	cmd := exec.Command("pdftohtml", "-c", p)
-	stderr, err := cmd.StderrPipe()
+	defer fp.Close()
+	layout, err := ParseHOCR(fp)
 	if err != nil {
 		log.WithFields(log.Fields{
+			"image": filename,
 			"error": err.Error(),
-		}).Error("failed to pipe pdftohtml")
-		return err
+		}).Error("failed to parse hocr")
+		return "", nil, err
 	}
+	return string(text), layout, nil
+}
 
-	err = cmd.Start()
+// readImageLayout ocrs a single page image.
+func readImageLayout(ctx context.Context, options *Options, filename string) (string, *LayoutPage, error) {
+	text, layout, err := runTesseract(ctx, options, filename)
 	if err != nil {
-		stderrBytes, _ := ioutil.ReadAll(stderr)
-		log.WithFields(log.Fields{
-			"stderr": string(stderrBytes),
-			"error":  err.Error(),
-		}).Error("failed to run pdftohtml")
-		return err
+		return "", nil, err
 	}
-
-	err = cmd.Wait()
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err.Error(),
-		}).Error("failed to wait for pdftohtml")
-		return err
+	if len(layout.Pages) == 0 {
+		return text, &LayoutPage{Lines: make([]LayoutLine, 0)}, nil
 	}
-	return nil
+	return text, &layout.Pages[0], nil
 }
 
-func readImageText(filename string) (string, error) {
-	cmd := exec.Command("tesseract", "-l", "spa", filename, "-")
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err.Error(),
-		}).Error("failed to pipe tesseract")
-		return "", err
+// ocrResult is the text of an ocr'd page, the mean confidence of its words and,
+// when options.Layout is set, their position.
+type ocrResult struct {
+	text       string
+	confidence float64
+	layout     *LayoutPage
+}
+
+var imagePageRegexp = regexp.MustCompile(`^content(?:-(\d+)_\d+|(\d+))\.(?:jpg|png)$`)
+
+// imagePage returns the page of an image written by pdftohtml, named
+// content-<page>_<n>.jpg or, for page backgrounds, content<page>.png.
+func imagePage(filename string) int {
+	match := imagePageRegexp.FindStringSubmatch(filename)
+	if match == nil {
+		return 1
 	}
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err.Error(),
-		}).Error("failed to pipe tesseract")
-		return "", err
+	number := match[1]
+	if number == "" {
+		number = match[2]
 	}
-
-	err = cmd.Start()
-	if err != nil {
-		stderrBytes, _ := ioutil.ReadAll(stderr)
-		log.WithFields(log.Fields{
-			"stderr": string(stderrBytes),
-			"error":  err.Error(),
-		}).Error("failed to run tesseract")
-		return "", err
+	page, err := strconv.Atoi(number)
+	if err != nil || page < 1 {
+		return 1
 	}
+	return page
+}
 
-	stdoutBytes, err := ioutil.ReadAll(stdout)
+// getPageRasterText ocrs the image of a page rendered by pdftoppm.
+func getPageRasterText(ctx context.Context, options *Options, image string, number int) (*ocrResult, error) {
+	image, err := preprocessImage(image, options)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err.Error(),
-		}).Error("failed to read tesseract's output")
-		return "", err
+		return nil, err
 	}
-	err = cmd.Wait()
+	text, layout, err := readImageLayout(ctx, options, image)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err.Error(),
-		}).Error("failed to wait for tesseract")
-		return "", err
+		return nil, err
+	}
+	layout.Number = number
+	res := &ocrResult{text: text, confidence: layout.Confidence()}
+	if options.Layout != "" {
+		res.layout = layout
 	}
-	return string(stdoutBytes), nil
+	return res, nil
 }
 
-func getDocumentImagesText(dir, p string) (string, error) {
+// isPageBackground tells whether an image written by pdftohtml is the
+// background of a page rather than an image embedded in it.
+func isPageBackground(filename string) bool {
+	match := imagePageRegexp.FindStringSubmatch(filename)
+	return match != nil && match[2] != ""
+}
+
+// getPageImagesText ocrs the images pdftohtml extracts from the page. The
+// confidence is the mean of the words of every image. Embedded images the
+// filter skips are not ocr'd, page backgrounds always are.
+func getPageImagesText(ctx context.Context, options *Options, filter *imageFilter, pageDir, p string, number int) (*ocrResult, error) {
 	log.WithFields(log.Fields{
-		"directory": dir,
+		"directory": pageDir,
 		"path":      p,
+		"page":      number,
 	}).Info("getting pdf images")
-	err := pdftohtml(p)
+	err := pdftohtml(ctx, options, p, pageDir, number)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	files, err := ioutil.ReadDir(dir)
+	files, err := ioutil.ReadDir(pageDir)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	text := ""
+	words := &LayoutPage{Number: number, Lines: make([]LayoutLine, 0)}
 	for _, file := range files {
 		filename := file.Name()
 		if strings.HasSuffix(filename, ".jpg") || strings.HasSuffix(filename, ".png") {
+			if imagePage(filename) != number {
+				continue
+			}
+			if !isPageBackground(filename) && filter.skip(path.Join(pageDir, filename)) {
+				continue
+			}
 			log.WithFields(log.Fields{
 				"image": filename,
 			}).Info("reading image text")
-			t, err := readImageText(path.Join(dir, filename))
+			t, layout, err := readImageLayout(ctx, options, path.Join(pageDir, filename))
 			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
 				continue
 			}
 			text = fmt.Sprintf("%s\n%s", text, t)
+			words.Lines = append(words.Lines, layout.Lines...)
 		}
 	}
-	return text, nil
+	return &ocrResult{text: text, confidence: words.Confidence()}, nil
 }
 
-func GetDocumentText(r io.Reader, images bool) (string, error) {
+// pageWork is what is left to do with a page: ocr it, decode its codes or
+// both, from the same rendered image.
+type pageWork struct {
+	page  *shared.Page
+	ocr   bool
+	codes bool
+}
+
+// processPage renders the page when needed, decodes its codes and ocrs it,
+// replacing its text when the ocr finds some.
+func processPage(ctx context.Context, options *Options, filter *imageFilter, dir, p string, work *pageWork) (*LayoutPage, []shared.Code) {
+	number := work.page.Number
+	pageDir := path.Join(dir, fmt.Sprintf("page-%d", number))
+	err := os.Mkdir(pageDir, 0755)
+	if err != nil {
+		return nil, nil
+	}
+	var image string
+	if work.codes || (work.ocr && options.Rasterizer != PdftohtmlRasterizer) {
+		log.WithFields(log.Fields{
+			"directory": pageDir,
+			"path":      p,
+			"page":      number,
+			"dpi":       options.DPI,
+		}).Info("rendering pdf page")
+		image, err = pdftoppm(ctx, options, p, pageDir, number)
+		if err != nil {
+			return nil, nil
+		}
+	}
+	var codes []shared.Code
+	if work.codes {
+		codes, err = zbarimg(ctx, options, image, number)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"page":  number,
+				"error": err.Error(),
+			}).Warn("failed to decode page codes")
+		}
+	}
+	if !work.ocr {
+		return nil, codes
+	}
+	var res *ocrResult
+	if options.Rasterizer == PdftohtmlRasterizer {
+		// the rendered page would be taken for an image of the page
+		if image != "" {
+			os.Remove(image)
+		}
+		res, err = getPageImagesText(ctx, options, filter, pageDir, p, number)
+	} else {
+		res, err = getPageRasterText(ctx, options, image, number)
+	}
+	if err != nil || TextChars(res.text) == 0 {
+		return nil, codes
+	}
+	work.page.Text = res.text
+	work.page.Source = shared.OCRSource
+	work.page.Confidence = res.confidence
+	return res.layout, codes
+}
+
+// TextChars counts the non blank characters of a text.
+func TextChars(text string) int {
+	count := 0
+	for _, r := range text {
+		if !unicode.IsSpace(r) {
+			count++
+		}
+	}
+	return count
+}
+
+// splitPages splits pdftotext's output, which ends every page with a form
+// feed.
+func splitPages(text string) []string {
+	pages := strings.Split(text, "\f")
+	if len(pages) > 1 && strings.TrimSpace(pages[len(pages)-1]) == "" {
+		pages = pages[:len(pages)-1]
+	}
+	return pages
+}
+
+// processPages ocrs the pages and decodes their codes with a pool of
+// options.Workers workers. The pages share one image filter, so repeated
+// images are counted across the document. It returns the layout of the ocr'd
+// pages and the codes in the order of the pages.
+func processPages(ctx context.Context, options *Options, dir, p string, work []*pageWork) ([]*LayoutPage, []shared.Code) {
+	workers := options.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	filter := newImageFilter(&options.ImageFilter)
+	layouts := make([]*LayoutPage, len(work))
+	pageCodes := make([][]shared.Code, len(work))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				if ctx.Err() != nil {
+					continue
+				}
+				layouts[i], pageCodes[i] = processPage(ctx, options, filter, dir, p, work[i])
+			}
+		}()
+	}
+	for i := range work {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	codes := make([]shared.Code, 0)
+	for _, c := range pageCodes {
+		codes = append(codes, c...)
+	}
+	return layouts, codes
+}
+
+// Result is everything extracted from a document.
+type Result struct {
+	Pages []shared.Page `json:"pages"`
+	// Layout has the words of the ocr'd pages when options.Layout is set.
+	Layout *Layout `json:"layout,omitempty"`
+	// Metadata is set by the pdf extractor.
+	Metadata *shared.PDFMetadata `json:"metadata,omitempty"`
+	// Signatures are set by the pdf extractor.
+	Signatures []shared.Signature `json:"signatures,omitempty"`
+	// Tables are found in the text layer of pdfs when options.Tables is
+	// set.
+	Tables []shared.Table `json:"tables,omitempty"`
+	// Codes are decoded from the rendered pages and images when
+	// options.Codes is set.
+	Codes []shared.Code `json:"codes,omitempty"`
+}
+
+type pdfExtractor struct{}
+
+// Extract extracts the text layer of every page, and ocrs the pages whose
+// text layer has less than options.MinTextChars characters. When ctx is done
+// before every page is ocr'd, the pages left keep their text layer and
+// ErrIncomplete is returned along with the result.
+func (pdfExtractor) Extract(ctx context.Context, r io.Reader, options *Options) (*Result, error) {
 	dir, p, err := writeToTempFile(r)
 	defer os.RemoveAll(dir)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	log.Info("getting pdf plain text")
-	text, err := getDocumentPlainText(p)
+	text, err := getDocumentPlainText(ctx, options, p)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	text2 := ""
-	if images {
-		log.Info("getting pdf images text")
-		text2, err = getDocumentImagesText(dir, p)
-		if err != nil {
-			return "", err
+	pages := make([]shared.Page, 0)
+	for i, t := range splitPages(text) {
+		pages = append(pages, shared.Page{
+			Number: i + 1,
+			Text:   t,
+			Source: shared.TextLayerSource,
+		})
+	}
+	result := &Result{Pages: pages}
+	getDocumentStructure(p, result)
+	if options.Tables {
+		layoutText := text
+		if !options.PreserveLayout {
+			layoutText, err = getDocumentText(ctx, options, p, true)
 		}
-	} else {
+		if err == nil {
+			result.Tables = pagesTables(splitPages(layoutText))
+		}
+	}
+	ocr := options.Images
+	if !ocr {
 		log.Info("skipping images text")
+	} else if !options.canRasterize() {
+		log.WithFields(log.Fields{
+			"rasterizer": options.Rasterizer,
+		}).Warn("rasterizer is not installed, skipping images text")
+		ocr = false
+	}
+	codes := options.Codes
+	if codes && !canDecodeCodes() {
+		log.Warn("pdftoppm or zbarimg are not installed, skipping codes")
+		codes = false
+	}
+	if !ocr && !codes {
+		return result, nil
+	}
+
+	work := make([]*pageWork, 0)
+	pending := 0
+	for i := range pages {
+		w := &pageWork{
+			page:  &pages[i],
+			ocr:   ocr && TextChars(pages[i].Text) < options.MinTextChars,
+			codes: codes,
+		}
+		if w.ocr {
+			pending++
+		}
+		if w.ocr || w.codes {
+			work = append(work, w)
+		}
+	}
+	log.WithFields(log.Fields{
+		"pages": len(pages),
+		"ocr":   pending,
+		"codes": codes,
+	}).Info("getting pdf pages images text")
+	layouts, found := processPages(ctx, options, dir, p, work)
+	if codes {
+		result.Codes = found
+	}
+	if ocr && options.Layout != "" {
+		result.Layout = &Layout{Pages: make([]LayoutPage, 0)}
+		for _, layout := range layouts {
+			if layout != nil {
+				result.Layout.Pages = append(result.Layout.Pages, *layout)
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		log.WithFields(log.Fields{
+			"timeout": options.DocumentTimeout,
+		}).Warn("document extraction ran out of time, some pages were not ocr'd")
+		return result, ErrIncomplete
+	}
+	return result, nil
+}
+
+// GetDocumentPages extracts the text of every page of a pdf.
+func GetDocumentPages(r io.Reader, options *Options) ([]shared.Page, error) {
+	result, err := Extract(shared.PDFContentType, r, options)
+	if result == nil {
+		return nil, err
+	}
+	return result.Pages, err
+}
+
+// DocumentConfidence is the mean confidence of the ocr'd pages, or 0 if no
+// page was ocr'd.
+func DocumentConfidence(pages []shared.Page) float64 {
+	total := 0.0
+	count := 0
+	for _, page := range pages {
+		if page.Source == shared.OCRSource {
+			total += page.Confidence
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
+}
+
+// PagesText joins the text of every page.
+func PagesText(pages []shared.Page) string {
+	texts := make([]string, len(pages))
+	for i, page := range pages {
+		texts[i] = page.Text
+	}
+	return strings.Join(texts, "\n")
+}
+
+func GetDocumentText(r io.Reader, images bool) (string, error) {
+	options := DefaultOptions()
+	options.Images = images
+	pages, err := GetDocumentPages(r, options)
+	if err != nil {
+		return "", err
 	}
-	return fmt.Sprintf("%s\n%s", text, text2), nil
+	return PagesText(pages), nil
 }
@@ -0,0 +1,109 @@
+package extracttext
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/odia/juscaba/pdf"
+	shared "github.com/odia/juscaba/shared"
+	log "github.com/sirupsen/logrus"
+)
+
+// permissionNames are the /P bits of the standard security handler, from
+// the third bit on.
+var permissionNames = []string{
+	"print", "modify", "copy", "annotate", "", "",
+	"fill-forms", "extract-accessibility", "assemble", "print-high-quality",
+}
+
+func permissions(p int) []string {
+	allowed := make([]string, 0)
+	for i, name := range permissionNames {
+		if name != "" && p&(1<<uint(i+2)) != 0 {
+			allowed = append(allowed, name)
+		}
+	}
+	return allowed
+}
+
+// recoverPDF turns a panic of the pdf parser into an error, so a malformed
+// document is skipped instead of stopping the build. It must be deferred by
+// every function that reads pdfs with the pdf package.
+func recoverPDF(err *error) {
+	e := recover()
+	if e == nil {
+		return
+	}
+	log.WithFields(log.Fields{
+		"error": fmt.Sprint(e),
+	}).Error("pdf parser failed")
+	*err = fmt.Errorf("%w: %v", pdf.ErrSyntax, e)
+}
+
+// GetPDFMetadata reads the document information dictionary, the page count,
+// the version and the encryption of a pdf.
+func GetPDFMetadata(content []byte) (metadata *shared.PDFMetadata, err error) {
+	defer func() {
+		if err != nil {
+			metadata = nil
+		}
+	}()
+	defer recoverPDF(&err)
+	metadata = &shared.PDFMetadata{FileSize: int64(len(content))}
+	r, err := pdf.Open(content)
+	if r == nil {
+		return nil, err
+	}
+	metadata.Version = r.Version
+	metadata.Encrypted = r.Encrypted()
+	if p, ok := r.Permissions(); ok {
+		metadata.Permissions = permissions(p)
+	}
+	if errors.Is(err, pdf.ErrPassword) {
+		metadata.NeedsPassword = true
+		return metadata, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	metadata.Pages = len(r.Pages())
+	info := r.Info()
+	metadata.Title = pdf.TextString(r.Resolve(info["Title"]))
+	metadata.Author = pdf.TextString(r.Resolve(info["Author"]))
+	metadata.Subject = pdf.TextString(r.Resolve(info["Subject"]))
+	metadata.Creator = pdf.TextString(r.Resolve(info["Creator"]))
+	metadata.Producer = pdf.TextString(r.Resolve(info["Producer"]))
+	if t, ok := pdf.Date(r.Resolve(info["CreationDate"])); ok {
+		metadata.CreationDate = &t
+	}
+	if t, ok := pdf.Date(r.Resolve(info["ModDate"])); ok {
+		metadata.ModificationDate = &t
+	}
+	return metadata, nil
+}
+
+// getDocumentStructure sets the metadata and the signatures of the pdf at
+// p. They are left empty when the structure can not be read, the text may
+// still be extracted by poppler.
+func getDocumentStructure(p string, result *Result) {
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return
+	}
+	result.Metadata, err = GetPDFMetadata(content)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Warn("failed to read pdf metadata")
+		return
+	}
+	result.Signatures, err = GetPDFSignatures(content)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Warn("failed to read pdf signatures")
+	}
+}
@@ -0,0 +1,119 @@
+package extracttext
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	shared "github.com/odia/juscaba/shared"
+)
+
+// infoPDF is a pdf of two empty pages with the given /Info dictionary.
+func infoPDF(info string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] >>",
+		info,
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.6\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	start := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, start)
+	return b.Bytes()
+}
+
+func TestGetPDFMetadata(t *testing.T) {
+	info := infoPDF("<< /Title (Sentencia definitiva) /Author <FEFF004A0075007A006700610064006F00200031> " +
+		"/Subject (Amparo) /Creator (Writer) /Producer (LibreOffice 7.0) " +
+		"/CreationDate (D:20210304120000-03'00') /ModDate (D:20210305) >>")
+	// the encrypted files are helloObjects of the pdf package with a
+	// standard security handler and an empty user password, written by
+	// encryptedHello in pdf/crypt_test.go
+	read := func(name string) []byte {
+		content, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return content
+	}
+	encrypted := []string{"print", "copy", "fill-forms", "assemble", "print-high-quality"}
+	art := time.FixedZone("", -3*60*60)
+	tests := []struct {
+		name         string
+		content      []byte
+		want         shared.PDFMetadata
+		created, mod time.Time
+	}{
+		{
+			"info", info,
+			shared.PDFMetadata{
+				Pages: 2, Title: "Sentencia definitiva", Author: "Juzgado 1", Subject: "Amparo",
+				Creator: "Writer", Producer: "LibreOffice 7.0", Version: "1.6",
+			},
+			time.Date(2021, 3, 4, 12, 0, 0, 0, art), time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{"no info", infoPDF("null"), shared.PDFMetadata{Pages: 2, Version: "1.6"}, time.Time{}, time.Time{}},
+		{
+			"rc4", read("encrypted-rc4.pdf"),
+			shared.PDFMetadata{
+				Pages: 1, Title: "Sentencia definitiva", Author: "Juzgado 1", Version: "1.4",
+				Encrypted: true, Permissions: encrypted,
+			},
+			time.Time{}, time.Time{},
+		},
+		{
+			"aes", read("encrypted-aes.pdf"),
+			shared.PDFMetadata{
+				Pages: 1, Title: "Sentencia definitiva", Author: "Juzgado 1", Version: "1.4",
+				Encrypted: true, Permissions: encrypted,
+			},
+			time.Time{}, time.Time{},
+		},
+		{
+			"user password", read("encrypted-password.pdf"),
+			shared.PDFMetadata{Version: "1.4", Encrypted: true, NeedsPassword: true, Permissions: encrypted},
+			time.Time{}, time.Time{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := GetPDFMetadata(test.content)
+			if err != nil {
+				t.Fatalf("GetPDFMetadata() error = %v", err)
+			}
+			for _, date := range []struct {
+				name string
+				got  *time.Time
+				want time.Time
+			}{{"CreationDate", got.CreationDate, test.created}, {"ModificationDate", got.ModificationDate, test.mod}} {
+				if (date.got == nil) != date.want.IsZero() || date.got != nil && !date.got.Equal(date.want) {
+					t.Errorf("%s = %v, want %v", date.name, date.got, date.want)
+				}
+			}
+			got.CreationDate, got.ModificationDate = nil, nil
+			want := test.want
+			want.FileSize = int64(len(test.content))
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("GetPDFMetadata() = %+v, want %+v", *got, want)
+			}
+		})
+	}
+	if _, err := GetPDFMetadata([]byte("no es un pdf")); err == nil {
+		t.Errorf("GetPDFMetadata() of a text file succeeded")
+	}
+}
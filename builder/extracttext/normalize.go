@@ -0,0 +1,260 @@
+package extracttext
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	shared "github.com/odia/juscaba/shared"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeOptions selects the cleanups applied to the extracted text.
+type NormalizeOptions struct {
+	// Unicode applies the NFC normalization, which composes accents written
+	// as combining marks, e.g. "a" and a combining acute into "á", and
+	// repairs ligatures like "ﬁ".
+	Unicode bool `json:"unicode"`
+	// HeadersFooters removes lines repeated at the top or bottom of most
+	// pages, like the court name and page numbers.
+	HeadersFooters bool `json:"headersFooters"`
+	// Dehyphenate joins words split with a hyphen at the end of a line.
+	Dehyphenate bool `json:"dehyphenate"`
+	// Junk removes lines that are mostly symbols, usually ocr noise.
+	Junk bool `json:"junk"`
+	// Whitespace collapses spaces and blank lines and removes form feeds.
+	Whitespace bool `json:"whitespace"`
+	// KeepRaw keeps the text as extracted in the documento when the
+	// cleanups changed it.
+	KeepRaw bool `json:"keepRaw"`
+}
+
+// Set enables every cleanup with "true", none with "false", or the ones in
+// a comma separated list, e.g. "unicode,whitespace".
+func (n *NormalizeOptions) Set(s string) error {
+	all := s == "true"
+	if all || s == "false" {
+		*n = NormalizeOptions{all, all, all, all, all, n.KeepRaw}
+		return nil
+	}
+	*n = NormalizeOptions{KeepRaw: n.KeepRaw}
+	for _, name := range strings.Split(s, ",") {
+		switch strings.TrimSpace(name) {
+		case "unicode":
+			n.Unicode = true
+		case "headers-footers":
+			n.HeadersFooters = true
+		case "dehyphenate":
+			n.Dehyphenate = true
+		case "junk":
+			n.Junk = true
+		case "whitespace":
+			n.Whitespace = true
+		default:
+			return fmt.Errorf("unknown normalization %q", name)
+		}
+	}
+	return nil
+}
+
+func (n *NormalizeOptions) enabled() bool {
+	return n.Unicode || n.HeadersFooters || n.Dehyphenate || n.Junk || n.Whitespace
+}
+
+var ligatures = strings.NewReplacer(
+	"ﬀ", "ff", "ﬁ", "fi", "ﬂ", "fl", "ﬃ", "ffi", "ﬄ", "ffl", "ﬅ", "st", "ﬆ", "st",
+	"­", "",
+)
+
+var digitsRegexp = regexp.MustCompile(`\d+`)
+
+// lineKey identifies repeated lines. Numbers are ignored in short lines, so
+// "Página 2 de 9" and "Página 3 de 9" are the same line.
+func lineKey(line string) string {
+	words := strings.Fields(line)
+	key := strings.ToLower(strings.Join(words, " "))
+	if len(words) > 4 {
+		return key
+	}
+	return digitsRegexp.ReplaceAllString(key, "#")
+}
+
+// edgeLines is how many lines at the top and bottom of a page can be a
+// header or a footer.
+const edgeLines = 3
+
+// edges returns the indexes of the first and last non blank lines of a page.
+func edges(lines []string) []int {
+	indexes := make([]int, 0, 2*edgeLines)
+	for i := 0; i < len(lines) && len(indexes) < edgeLines; i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			indexes = append(indexes, i)
+		}
+	}
+	lastTop := -1
+	if len(indexes) > 0 {
+		lastTop = indexes[len(indexes)-1]
+	}
+	bottom := 0
+	for i := len(lines) - 1; i > lastTop && bottom < edgeLines; i-- {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		bottom++
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// removeHeadersFooters drops the lines at the edges of the pages that repeat,
+// ignoring numbers, in at least half of the pages. Documents with fewer than
+// three pages are left alone.
+func removeHeadersFooters(pages [][]string) {
+	if len(pages) < 3 {
+		return
+	}
+	counts := map[string]int{}
+	for _, lines := range pages {
+		seen := map[string]bool{}
+		for _, i := range edges(lines) {
+			key := lineKey(lines[i])
+			if !seen[key] {
+				seen[key] = true
+				counts[key]++
+			}
+		}
+	}
+	min := (len(pages) + 1) / 2
+	if min < 3 {
+		min = 3
+	}
+	for p, lines := range pages {
+		remove := map[int]bool{}
+		for _, i := range edges(lines) {
+			if counts[lineKey(lines[i])] >= min {
+				remove[i] = true
+			}
+		}
+		kept := make([]string, 0, len(lines))
+		for i, line := range lines {
+			if !remove[i] {
+				kept = append(kept, line)
+			}
+		}
+		pages[p] = kept
+	}
+}
+
+// dehyphenate joins "pala-" at the end of a line with "bra" at the start of
+// the next one when it starts in lower case.
+func dehyphenate(lines []string) []string {
+	for i := 0; i+1 < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		if !strings.HasSuffix(line, "-") || strings.HasSuffix(line, "--") {
+			continue
+		}
+		before := []rune(strings.TrimSuffix(line, "-"))
+		if len(before) == 0 || !unicode.IsLetter(before[len(before)-1]) {
+			continue
+		}
+		next := strings.TrimLeft(lines[i+1], " \t")
+		first := []rune(next)
+		if len(first) == 0 || !unicode.IsLower(first[0]) {
+			continue
+		}
+		word := next
+		rest := ""
+		if j := strings.IndexAny(next, " \t"); j >= 0 {
+			word, rest = next[:j], strings.TrimLeft(next[j:], " \t")
+		}
+		lines[i] = string(before) + word
+		if rest == "" {
+			lines = append(lines[:i+1], lines[i+2:]...)
+			// the joined line may end with a hyphen again
+			i--
+			continue
+		}
+		lines[i+1] = rest
+	}
+	return lines
+}
+
+// isJunk tells if a line is mostly symbols, like "~ ,. |||".
+func isJunk(line string) bool {
+	total, alphanumeric := 0, 0
+	for _, r := range line {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			alphanumeric++
+		}
+	}
+	return total >= 3 && alphanumeric*10 < total*3
+}
+
+var lineSpacesRegexp = regexp.MustCompile(`[ \t\f\r\v\x{a0}]+`)
+
+// collapseWhitespace trims the lines, collapses spaces and leaves at most
+// one blank line in a row.
+func collapseWhitespace(lines []string) []string {
+	kept := make([]string, 0, len(lines))
+	blank := true
+	for _, line := range lines {
+		line = strings.TrimSpace(lineSpacesRegexp.ReplaceAllString(line, " "))
+		if line == "" {
+			if !blank {
+				kept = append(kept, "")
+			}
+			blank = true
+			continue
+		}
+		kept = append(kept, line)
+		blank = false
+	}
+	for len(kept) > 0 && kept[len(kept)-1] == "" {
+		kept = kept[:len(kept)-1]
+	}
+	return kept
+}
+
+// Normalize returns a copy of the pages with their text cleaned up.
+func Normalize(pages []shared.Page, options *NormalizeOptions) []shared.Page {
+	normalized := make([]shared.Page, len(pages))
+	copy(normalized, pages)
+	if !options.enabled() {
+		return normalized
+	}
+	lines := make([][]string, len(pages))
+	for i, page := range pages {
+		text := page.Text
+		if options.Unicode {
+			text = norm.NFC.String(ligatures.Replace(text))
+		}
+		lines[i] = strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	}
+	if options.HeadersFooters {
+		removeHeadersFooters(lines)
+	}
+	for i := range lines {
+		if options.Dehyphenate {
+			lines[i] = dehyphenate(lines[i])
+		}
+		if options.Junk {
+			kept := make([]string, 0, len(lines[i]))
+			for _, line := range lines[i] {
+				if !isJunk(line) {
+					kept = append(kept, line)
+				}
+			}
+			lines[i] = kept
+		}
+		if options.Whitespace {
+			lines[i] = collapseWhitespace(lines[i])
+		}
+		normalized[i].Text = strings.Join(lines[i], "\n")
+	}
+	return normalized
+}
@@ -0,0 +1,186 @@
+package extracttext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	shared "github.com/odia/juscaba/shared"
+)
+
+func TestNormalizeOptionsSet(t *testing.T) {
+	tests := []struct {
+		value string
+		want  NormalizeOptions
+		err   bool
+	}{
+		{"true", NormalizeOptions{true, true, true, true, true, true}, false},
+		{"false", NormalizeOptions{KeepRaw: true}, false},
+		{"unicode, whitespace", NormalizeOptions{Unicode: true, Whitespace: true, KeepRaw: true}, false},
+		{"headers-footers,dehyphenate,junk", NormalizeOptions{HeadersFooters: true, Dehyphenate: true, Junk: true, KeepRaw: true}, false},
+		{"unicode,accents", NormalizeOptions{}, true},
+	}
+	for _, test := range tests {
+		// Set keeps the KeepRaw given in its own flag
+		n := NormalizeOptions{KeepRaw: true}
+		err := n.Set(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("Set(%q) error = %v", test.value, err)
+			continue
+		}
+		if err == nil && n != test.want {
+			t.Errorf("Set(%q) = %+v, want %+v", test.value, n, test.want)
+		}
+	}
+}
+
+func TestNormalizeUnicode(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"combining acute", "resolucio\u0301n", "resoluci\u00f3n"},
+		{"combining tilde", "ESPAN\u0303A", "ESPA\u00d1A"},
+		{"combining diaeresis", "argu\u0308ir", "arg\u00fcir"},
+		{"already composed", "notificaci\u00f3n", "notificaci\u00f3n"},
+		{"spacing acute is kept", "l\u00b4a", "l\u00b4a"},
+		{"spacing grave is kept", "`a`", "`a`"},
+		{"ligatures", "ﬁrma y ﬂujo", "firma y flujo"},
+		{"soft hyphen", "expe\u00addiente", "expediente"},
+	}
+	options := &NormalizeOptions{Unicode: true}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Normalize([]shared.Page{{Number: 1, Text: test.text}}, options)
+			if got[0].Text != test.want {
+				t.Errorf("Normalize(%q) = %q, want %q", test.text, got[0].Text, test.want)
+			}
+		})
+	}
+}
+
+func TestRemoveHeadersFooters(t *testing.T) {
+	page := func(n int, body string) []string {
+		return []string{
+			"Poder Judicial de la Ciudad de Buenos Aires",
+			body,
+			"Página " + strings.Repeat("1", n) + " de 4",
+		}
+	}
+	tests := []struct {
+		name  string
+		pages [][]string
+		want  [][]string
+	}{
+		{
+			"repeated in every page",
+			[][]string{page(1, "uno"), page(2, "dos"), page(3, "tres"), page(4, "cuatro")},
+			[][]string{{"uno"}, {"dos"}, {"tres"}, {"cuatro"}},
+		},
+		{
+			"too few pages",
+			[][]string{page(1, "uno"), page(2, "dos")},
+			[][]string{page(1, "uno"), page(2, "dos")},
+		},
+		{
+			"not repeated",
+			[][]string{{"a", "b"}, {"c", "d"}, {"e", "f"}},
+			[][]string{{"a", "b"}, {"c", "d"}, {"e", "f"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			removeHeadersFooters(test.pages)
+			if !reflect.DeepEqual(test.pages, test.want) {
+				t.Errorf("removeHeadersFooters() = %q, want %q", test.pages, test.want)
+			}
+		})
+	}
+}
+
+func TestDehyphenate(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  []string
+	}{
+		{[]string{"la resolu-", "ción dictada"}, []string{"la resolución", "dictada"}},
+		{[]string{"la resolu-", "ción"}, []string{"la resolución"}},
+		{[]string{"ante-", "pro-", "yecto final"}, []string{"anteproyecto", "final"}},
+		{[]string{"Buenos Aires -", "Argentina"}, []string{"Buenos Aires -", "Argentina"}},
+		{[]string{"Ciudad Autónoma-", "Buenos Aires"}, []string{"Ciudad Autónoma-", "Buenos Aires"}},
+		{[]string{"separador --", "fin"}, []string{"separador --", "fin"}},
+		{[]string{"art. 12-", "bis"}, []string{"art. 12-", "bis"}},
+	}
+	for _, test := range tests {
+		lines := append([]string{}, test.lines...)
+		got := dehyphenate(lines)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("dehyphenate(%q) = %q, want %q", test.lines, got, test.want)
+		}
+	}
+}
+
+func TestIsJunk(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"~ ,. |||", true},
+		{"-- * --", true},
+		{"a)", false},
+		{"Expediente 182908/2020-0", false},
+		{"", false},
+		{"§ 12", false},
+	}
+	for _, test := range tests {
+		if got := isJunk(test.line); got != test.want {
+			t.Errorf("isJunk(%q) = %v, want %v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestCollapseWhitespace(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  []string
+	}{
+		{[]string{"  hola \t mundo  "}, []string{"hola mundo"}},
+		{[]string{"", "", "uno", "", "", "", "dos", "", ""}, []string{"uno", "", "dos"}},
+		{[]string{"uno\f", " dos\r"}, []string{"uno", "dos"}},
+		{[]string{" ", "\t"}, []string{}},
+	}
+	for _, test := range tests {
+		got := collapseWhitespace(test.lines)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("collapseWhitespace(%q) = %q, want %q", test.lines, got, test.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	pages := []shared.Page{{Number: 1, Text: "  Resolución de-\r\nfinitiva \n\n\n~~ || ~~\nﬁrmada  "}}
+	tests := []struct {
+		name    string
+		options NormalizeOptions
+		want    string
+	}{
+		{"everything", NormalizeOptions{true, true, true, true, true, false}, "Resolución definitiva\n\nfirmada"},
+		{"nothing", NormalizeOptions{}, pages[0].Text},
+		{"only whitespace", NormalizeOptions{Whitespace: true}, "Resolución de-\nfinitiva\n\n~~ || ~~\nﬁrmada"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Normalize(pages, &test.options)
+			if got[0].Text != test.want {
+				t.Errorf("Normalize() = %q, want %q", got[0].Text, test.want)
+			}
+			if got[0].Number != 1 {
+				t.Errorf("Normalize() changed the page number")
+			}
+		})
+	}
+	if pages[0].Text != "  Resolución de-\r\nfinitiva \n\n\n~~ || ~~\nﬁrmada  " {
+		t.Errorf("Normalize() changed its input")
+	}
+}
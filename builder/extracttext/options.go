@@ -0,0 +1,222 @@
+package extracttext
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Version identifies the extraction code. It must be increased whenever a
+// change alters the extracted text, so cached results are not reused.
+const Version = 6
+
+// PdftoppmRasterizer renders whole pages, PdftohtmlRasterizer only extracts
+// the images embedded in them.
+const PdftoppmRasterizer = "pdftoppm"
+const PdftohtmlRasterizer = "pdftohtml"
+
+// TesseractOptions are passed to every tesseract call.
+type TesseractOptions struct {
+	// Languages are the tesseract language packs to use, e.g. spa and por.
+	Languages []string `json:"languages"`
+	// PSM is the page segmentation mode, -1 leaves tesseract's default.
+	PSM int `json:"psm"`
+	// OEM is the ocr engine mode, -1 leaves tesseract's default.
+	OEM int `json:"oem"`
+	// Variables are set with -c name=value.
+	Variables map[string]string `json:"variables"`
+	// UserWords is a file with one word per line, e.g. legal vocabulary.
+	UserWords string `json:"userWords"`
+}
+
+func (t *TesseractOptions) args() []string {
+	args := []string{"-l", strings.Join(t.Languages, "+")}
+	if t.PSM >= 0 {
+		args = append(args, "--psm", strconv.Itoa(t.PSM))
+	}
+	if t.OEM >= 0 {
+		args = append(args, "--oem", strconv.Itoa(t.OEM))
+	}
+	if t.UserWords != "" {
+		args = append(args, "--user-words", t.UserWords)
+	}
+	names := make([]string, 0, len(t.Variables))
+	for name := range t.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-c", fmt.Sprintf("%s=%s", name, t.Variables[name]))
+	}
+	return args
+}
+
+// Options configures how the text of a document is extracted.
+type Options struct {
+	// PDFBackend reads the text layer of pdfs: poppler runs pdftotext, go
+	// uses the pure go parser and auto picks poppler when it is installed.
+	PDFBackend string `json:"pdfBackend"`
+	// PreserveLayout keeps the physical layout of the text layer, like
+	// pdftotext -layout, instead of the reading order.
+	PreserveLayout bool `json:"preserveLayout"`
+	// Tables finds the tables of the text layer of pdfs.
+	Tables bool `json:"tables"`
+	// Codes decodes the qr codes and barcodes of the rendered pages and
+	// images with zbarimg.
+	Codes bool `json:"codes"`
+	// Images enables ocr of the pages without a usable text layer.
+	Images bool `json:"images"`
+	// MinTextChars is the number of non blank characters below which a
+	// page's text layer is considered unusable and the page is ocr'd.
+	MinTextChars int `json:"minTextChars"`
+	// Rasterizer is how the pages are turned into images to ocr.
+	Rasterizer string `json:"rasterizer"`
+	// DPI is the resolution pages are rendered at.
+	DPI int `json:"dpi"`
+	// Grayscale renders the pages in gray instead of color.
+	Grayscale bool `json:"grayscale"`
+	// Deskew straightens rendered pages before ocr.
+	Deskew bool `json:"deskew"`
+	// Threshold binarizes rendered pages before ocr.
+	Threshold bool `json:"threshold"`
+	// Tesseract configures the ocr engine.
+	Tesseract TesseractOptions `json:"tesseract"`
+	// ImageFilter skips repeated images, like letterheads, before ocr.
+	ImageFilter ImageFilterOptions `json:"imageFilter"`
+	// Layout is the format, hocr or alto, of the word positions saved for
+	// the ocr'd pages. Empty disables it. Only the pdftoppm rasterizer
+	// produces layouts, in pixels of the rendered and preprocessed page.
+	Layout string `json:"layout"`
+	// Normalize cleans up the extracted text. It is applied after the
+	// cache, so changing it does not extract the documents again.
+	Normalize NormalizeOptions `json:"normalize"`
+	// Archive limits the members unpacked from zip and rar files.
+	Archive ArchiveOptions `json:"archive"`
+	// Workers is the number of pages ocr'd in parallel.
+	Workers int `json:"workers"`
+	// CommandTimeout limits each call to pdftotext, pdftohtml and tesseract.
+	CommandTimeout time.Duration `json:"-"`
+	// DocumentTimeout limits the whole extraction of a document.
+	DocumentTimeout time.Duration `json:"-"`
+}
+
+func DefaultOptions() *Options {
+	return &Options{
+		PDFBackend:   AutoBackend,
+		Images:       true,
+		MinTextChars: 20,
+		Rasterizer:   PdftoppmRasterizer,
+		DPI:          300,
+		Grayscale:    true,
+		Tesseract: TesseractOptions{
+			Languages: []string{"spa"},
+			PSM:       -1,
+			OEM:       -1,
+			Variables: map[string]string{},
+		},
+		ImageFilter: ImageFilterOptions{
+			Ignore:      []string{},
+			MaxRepeats:  0,
+			MaxDistance: 4,
+		},
+		Normalize: NormalizeOptions{
+			Unicode:        true,
+			HeadersFooters: true,
+			Dehyphenate:    true,
+			Junk:           true,
+			Whitespace:     true,
+		},
+		Archive: ArchiveOptions{
+			MaxFiles:     1000,
+			MaxFileSize:  100 << 20,
+			MaxTotalSize: 500 << 20,
+			MaxDepth:     3,
+		},
+		Workers:         runtime.NumCPU(),
+		CommandTimeout:  2 * time.Minute,
+		DocumentTimeout: 30 * time.Minute,
+	}
+}
+
+// UnmarshalJSON reads the timeouts as duration strings, e.g. "90s", and
+// rejects unknown settings.
+func (options *Options) UnmarshalJSON(b []byte) error {
+	type plain Options
+	config := struct {
+		*plain
+		CommandTimeout  string `json:"commandTimeout"`
+		DocumentTimeout string `json:"documentTimeout"`
+	}{plain: (*plain)(options)}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&config)
+	if err != nil {
+		return err
+	}
+	if config.CommandTimeout != "" {
+		options.CommandTimeout, err = time.ParseDuration(config.CommandTimeout)
+		if err != nil {
+			return err
+		}
+	}
+	if config.DocumentTimeout != "" {
+		options.DocumentTimeout, err = time.ParseDuration(config.DocumentTimeout)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadOptions reads a json config file over the given options. Settings
+// missing from the file keep their value.
+func LoadOptions(p string, options *Options) error {
+	fp, err := os.Open(p)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Error("failed to open extraction config")
+		return err
+	}
+	defer fp.Close()
+	err = json.NewDecoder(fp).Decode(options)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Error("failed to decode extraction config")
+	}
+	return err
+}
+
+// Fingerprint identifies the version and the options that affect the
+// extracted text.
+func (options *Options) Fingerprint() string {
+	h := sha1.New()
+	fmt.Fprintf(h, "v%d backend=%s images=%v minTextChars=%d", Version, options.Backend(), options.Images, options.MinTextChars)
+	fmt.Fprintf(h, " rasterizer=%s dpi=%d grayscale=%v deskew=%v threshold=%v",
+		options.Rasterizer, options.DPI, options.Grayscale, options.Deskew, options.Threshold)
+	fmt.Fprintf(h, " tesseract=%q layout=%s", options.Tesseract.args(), options.Layout)
+	fmt.Fprintf(h, " preserveLayout=%v tables=%v codes=%v", options.PreserveLayout, options.Tables, options.Codes)
+	fmt.Fprintf(h, " imageFilter=%q/%d/%d", options.ImageFilter.Ignore, options.ImageFilter.MaxRepeats, options.ImageFilter.MaxDistance)
+	if options.Tesseract.UserWords != "" {
+		words, _ := os.ReadFile(options.Tesseract.UserWords)
+		fmt.Fprintf(h, " userWords=%x", sha1.Sum(words))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))[:12]
+}
+
+// CacheKey is the key of the extracted text of a content hash.
+func (options *Options) CacheKey(contentHash string) string {
+	return fmt.Sprintf("%s-pages-%s", contentHash, options.Fingerprint())
+}
@@ -0,0 +1,136 @@
+package extracttext
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testOptions are the default options with a backend that does not depend
+// on the installed tools.
+func testOptions() *Options {
+	options := DefaultOptions()
+	options.PDFBackend = GoBackend
+	return options
+}
+
+func TestFingerprint(t *testing.T) {
+	userWords := filepath.Join(t.TempDir(), "palabras.txt")
+	err := os.WriteFile(userWords, []byte("autos\nfojas\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := testOptions()
+	base.Tesseract.UserWords = userWords
+	fingerprint := base.Fingerprint()
+
+	tests := []struct {
+		name    string
+		change  func(o *Options)
+		changes bool
+	}{
+		{"language", func(o *Options) { o.Tesseract.Languages = []string{"spa", "por"} }, true},
+		{"dpi", func(o *Options) { o.DPI = 200 }, true},
+		{"deskew", func(o *Options) { o.Deskew = true }, true},
+		{"threshold", func(o *Options) { o.Threshold = true }, true},
+		{"backend", func(o *Options) { o.PDFBackend = PopplerBackend }, true},
+		{"images", func(o *Options) { o.Images = false }, true},
+		{"min text chars", func(o *Options) { o.MinTextChars = 50 }, true},
+		{"rasterizer", func(o *Options) { o.Rasterizer = PdftohtmlRasterizer }, true},
+		{"grayscale", func(o *Options) { o.Grayscale = false }, true},
+		{"page segmentation", func(o *Options) { o.Tesseract.PSM = 6 }, true},
+		{"tesseract variable", func(o *Options) { o.Tesseract.Variables = map[string]string{"preserve_interword_spaces": "1"} }, true},
+		{"layout", func(o *Options) { o.Layout = HOCRLayout }, true},
+		{"preserve layout", func(o *Options) { o.PreserveLayout = true }, true},
+		{"tables", func(o *Options) { o.Tables = true }, true},
+		{"codes", func(o *Options) { o.Codes = true }, true},
+		{"image filter", func(o *Options) { o.ImageFilter.MaxRepeats = 3 }, true},
+		{"user words content", func(o *Options) { os.WriteFile(userWords, []byte("autos\n"), 0644) }, true},
+		{"nothing", func(o *Options) {}, false},
+		{"normalize", func(o *Options) { o.Normalize = NormalizeOptions{} }, false},
+		{"archive limits", func(o *Options) { o.Archive.MaxFiles = 10 }, false},
+		{"workers", func(o *Options) { o.Workers = 64 }, false},
+		{"command timeout", func(o *Options) { o.CommandTimeout = time.Second }, false},
+		{"document timeout", func(o *Options) { o.DocumentTimeout = time.Hour }, false},
+		{"empty tesseract variables", func(o *Options) { o.Tesseract.Variables = nil }, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer os.WriteFile(userWords, []byte("autos\nfojas\n"), 0644)
+			options := testOptions()
+			options.Tesseract.UserWords = userWords
+			test.change(options)
+			got := options.Fingerprint()
+			if changed := got != fingerprint; changed != test.changes {
+				t.Errorf("fingerprint changed = %v, want %v", changed, test.changes)
+			}
+			if options.CacheKey("abc") != "abc-pages-"+got {
+				t.Errorf("CacheKey() = %q, want it to use the fingerprint", options.CacheKey("abc"))
+			}
+		})
+	}
+}
+
+func TestLoadOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		check  func(o *Options) bool
+		fails  bool
+	}{
+		{
+			"settings and timeouts",
+			`{"dpi": 400, "deskew": true, "tesseract": {"languages": ["spa", "por"], "psm": 6}, "commandTimeout": "90s", "documentTimeout": "1h"}`,
+			func(o *Options) bool {
+				return o.DPI == 400 && o.Deskew && len(o.Tesseract.Languages) == 2 && o.Tesseract.PSM == 6 &&
+					o.CommandTimeout == 90*time.Second && o.DocumentTimeout == time.Hour
+			},
+			false,
+		},
+		{
+			"missing settings keep their value",
+			`{"tables": true}`,
+			func(o *Options) bool {
+				defaults := testOptions()
+				return o.Tables && o.DPI == defaults.DPI && o.Tesseract.OEM == -1 &&
+					o.CommandTimeout == defaults.CommandTimeout && o.Normalize == defaults.Normalize
+			},
+			false,
+		},
+		{
+			"sections",
+			`{"normalize": {"junk": false}, "archive": {"maxFiles": 10}, "imageFilter": {"maxRepeats": 2}}`,
+			func(o *Options) bool {
+				return !o.Normalize.Junk && o.Normalize.Unicode && o.Archive.MaxFiles == 10 &&
+					o.Archive.MaxDepth == 3 && o.ImageFilter.MaxRepeats == 2
+			},
+			false,
+		},
+		{"unknown setting", `{"dpis": 400}`, nil, true},
+		{"unknown nested setting", `{"tesseract": {"language": "spa"}}`, nil, true},
+		{"invalid timeout", `{"commandTimeout": "90"}`, nil, true},
+		{"wrong type", `{"dpi": "400"}`, nil, true},
+		{"invalid json", `{"dpi": 400`, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "config.json")
+			err := os.WriteFile(p, []byte(test.config), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			options := testOptions()
+			err = LoadOptions(p, options)
+			if (err != nil) != test.fails {
+				t.Fatalf("LoadOptions() error = %v, want failure %v", err, test.fails)
+			}
+			if !test.fails && !test.check(options) {
+				t.Errorf("LoadOptions() = %+v", options)
+			}
+		})
+	}
+	if err := LoadOptions(filepath.Join(t.TempDir(), "missing.json"), testOptions()); err == nil {
+		t.Errorf("LoadOptions() of a missing file succeeded")
+	}
+}
@@ -0,0 +1,170 @@
+package extracttext
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ImageFilterOptions skips the images, like court logos and stamps, that are
+// repeated on every page, so they are not ocr'd again and again. Images are
+// compared by their perceptual hash, which survives rescaling and
+// recompression.
+type ImageFilterOptions struct {
+	// Ignore are hashes, as printed by the image-hash command, of images
+	// that are never ocr'd.
+	Ignore []string `json:"ignore"`
+	// MaxRepeats is how many times the same image is ocr'd across the pages
+	// of a document. 0 disables the filter.
+	MaxRepeats int `json:"maxRepeats"`
+	// MaxDistance is the number of differing bits below which two hashes
+	// are the same image.
+	MaxDistance int `json:"maxDistance"`
+}
+
+// hashSize is the side of the grid the image is reduced to; the hash has
+// hashSize*hashSize bits.
+const hashSize = 8
+
+// ImageHash is a difference hash: every bit tells whether an area of the
+// image is brighter than the area on its right.
+func ImageHash(img *image.Gray) uint64 {
+	b := img.Bounds()
+	var cells [hashSize][hashSize + 1]float64
+	for y := 0; y < hashSize; y++ {
+		y0 := b.Min.Y + y*b.Dy()/hashSize
+		y1 := b.Min.Y + (y+1)*b.Dy()/hashSize
+		for x := 0; x <= hashSize; x++ {
+			x0 := b.Min.X + x*b.Dx()/(hashSize+1)
+			x1 := b.Min.X + (x+1)*b.Dx()/(hashSize+1)
+			sum, count := 0, 0
+			for sy := y0; sy < y1 || sy == y0; sy++ {
+				for sx := x0; sx < x1 || sx == x0; sx++ {
+					if sx >= b.Max.X || sy >= b.Max.Y {
+						continue
+					}
+					sum += int(img.Pix[img.PixOffset(sx, sy)])
+					count++
+				}
+			}
+			if count > 0 {
+				cells[y][x] = float64(sum) / float64(count)
+			}
+		}
+	}
+	var hash uint64
+	for y := 0; y < hashSize; y++ {
+		for x := 0; x < hashSize; x++ {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// FormatImageHash returns the hash as 16 hex digits.
+func FormatImageHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+func parseImageHash(s string) (uint64, error) {
+	return strconv.ParseUint(strings.TrimSpace(s), 16, 64)
+}
+
+// HashImageFile returns the perceptual hash of an image file.
+func HashImageFile(p string) (uint64, error) {
+	img, err := readGray(p)
+	if err != nil {
+		return 0, err
+	}
+	return ImageHash(img), nil
+}
+
+func hashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// seenImage counts the times an image was found.
+type seenImage struct {
+	hash  uint64
+	count int
+}
+
+// imageFilter decides which images of a document are not ocr'd. Every
+// document gets its own, so what is skipped does not depend on the documents
+// extracted before it.
+type imageFilter struct {
+	options *ImageFilterOptions
+	mu      sync.Mutex
+	seen    []*seenImage
+}
+
+func newImageFilter(options *ImageFilterOptions) *imageFilter {
+	return &imageFilter{options: options}
+}
+
+// ignoredImage tells whether the hash is in the ignore list.
+func (f *ImageFilterOptions) ignoredImage(hash uint64) bool {
+	for _, s := range f.Ignore {
+		ignored, err := parseImageHash(s)
+		if err != nil {
+			continue
+		}
+		if hashDistance(hash, ignored) <= f.MaxDistance {
+			return true
+		}
+	}
+	return false
+}
+
+// repeatedImage records the hash and tells whether it was already found
+// MaxRepeats times in the document.
+func (f *imageFilter) repeatedImage(hash uint64) bool {
+	if f.options.MaxRepeats <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, seen := range f.seen {
+		if hashDistance(hash, seen.hash) <= f.options.MaxDistance {
+			seen.count++
+			return seen.count > f.options.MaxRepeats
+		}
+	}
+	f.seen = append(f.seen, &seenImage{hash: hash, count: 1})
+	return false
+}
+
+// skip tells whether the image should not be ocr'd because it is ignored or
+// repeated. Images that can not be decoded are never skipped.
+func (f *imageFilter) skip(p string) bool {
+	if len(f.options.Ignore) == 0 && f.options.MaxRepeats <= 0 {
+		return false
+	}
+	hash, err := HashImageFile(p)
+	if err != nil {
+		return false
+	}
+	if f.options.ignoredImage(hash) {
+		log.WithFields(log.Fields{
+			"image": p,
+			"hash":  FormatImageHash(hash),
+		}).Info("skipping ignored image")
+		return true
+	}
+	if f.repeatedImage(hash) {
+		log.WithFields(log.Fields{
+			"image": p,
+			"hash":  FormatImageHash(hash),
+		}).Info("skipping repeated image")
+		return true
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package extracttext
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testImage draws a pattern of size pixels, stripes when vertical is set and
+// a diagonal gradient otherwise.
+func testImage(size int, vertical bool) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			v := (x + y) * 255 / (2 * size)
+			if vertical {
+				v = 255 * ((x * 8 / size) % 2)
+			}
+			img.SetGray(x, y, color.Gray{Y: uint8(v)})
+		}
+	}
+	return img
+}
+
+func writeTestImage(t *testing.T, p string, img image.Image) string {
+	t.Helper()
+	err := writePNG(p, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestImageHash(t *testing.T) {
+	small := ImageHash(testImage(64, false))
+	tests := []struct {
+		name string
+		img  *image.Gray
+		same bool
+	}{
+		{"same image", testImage(64, false), true},
+		{"rescaled", testImage(200, false), true},
+		{"different", testImage(64, true), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := hashDistance(small, ImageHash(test.img))
+			if (d <= DefaultOptions().ImageFilter.MaxDistance) != test.same {
+				t.Errorf("distance = %d, same = %v", d, test.same)
+			}
+		})
+	}
+}
+
+func TestImageFilter(t *testing.T) {
+	dir := t.TempDir()
+	logo := writeTestImage(t, filepath.Join(dir, "logo.png"), testImage(64, false))
+	stripes := writeTestImage(t, filepath.Join(dir, "stripes.png"), testImage(64, true))
+	logoHash, err := HashImageFile(logo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		options ImageFilterOptions
+		images  []string
+		want    []bool
+	}{
+		{"disabled", ImageFilterOptions{MaxDistance: 4}, []string{logo, logo, logo}, []bool{false, false, false}},
+		{"once", ImageFilterOptions{MaxRepeats: 1, MaxDistance: 4}, []string{logo, logo, stripes, logo, stripes}, []bool{false, true, false, true, true}},
+		{"twice", ImageFilterOptions{MaxRepeats: 2, MaxDistance: 4}, []string{logo, logo, logo}, []bool{false, false, true}},
+		{"ignored", ImageFilterOptions{Ignore: []string{FormatImageHash(logoHash)}, MaxDistance: 4}, []string{logo, stripes}, []bool{true, false}},
+		{"not an image", ImageFilterOptions{MaxRepeats: 1, MaxDistance: 4}, []string{filepath.Join(dir, "missing.png"), filepath.Join(dir, "missing.png")}, []bool{false, false}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter := newImageFilter(&test.options)
+			got := make([]bool, 0)
+			for _, p := range test.images {
+				got = append(got, filter.skip(p))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("skip() = %v, want %v", got, test.want)
+			}
+			// a new document starts from scratch
+			if test.options.MaxRepeats > 0 && newImageFilter(&test.options).skip(test.images[0]) {
+				t.Errorf("a new filter skipped %s", test.images[0])
+			}
+		})
+	}
+}
+
+func TestIsPageBackground(t *testing.T) {
+	tests := []struct {
+		filename   string
+		page       int
+		background bool
+	}{
+		{"content-3_1.jpg", 3, false},
+		{"content-12_2.png", 12, false},
+		{"content3.png", 3, true},
+		{"page.png", 1, false},
+	}
+	for _, test := range tests {
+		if got := imagePage(test.filename); got != test.page {
+			t.Errorf("imagePage(%q) = %d, want %d", test.filename, got, test.page)
+		}
+		if got := isPageBackground(test.filename); got != test.background {
+			t.Errorf("isPageBackground(%q) = %v, want %v", test.filename, got, test.background)
+		}
+	}
+}
+
+// fakePdftohtml writes the logo next to it as the only embedded image of the
+// page and as its background.
+const fakePdftohtml = `#!/bin/sh
+eval "out=\${$#}"
+cp "$(dirname "$0")/logo.png" "$out-$3_1.png"
+cp "$(dirname "$0")/logo.png" "$out$3.png"
+`
+
+// TestPageImagesFilter checks that a logo embedded in every page is ocr'd
+// once, while the page backgrounds are always ocr'd.
+func TestPageImagesFilter(t *testing.T) {
+	calls := installFakeTools(t)
+	bin := filepath.Dir(calls)
+	writeTestImage(t, filepath.Join(bin, "logo.png"), testImage(64, false))
+	err := os.WriteFile(filepath.Join(bin, "pdftohtml"), []byte(fakePdftohtml), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := DefaultOptions()
+	options.CommandTimeout = 10 * time.Second
+	options.ImageFilter.MaxRepeats = 1
+	filter := newImageFilter(&options.ImageFilter)
+	dir := t.TempDir()
+	for page := 1; page <= 3; page++ {
+		pageDir := filepath.Join(dir, strings.Repeat("p", page))
+		err := os.Mkdir(pageDir, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = getPageImagesText(context.Background(), options, filter, pageDir, "content.pdf", page)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	log, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// three backgrounds and the first copy of the logo
+	if n := strings.Count(string(log), "tesseract "); n != 4 {
+		t.Errorf("tesseract ran %d times, want 4:\n%s", n, log)
+	}
+}
@@ -0,0 +1,220 @@
+package extracttext
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxSkew is the largest rotation, in degrees, that deskewing corrects.
+const maxSkew = 5.0
+
+func readGray(p string) (*image.Gray, error) {
+	fp, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	img, _, err := image.Decode(fp)
+	if err != nil {
+		return nil, err
+	}
+	if gray, ok := img.(*image.Gray); ok {
+		return gray, nil
+	}
+	gray := image.NewGray(img.Bounds())
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+	return gray, nil
+}
+
+func writePNG(p string, img image.Image) error {
+	fp, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	return png.Encode(fp, img)
+}
+
+// otsuThreshold returns the gray level that best separates ink from paper.
+func otsuThreshold(img *image.Gray) uint8 {
+	var histogram [256]int
+	for _, v := range img.Pix {
+		histogram[v]++
+	}
+	total := len(img.Pix)
+	sum := 0.0
+	for i, count := range histogram {
+		sum += float64(i * count)
+	}
+	sumBackground := 0.0
+	weightBackground := 0
+	best := 0.0
+	threshold := uint8(128)
+	for i, count := range histogram {
+		weightBackground += count
+		if weightBackground == 0 {
+			continue
+		}
+		weightForeground := total - weightBackground
+		if weightForeground == 0 {
+			break
+		}
+		sumBackground += float64(i * count)
+		meanBackground := sumBackground / float64(weightBackground)
+		meanForeground := (sum - sumBackground) / float64(weightForeground)
+		variance := float64(weightBackground) * float64(weightForeground) * (meanBackground - meanForeground) * (meanBackground - meanForeground)
+		if variance > best {
+			best = variance
+			threshold = uint8(i)
+		}
+	}
+	return threshold
+}
+
+func binarize(img *image.Gray) *image.Gray {
+	threshold := otsuThreshold(img)
+	res := image.NewGray(img.Bounds())
+	for i, v := range img.Pix {
+		if v > threshold {
+			res.Pix[i] = 0xff
+		}
+	}
+	return res
+}
+
+// rotate rotates the image around its center, filling with white.
+func rotate(img *image.Gray, degrees float64) *image.Gray {
+	b := img.Bounds()
+	res := image.NewGray(b)
+	draw.Draw(res, b, &image.Uniform{color.Gray{Y: 0xff}}, image.Point{}, draw.Src)
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	cx := float64(b.Min.X+b.Max.X) / 2
+	cy := float64(b.Min.Y+b.Max.Y) / 2
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			sx := int(math.Round(cx + dx*cos + dy*sin))
+			sy := int(math.Round(cy - dx*sin + dy*cos))
+			if sx < b.Min.X || sx >= b.Max.X || sy < b.Min.Y || sy >= b.Max.Y {
+				continue
+			}
+			res.Pix[res.PixOffset(x, y)] = img.Pix[img.PixOffset(sx, sy)]
+		}
+	}
+	return res
+}
+
+// downscale shrinks the image by an integer factor keeping the darkest pixel,
+// so thin strokes survive.
+func downscale(img *image.Gray, factor int) *image.Gray {
+	b := img.Bounds()
+	res := image.NewGray(image.Rect(0, 0, b.Dx()/factor, b.Dy()/factor))
+	for y := 0; y < res.Rect.Dy(); y++ {
+		for x := 0; x < res.Rect.Dx(); x++ {
+			v := uint8(0xff)
+			for sy := 0; sy < factor; sy++ {
+				for sx := 0; sx < factor; sx++ {
+					p := img.Pix[img.PixOffset(b.Min.X+x*factor+sx, b.Min.Y+y*factor+sy)]
+					if p < v {
+						v = p
+					}
+				}
+			}
+			res.Pix[res.PixOffset(x, y)] = v
+		}
+	}
+	return res
+}
+
+// rowVariance measures how well the text lines are aligned with the rows:
+// horizontal lines produce rows that are either full of ink or empty.
+func rowVariance(img *image.Gray) float64 {
+	b := img.Bounds()
+	rows := make([]float64, b.Dy())
+	mean := 0.0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		ink := 0
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.Pix[img.PixOffset(x, y)] < 0x80 {
+				ink++
+			}
+		}
+		rows[y-b.Min.Y] = float64(ink)
+		mean += float64(ink)
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+	mean /= float64(len(rows))
+	variance := 0.0
+	for _, ink := range rows {
+		variance += (ink - mean) * (ink - mean)
+	}
+	return variance / float64(len(rows))
+}
+
+// skewAngle estimates the rotation that straightens the text lines.
+func skewAngle(img *image.Gray) float64 {
+	small := binarize(img)
+	if factor := small.Bounds().Dx() / 600; factor > 1 {
+		small = downscale(small, factor)
+	}
+	best := 0.0
+	bestVariance := rowVariance(small)
+	for angle := -maxSkew; angle <= maxSkew; angle += 0.5 {
+		if angle == 0 {
+			continue
+		}
+		variance := rowVariance(rotate(small, angle))
+		if variance > bestVariance {
+			best = angle
+			bestVariance = variance
+		}
+	}
+	return best
+}
+
+// preprocessImage deskews and binarizes a page image as configured, and
+// returns the path of the image to ocr.
+func preprocessImage(p string, options *Options) (string, error) {
+	if !options.Deskew && !options.Threshold {
+		return p, nil
+	}
+	img, err := readGray(p)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"image": p,
+			"error": err.Error(),
+		}).Error("failed to read page image")
+		return "", err
+	}
+	if options.Deskew {
+		if angle := skewAngle(img); angle != 0 {
+			log.WithFields(log.Fields{
+				"image": p,
+				"angle": angle,
+			}).Info("deskewing page image")
+			img = rotate(img, angle)
+		}
+	}
+	if options.Threshold {
+		img = binarize(img)
+	}
+	res := strings.TrimSuffix(p, ".png") + "-preprocessed.png"
+	err = writePNG(res, img)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"image": res,
+			"error": err.Error(),
+		}).Error("failed to write page image")
+		return "", err
+	}
+	return res, nil
+}
@@ -0,0 +1,159 @@
+package extracttext
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// rtfDestinations are the groups whose text is not part of the document.
+var rtfDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true, "pict": true,
+	"header": true, "headerl": true, "headerr": true, "headerf": true, "footer": true,
+	"footerl": true, "footerr": true, "footerf": true, "object": true, "themedata": true,
+	"datastore": true, "xmlnstbl": true, "listtable": true, "listoverridetable": true,
+	"rsidtbl": true, "generator": true, "latentstyles": true, "filetbl": true,
+	"revtbl": true, "colorschememapping": true, "fldinst": true,
+}
+
+type rtfGroup struct {
+	skip bool
+	// uc is the number of fallback characters after a \u character.
+	uc int
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// rtfText returns the text of an rtf document, with \page as form feeds.
+func rtfText(content []byte) string {
+	var b strings.Builder
+	groups := []rtfGroup{{uc: 1}}
+	fallback := 0
+	emit := func(s string) {
+		if fallback > 0 {
+			fallback--
+			return
+		}
+		if !groups[len(groups)-1].skip {
+			b.WriteString(s)
+		}
+	}
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch c {
+		case '{':
+			groups = append(groups, groups[len(groups)-1])
+			fallback = 0
+			i++
+		case '}':
+			if len(groups) > 1 {
+				groups = groups[:len(groups)-1]
+			}
+			fallback = 0
+			i++
+		case '\r', '\n':
+			i++
+		case '\\':
+			i++
+			if i >= len(content) {
+				break
+			}
+			c = content[i]
+			switch {
+			case c == '\'':
+				if i+2 < len(content) {
+					if v, err := strconv.ParseUint(string(content[i+1:i+3]), 16, 8); err == nil {
+						emit(string(windows1252Rune(byte(v))))
+					}
+				}
+				i += 3
+			case c == '*':
+				groups[len(groups)-1].skip = true
+				i++
+			case isASCIILetter(c):
+				start := i
+				for i < len(content) && isASCIILetter(content[i]) {
+					i++
+				}
+				word := string(content[start:i])
+				start = i
+				if i < len(content) && content[i] == '-' {
+					i++
+				}
+				for i < len(content) && content[i] >= '0' && content[i] <= '9' {
+					i++
+				}
+				param, hasParam := 0, false
+				if i > start {
+					param, _ = strconv.Atoi(string(content[start:i]))
+					hasParam = true
+				}
+				if i < len(content) && content[i] == ' ' {
+					i++
+				}
+				group := &groups[len(groups)-1]
+				switch {
+				case rtfDestinations[word]:
+					group.skip = true
+				case word == "par" || word == "line":
+					emit("\n")
+				case word == "page":
+					emit("\f")
+				case word == "tab" || word == "cell":
+					emit("\t")
+				case word == "row":
+					emit("\n")
+				case word == "emdash":
+					emit("—")
+				case word == "endash":
+					emit("–")
+				case word == "lquote":
+					emit("‘")
+				case word == "rquote":
+					emit("’")
+				case word == "ldblquote":
+					emit("“")
+				case word == "rdblquote":
+					emit("”")
+				case word == "bullet":
+					emit("•")
+				case word == "uc" && hasParam:
+					group.uc = param
+				case word == "u" && hasParam:
+					if param < 0 {
+						param += 65536
+					}
+					emit(string(rune(param)))
+					fallback = group.uc
+				}
+			default:
+				switch c {
+				case '\\', '{', '}':
+					emit(string(c))
+				case '~':
+					emit("\u00a0")
+				case '_':
+					emit("-")
+				case '\r', '\n':
+					emit("\n")
+				}
+				i++
+			}
+		default:
+			emit(string(windows1252Rune(c)))
+			i++
+		}
+	}
+	return b.String()
+}
+
+func extractRTF(ctx context.Context, r io.Reader, options *Options) (*Result, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return textResult(rtfText(content)), nil
+}
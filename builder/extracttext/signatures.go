@@ -0,0 +1,71 @@
+package extracttext
+
+import (
+	"github.com/odia/juscaba/pdf"
+	shared "github.com/odia/juscaba/shared"
+)
+
+// GetPDFSignatures finds the digital signatures of a pdf and validates them
+// against the signed bytes.
+func GetPDFSignatures(content []byte) (signatures []shared.Signature, err error) {
+	defer recoverPDF(&err)
+	r, err := pdf.Open(content)
+	if err != nil {
+		return nil, err
+	}
+	found := r.Signatures()
+	// a later signature that covers the whole file accepts the updates
+	// made after the earlier ones, as when an actuacion is co-signed
+	resigned := false
+	for _, sig := range found {
+		if sig.ByteRange[0] == 0 && sig.ByteRange[2]+sig.ByteRange[3] == r.Size() {
+			resigned = true
+		}
+	}
+	signatures = make([]shared.Signature, 0, len(found))
+	for _, sig := range found {
+		end := sig.ByteRange[2] + sig.ByteRange[3]
+		s := shared.Signature{
+			Field:                sig.Field,
+			Signer:               sig.Name,
+			Reason:               sig.Reason,
+			Location:             sig.Location,
+			SubFilter:            sig.SubFilter,
+			CoversWholeDocument:  sig.ByteRange[0] == 0 && end == r.Size(),
+			SignedAgain:          end < r.Size() && resigned,
+			ModifiedAfterSigning: end != r.Size() && !resigned,
+		}
+		if !sig.Time.IsZero() {
+			t := sig.Time
+			s.SigningTime = &t
+		}
+		signed, ok := r.SignedData(sig)
+		if !ok || sig.ByteRange[0] != 0 {
+			s.Error = "invalid byte range"
+			signatures = append(signatures, s)
+			continue
+		}
+		v, err := pdf.VerifySignature(sig, signed)
+		if v != nil && v.Certificate != nil {
+			s.Signer = v.Certificate.Subject.CommonName
+			s.Issuer = v.Certificate.Issuer.CommonName
+			if s.Signer == "" {
+				s.Signer = sig.Name
+			}
+		}
+		if v != nil && !v.SigningTime.IsZero() {
+			t := v.SigningTime
+			s.SigningTime = &t
+		}
+		if err == nil && v.Certificate != nil && s.SigningTime != nil &&
+			(s.SigningTime.Before(v.Certificate.NotBefore) || s.SigningTime.After(v.Certificate.NotAfter)) {
+			err = pdf.ErrInvalidSignature
+			s.Error = "certificate not valid at signing time"
+		} else if err != nil {
+			s.Error = err.Error()
+		}
+		s.Valid = err == nil
+		signatures = append(signatures, s)
+	}
+	return signatures, nil
+}
@@ -0,0 +1,58 @@
+package extracttext
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// signedUpdate is an incremental update that changes nothing, appended
+// after the last signature.
+const signedUpdate = "\n% update after signing\n"
+
+func TestGetPDFSignatures(t *testing.T) {
+	type want struct {
+		valid, coversWholeDocument, modifiedAfterSigning, signedAgain bool
+	}
+	tests := []struct {
+		name     string
+		file     string
+		appended string
+		want     []want
+	}{
+		{"signed", "signed.pdf", "", []want{{true, true, false, false}}},
+		{"tampered", "signed-tampered.pdf", "", []want{{false, true, false, false}}},
+		{"appended", "signed-appended.pdf", "", []want{{true, false, true, false}}},
+		// the first signature is followed by the update that adds the
+		// second one, which covers the whole file
+		{"signed twice", "signed-twice.pdf", "", []want{{true, false, false, true}, {true, true, false, false}}},
+		// an update after the last signature is not covered by any
+		{"signed twice and updated", "signed-twice.pdf", signedUpdate, []want{{true, false, true, false}, {true, false, true, false}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			content = append(content, test.appended...)
+			signatures, err := GetPDFSignatures(content)
+			if err != nil {
+				t.Fatalf("GetPDFSignatures() error = %v", err)
+			}
+			if len(signatures) != len(test.want) {
+				t.Fatalf("got %d signatures, want %d", len(signatures), len(test.want))
+			}
+			for i, w := range test.want {
+				s := signatures[i]
+				got := want{s.Valid, s.CoversWholeDocument, s.ModifiedAfterSigning, s.SignedAgain}
+				if got != w {
+					t.Errorf("signature %d (valid, covers, modified, signed again) = %v, want %v (%s)", i, got, w, s.Error)
+				}
+				if s.Signer != "PEREZ Juan Carlos" {
+					t.Errorf("signature %d signer = %q", i, s.Signer)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package extracttext
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	shared "github.com/odia/juscaba/shared"
+)
+
+// minTableRows is the number of rows a block of aligned lines needs to be
+// considered a table.
+const minTableRows = 3
+
+// maxCellChars is the mean length of the cells of at least two columns of
+// a table, longer cells are usually justified running text.
+const maxCellChars = 20
+
+var cellSeparator = regexp.MustCompile(`\S\s{3,}\S`)
+
+// isTableLine tells if a line of layout text has cells separated by three or
+// more spaces.
+func isTableLine(line string) bool {
+	return cellSeparator.MatchString(line)
+}
+
+// gutters returns the character columns that are blank in every line, as
+// ranges of at least two columns between text.
+func gutters(lines [][]rune) [][2]int {
+	width := 0
+	for _, line := range lines {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+	blank := make([]bool, width)
+	start := width
+	for c := 0; c < width; c++ {
+		blank[c] = true
+		for _, line := range lines {
+			if c < len(line) && line[c] != ' ' {
+				blank[c] = false
+				if c < start {
+					start = c
+				}
+				break
+			}
+		}
+	}
+	ranges := make([][2]int, 0)
+	for c := start; c < width; {
+		if !blank[c] {
+			c++
+			continue
+		}
+		end := c
+		for end < width && blank[end] {
+			end++
+		}
+		if end-c >= 2 && end < width {
+			ranges = append(ranges, [2]int{c, end})
+		}
+		c = end
+	}
+	return ranges
+}
+
+// blockTable splits the aligned lines of a block into cells at the gutters,
+// and returns nil when they do not look like a table.
+func blockTable(block []string) [][]string {
+	lines := make([][]rune, 0, len(block))
+	for _, line := range block {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, []rune(line))
+		}
+	}
+	if len(lines) < minTableRows {
+		return nil
+	}
+	separators := gutters(lines)
+	// lines of text before or after the table hide its gutters
+	for len(lines) > minTableRows {
+		if g := gutters(lines[:len(lines)-1]); len(g) > len(separators) {
+			lines, separators = lines[:len(lines)-1], g
+		} else if g := gutters(lines[1:]); len(g) > len(separators) {
+			lines, separators = lines[1:], g
+		} else {
+			break
+		}
+	}
+	if len(separators) == 0 {
+		return nil
+	}
+	rows := make([][]string, 0, len(lines))
+	full := 0
+	for _, line := range lines {
+		row := make([]string, 0, len(separators)+1)
+		start := 0
+		for _, gutter := range separators {
+			row = append(row, cell(line, start, gutter[0]))
+			start = gutter[1]
+		}
+		row = append(row, cell(line, start, len(line)))
+		filled := 0
+		for _, c := range row {
+			if c != "" {
+				filled++
+			}
+		}
+		if filled >= 2 {
+			full++
+		}
+		rows = append(rows, row)
+	}
+	// most rows must have at least two cells
+	if full*2 < len(rows) || shortColumns(rows) < 2 {
+		return nil
+	}
+	return rows
+}
+
+// shortColumns counts the columns whose cells have a mean length of at most
+// maxCellChars.
+func shortColumns(rows [][]string) int {
+	short := 0
+	for c := range rows[0] {
+		chars, cells := 0, 0
+		for _, row := range rows {
+			if row[c] != "" {
+				chars += utf8.RuneCountInString(row[c])
+				cells++
+			}
+		}
+		if cells > 0 && chars <= cells*maxCellChars {
+			short++
+		}
+	}
+	return short
+}
+
+func cell(line []rune, start, end int) string {
+	if start >= len(line) {
+		return ""
+	}
+	if end > len(line) {
+		end = len(line)
+	}
+	return strings.TrimSpace(string(line[start:end]))
+}
+
+// DetectTables finds blocks of consecutive lines, in text with the physical
+// layout of the page, whose columns are aligned.
+func DetectTables(page int, text string) []shared.Table {
+	tables := make([]shared.Table, 0)
+	block := make([]string, 0)
+	blanks := 0
+	flush := func() {
+		if rows := blockTable(block); rows != nil {
+			tables = append(tables, shared.Table{Page: page, Rows: rows})
+		}
+		block = block[:0]
+		blanks = 0
+	}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.ReplaceAll(strings.TrimRight(line, " \f\r"), "\t", " ")
+		switch {
+		case strings.TrimSpace(line) == "":
+			// rows are often separated by a blank line
+			blanks++
+			if blanks > 1 {
+				flush()
+			}
+		case isTableLine(line) && utf8.ValidString(line):
+			block = append(block, line)
+			blanks = 0
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tables
+}
+
+// pagesTables finds the tables of the layout text of every page.
+func pagesTables(texts []string) []shared.Table {
+	tables := make([]shared.Table, 0)
+	for i, text := range texts {
+		tables = append(tables, DetectTables(i+1, text)...)
+	}
+	return tables
+}
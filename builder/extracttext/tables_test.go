@@ -0,0 +1,147 @@
+package extracttext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	shared "github.com/odia/juscaba/shared"
+)
+
+func TestDetectTables(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []shared.Table
+	}{
+		{
+			"aligned columns",
+			strings.Join([]string{
+				"Concepto            Fecha          Monto",
+				"Capital             01/02/2020     1.000,00",
+				"Intereses           01/03/2020     150,00",
+				"Costas              01/04/2020     80,50",
+			}, "\n"),
+			[]shared.Table{{Page: 2, Rows: [][]string{
+				{"Concepto", "Fecha", "Monto"},
+				{"Capital", "01/02/2020", "1.000,00"},
+				{"Intereses", "01/03/2020", "150,00"},
+				{"Costas", "01/04/2020", "80,50"},
+			}}},
+		},
+		{
+			"rows separated by blank lines and empty cells",
+			strings.Join([]string{
+				"Nombre        Rol           Fecha",
+				"",
+				"Perez         Actor         01/02/2020",
+				"",
+				"Gomez                       03/04/2020",
+				"Lopez         Perito        05/06/2020",
+			}, "\n"),
+			[]shared.Table{{Page: 2, Rows: [][]string{
+				{"Nombre", "Rol", "Fecha"},
+				{"Perez", "Actor", "01/02/2020"},
+				{"Gomez", "", "03/04/2020"},
+				{"Lopez", "Perito", "05/06/2020"},
+			}}},
+		},
+		{
+			"text around the table",
+			strings.Join([]string{
+				"VISTOS los autos caratulados, de los que surge la siguiente liquidación:",
+				"Capital         1.000,00     01/02/2020",
+				"Intereses       150,00       01/03/2020",
+				"Costas          80,50        01/04/2020",
+				"Por ello, se resuelve aprobar la liquidación practicada.",
+			}, "\n"),
+			[]shared.Table{{Page: 2, Rows: [][]string{
+				{"Capital", "1.000,00", "01/02/2020"},
+				{"Intereses", "150,00", "01/03/2020"},
+				{"Costas", "80,50", "01/04/2020"},
+			}}},
+		},
+		{
+			"two tables",
+			strings.Join([]string{
+				"a     b",
+				"c     d",
+				"e     f",
+				"",
+				"",
+				"1     2",
+				"3     4",
+				"5     6",
+			}, "\n"),
+			[]shared.Table{
+				{Page: 2, Rows: [][]string{{"a", "b"}, {"c", "d"}, {"e", "f"}}},
+				{Page: 2, Rows: [][]string{{"1", "2"}, {"3", "4"}, {"5", "6"}}},
+			},
+		},
+		{
+			"too few rows",
+			"Capital      1.000,00\nIntereses    150,00",
+			[]shared.Table{},
+		},
+		{
+			"running text",
+			strings.Join([]string{
+				"Que en   atención a lo dispuesto   por el artículo primero de la ley",
+				"citada corresponde hacer   lugar a lo solicitado por   la parte actora",
+				"en su escrito de fecha   reciente, con costas a la   vencida en juicio",
+			}, "\n"),
+			[]shared.Table{},
+		},
+		{
+			"justified paragraphs",
+			strings.Join([]string{
+				"Se resuelve hacer lugar a la medida cautelar solicitada por la parte actora.",
+				"Notifíquese a las partes por cédula electrónica y oportunamente archívese.",
+				"Regístrese y comuníquese a la Secretaría General de Asuntos Judiciales.",
+			}, "\n"),
+			[]shared.Table{},
+		},
+		{
+			"empty",
+			"",
+			[]shared.Table{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := DetectTables(2, test.text)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("DetectTables() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGutters(t *testing.T) {
+	tests := []struct {
+		lines []string
+		want  [][2]int
+	}{
+		{[]string{"ab   cd", "ef   gh"}, [][2]int{{2, 5}}},
+		{[]string{"ab   cd", "ef gh"}, [][2]int{}},
+		{[]string{"  ab  cd", "  ef  gh"}, [][2]int{{4, 6}}},
+		{[]string{"ab   ", "cd"}, [][2]int{}},
+	}
+	for _, test := range tests {
+		lines := make([][]rune, len(test.lines))
+		for i, line := range test.lines {
+			lines[i] = []rune(line)
+		}
+		if got := gutters(lines); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("gutters(%q) = %v, want %v", test.lines, got, test.want)
+		}
+	}
+}
+
+func TestPagesTables(t *testing.T) {
+	table := "a     b\nc     d\ne     f"
+	got := pagesTables([]string{"texto", table, table})
+	if len(got) != 2 || got[0].Page != 2 || got[1].Page != 3 {
+		t.Errorf("pagesTables() = %v, want tables in pages 2 and 3", got)
+	}
+}
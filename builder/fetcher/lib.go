@@ -1,7 +1,7 @@
 package fetcher
 
 import (
-	"crypto/sha1"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -10,6 +10,9 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// ErrStatus is returned when the server does not answer with a 2xx status.
+var ErrStatus = errors.New("unexpected http status")
+
 func Download(s *shared.FileManager, url string) error {
 	if s.IsSaved(url) {
 		log.WithFields(log.Fields{
@@ -17,6 +20,17 @@ func Download(s *shared.FileManager, url string) error {
 		}).Printf("skipping url")
 		return nil
 	}
+	return Refetch(s, url)
+}
+
+// Refetch downloads the url even if it was already saved, replacing the
+// saved file. When the content changed, the previous content file is removed
+// unless another url has the same content.
+func Refetch(s *shared.FileManager, url string) error {
+	var previous *shared.SavedFile
+	if s.IsSaved(url) {
+		previous, _ = s.SavedFileForURL(url)
+	}
 	res, err := http.Get(url)
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -26,6 +40,13 @@ func Download(s *shared.FileManager, url string) error {
 		return err
 	}
 	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		log.WithFields(log.Fields{
+			"status": res.Status,
+			"url":    url,
+		}).Warn("Failed to get url")
+		return fmt.Errorf("%w: %s", ErrStatus, res.Status)
+	}
 
 	content, err := ioutil.ReadAll(res.Body)
 	if err != nil {
@@ -36,16 +57,23 @@ func Download(s *shared.FileManager, url string) error {
 		return err
 	}
 
-	h := sha1.New()
-	_, err = h.Write(content)
+	savedFile := shared.NewSavedFile(url, "")
+	savedFile.SetContent(content)
+	savedFile.DestinationFilename = savedFile.ContentHash + shared.ContentTypeExtension(savedFile.ContentType)
+	err = s.SaveSavedFile(savedFile, content)
 	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err.Error(),
-			"url":   url,
-		}).Error("Failed to create hash")
 		return err
 	}
-
-	savedFile := shared.NewSavedFile(url, fmt.Sprintf("%x.pdf", h.Sum(nil)))
-	return s.SaveSavedFile(savedFile, content)
+	if previous != nil && previous.DestinationFilename != savedFile.DestinationFilename {
+		err = s.RemoveUnreferencedFile(previous)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"url":   url,
+				"file":  previous.DestinationFilename,
+			}).Error("Failed to remove previous content file")
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,109 @@
+package fetcher
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/odia/juscaba/shared"
+)
+
+// testServer answers every path with the content in the map, and 404 for
+// the rest.
+func testServer(t *testing.T, contents map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, found := contents[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRefetchStatus(t *testing.T) {
+	server := testServer(t, map[string]string{})
+	fm := &shared.FileManager{Directory: t.TempDir()}
+	defer fm.Close()
+	err := Refetch(fm, server.URL+"/missing.pdf")
+	if !errors.Is(err, ErrStatus) {
+		t.Errorf("Refetch() error = %v, want %v", err, ErrStatus)
+	}
+	if fm.IsSaved(server.URL + "/missing.pdf") {
+		t.Errorf("the error page was saved")
+	}
+}
+
+func TestRefetchChangedContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		shared bool
+	}{
+		{"previous file removed", false},
+		{"previous file kept for another url", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contents := map[string]string{"/a.txt": "primera version", "/b.txt": "primera version"}
+			server := testServer(t, contents)
+			fm := &shared.FileManager{Directory: t.TempDir()}
+			defer fm.Close()
+			err := Download(fm, server.URL+"/a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.shared {
+				err = Download(fm, server.URL+"/b.txt")
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			previous, err := fm.SavedFileForURL(server.URL + "/a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// a cache entry derived from the previous content
+			cacheKey := previous.Hash() + "-text"
+			err = fm.WriteCache(cacheKey, "primera version")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			contents["/a.txt"] = "segunda version"
+			err = Refetch(fm, server.URL+"/a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			current, err := fm.SavedFileForURL(server.URL + "/a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if current.DestinationFilename == previous.DestinationFilename {
+				t.Fatalf("the index entry was not updated")
+			}
+			if _, err := os.Stat(fm.DestinationPath(current)); err != nil {
+				t.Errorf("the new content file is missing: %v", err)
+			}
+			_, err = os.Stat(path.Join(fm.Directory, previous.DestinationFilename))
+			if exists := err == nil; exists != test.shared {
+				t.Errorf("previous content file exists = %v, want %v", exists, test.shared)
+			}
+			var cached string
+			if found := fm.ReadCache(cacheKey, &cached); found != test.shared {
+				t.Errorf("previous cache entry exists = %v, want %v", found, test.shared)
+			}
+			orphans, err := fm.OrphanFiles()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(orphans) > 0 {
+				t.Errorf("OrphanFiles() = %v, want none", orphans)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	shared "github.com/odia/juscaba/shared"
+	log "github.com/sirupsen/logrus"
+)
+
+func gc(argv []string) error {
+	var pdfsPath, quarantine string
+	var dryRun bool
+	flags := newFlagSet("gc")
+	flags.StringVar(&pdfsPath, "pdfs", "", "pdfs path")
+	flags.StringVar(&quarantine, "quarantine", "", "move unreferenced files to this directory instead of deleting them")
+	flags.BoolVar(&dryRun, "dry-run", false, "only report what would be removed")
+	flags.Parse(argv)
+
+	if flags.NArg() == 0 {
+		log.Error("gc needs at least one expediente json, refusing to remove every file")
+		return errors.New("no expediente json given")
+	}
+	referenced := map[string]bool{}
+	for _, p := range flags.Args() {
+		exp, err := shared.ReadExpediente(p)
+		if err != nil {
+			return err
+		}
+		for _, doc := range exp.Documentos() {
+			referenced[doc.URL] = true
+		}
+	}
+
+	fm := &shared.FileManager{Directory: pdfsPath}
+	defer fm.Close()
+	report, err := fm.CollectGarbage(referenced, quarantine, dryRun)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"pdfs":  pdfsPath,
+			"error": err.Error(),
+		}).Error("failed to collect garbage")
+		return err
+	}
+	if !dryRun {
+		idx, err := fm.Index()
+		if err != nil {
+			return err
+		}
+		err = idx.Compact()
+		if err != nil {
+			return err
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(report)
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"dryRun":       dryRun,
+		"keptFiles":    report.KeptFiles,
+		"keptBytes":    report.KeptBytes,
+		"removedFiles": report.RemovedFiles,
+		"removedBytes": report.RemovedBytes,
+	}).Info("collected garbage")
+	return nil
+}
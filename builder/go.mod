@@ -2,6 +2,9 @@ module github.com/odia/juscaba
 
 go 1.17
 
-require github.com/sirupsen/logrus v1.8.1
+require (
+	github.com/sirupsen/logrus v1.8.1
+	golang.org/x/text v0.13.0
+)
 
-require golang.org/x/sys v0.0.0-20191026070338-33540a1f6037 // indirect
+require golang.org/x/sys v0.5.0 // indirect
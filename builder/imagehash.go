@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	extracttext "github.com/odia/juscaba/extracttext"
+	log "github.com/sirupsen/logrus"
+)
+
+// imageHash prints the perceptual hash of every image given, in the format
+// taken by -ocr-ignore-image.
+func imageHash(argv []string) error {
+	flags := newFlagSet("image-hash")
+	flags.Parse(argv)
+
+	failed := 0
+	for _, p := range flags.Args() {
+		hash, err := extracttext.HashImageFile(p)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"path":  p,
+				"error": err.Error(),
+			}).Error("failed to hash image")
+			failed++
+			continue
+		}
+		fmt.Printf("%s  %s\n", extracttext.FormatImageHash(hash), p)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to hash %d images", failed)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"time"
+
+	shared "github.com/odia/juscaba/shared"
+	log "github.com/sirupsen/logrus"
+)
+
+func migrateIndex(argv []string) error {
+	var pdfsPath string
+	var remove bool
+	flags := newFlagSet("migrate-index")
+	flags.StringVar(&pdfsPath, "pdfs", "", "pdfs path")
+	flags.BoolVar(&remove, "remove", false, "remove the per-url metadata files after importing them")
+	flags.Parse(argv)
+
+	idx, err := shared.OpenIndex(path.Join(pdfsPath, shared.IndexFilename))
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	migrated, err := shared.MigrateLegacyMetadata(idx, pdfsPath, remove)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"pdfs":  pdfsPath,
+			"error": err.Error(),
+		}).Error("failed to migrate metadata files")
+		return err
+	}
+	err = idx.Compact()
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"pdfs":     pdfsPath,
+		"migrated": migrated,
+		"total":    idx.Len(),
+	}).Info("migrated metadata files")
+	return nil
+}
+
+func queryIndex(argv []string) error {
+	var pdfsPath, fetchedBefore, hash, url string
+	flags := newFlagSet("query")
+	flags.StringVar(&pdfsPath, "pdfs", "", "pdfs path")
+	flags.StringVar(&fetchedBefore, "fetched-before", "", "only files fetched before this date (RFC 3339 or YYYY-MM-DD)")
+	flags.StringVar(&hash, "hash", "", "only files with this content hash")
+	flags.StringVar(&url, "url", "", "only the file saved for this source url")
+	flags.Parse(argv)
+
+	var before time.Time
+	if fetchedBefore != "" {
+		var err error
+		before, err = time.Parse(time.RFC3339, fetchedBefore)
+		if err != nil {
+			before, err = time.Parse("2006-01-02", fetchedBefore)
+		}
+		if err != nil {
+			log.WithFields(log.Fields{
+				"fetchedBefore": fetchedBefore,
+			}).Error("failed to parse date")
+			return errors.New("invalid date")
+		}
+	}
+
+	fm := &shared.FileManager{Directory: pdfsPath}
+	defer fm.Close()
+	idx, err := fm.Index()
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	for _, sf := range idx.Find(func(sf *shared.SavedFile) bool {
+		if url != "" && sf.SourceURL != url {
+			return false
+		}
+		if hash != "" && sf.Hash() != hash {
+			return false
+		}
+		if !before.IsZero() && !sf.FetchDate.Before(before) {
+			return false
+		}
+		return true
+	}) {
+		encoder.Encode(sf)
+	}
+	return nil
+}
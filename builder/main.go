@@ -1,15 +1,20 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
 	"regexp"
+	"strings"
 
 	crawler "github.com/odia/juscaba/crawler"
 	extracttext "github.com/odia/juscaba/extracttext"
 	fetcher "github.com/odia/juscaba/fetcher"
 	shared "github.com/odia/juscaba/shared"
+	warc "github.com/odia/juscaba/warc"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -18,11 +23,14 @@ type arguments struct {
 	jsonPath       string
 	fm             *shared.FileManager
 	exp            *shared.Expediente
-	parseImages    bool
+	extractOptions *extracttext.Options
+	snapshotPath   string
+	snapshotKey    ed25519.PrivateKey
+	warc           *warc.Writer
 }
 
 func parseArguments() (*arguments, error) {
-	var mirrorBaseURL, pdfsPath, expId string
+	var mirrorBaseURL, pdfsPath, expId, warcPath, extractConfig, snapshotSecret string
 	var err error
 	args := arguments{}
 	flag.StringVar(&args.blacklistRegex, "blacklist", "", "regex of urls to ignore (e.g.: \"(cedulas.*667442)|(actuaciones.*349676)\")")
@@ -30,76 +38,369 @@ func parseArguments() (*arguments, error) {
 	flag.StringVar(&pdfsPath, "pdfs", "", "pdfs destination path")
 	flag.StringVar(&expId, "expediente", "", "expediente identifier (e.g.: \"182908/2020-0\")")
 	flag.StringVar(&mirrorBaseURL, "mirror-base-url", "", "base url for documents")
-	flag.BoolVar(&args.parseImages, "images", true, "apply ocr")
-	flag.Parse()
+	args.extractOptions = extracttext.DefaultOptions()
+	extractFlags(flag.CommandLine, args.extractOptions, &extractConfig)
+	flag.StringVar(&args.snapshotPath, "snapshot", "", "signed snapshot destination path")
+	flag.StringVar(&snapshotSecret, "snapshot-key", "snapshot-key", "name of the secret with the ed25519 key to sign the snapshot")
+	flag.StringVar(&warcPath, "warc", "", "warc destination path, records every request (e.g.: \"crawl.warc.gz\")")
+	flag.Usage = usage
+	err = parseExtractFlags(flag.CommandLine, os.Args[1:], args.extractOptions, &extractConfig)
+	if err != nil {
+		return nil, err
+	}
 
 	log.WithFields(log.Fields{
-		"json":          args.jsonPath,
-		"pdfs":          pdfsPath,
-		"expediente":    expId,
-		"parseImages":   args.parseImages,
-		"mirrorBaseURL": mirrorBaseURL,
+		"json":           args.jsonPath,
+		"pdfs":           pdfsPath,
+		"expediente":     expId,
+		"pdfBackend":     args.extractOptions.Backend(),
+		"parseImages":    args.extractOptions.Images,
+		"ocrMinChars":    args.extractOptions.MinTextChars,
+		"tesseract":      args.extractOptions.Tesseract,
+		"ocrLayout":      args.extractOptions.Layout,
+		"ocrImageFilter": args.extractOptions.ImageFilter,
+		"mirrorBaseURL":  mirrorBaseURL,
+		"warc":           warcPath,
 	}).Print("arguments")
 
+	// the key is read before crawling, so a missing secret does not waste
+	// the whole run
+	if args.snapshotPath != "" {
+		args.snapshotKey, err = readSnapshotKey(snapshotSecret)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if warcPath != "" {
+		args.warc, err = warc.NewWriter(warcPath, "juscaba builder")
+		if err != nil {
+			return nil, err
+		}
+		warc.Install(args.warc)
+	}
+
 	args.fm = &shared.FileManager{
 		Directory:     pdfsPath,
 		MirrorBaseURL: mirrorBaseURL,
 	}
 	args.exp, err = crawler.GetExpediente(expId)
 	if err != nil {
+		if args.warc != nil {
+			args.warc.Close()
+		}
 		return nil, err
 	}
 
 	return &args, nil
 }
 
-func main() {
-	args, err := parseArguments()
+// extractFlags registers the text extraction flags in fs, over options, and
+// -extract-config in config.
+func extractFlags(fs *flag.FlagSet, options *extracttext.Options, config *string) {
+	fs.StringVar(&options.PDFBackend, "pdf-backend", options.PDFBackend, "how to read the text layer of pdfs: poppler runs pdftotext, go uses a pure go parser, auto picks poppler when installed")
+	fs.BoolVar(&options.PreserveLayout, "layout", options.PreserveLayout, "keep the physical layout of the pdf text, like pdftotext -layout")
+	fs.BoolVar(&options.Tables, "tables", options.Tables, "find tables in pdfs and save them as csv")
+	fs.BoolVar(&options.Codes, "codes", options.Codes, "decode the qr codes and barcodes of pdf pages and images with zbarimg")
+	fs.BoolVar(&options.Images, "images", options.Images, "apply ocr to pages without a usable text layer")
+	fs.IntVar(&options.MinTextChars, "ocr-min-chars", options.MinTextChars, "pages whose text layer has fewer characters are ocr'd")
+	fs.StringVar(&options.Rasterizer, "ocr-rasterizer", options.Rasterizer, "how to get page images: pdftoppm renders pages, pdftohtml extracts embedded images")
+	fs.IntVar(&options.DPI, "ocr-dpi", options.DPI, "resolution of rendered pages")
+	fs.BoolVar(&options.Grayscale, "ocr-gray", options.Grayscale, "render pages in grayscale")
+	fs.BoolVar(&options.Deskew, "ocr-deskew", options.Deskew, "straighten rendered pages before ocr")
+	fs.BoolVar(&options.Threshold, "ocr-threshold", options.Threshold, "binarize rendered pages before ocr")
+	fs.Func("ocr-languages", "tesseract languages joined by + (default \"spa\")", func(s string) error {
+		options.Tesseract.Languages = strings.Split(s, "+")
+		return nil
+	})
+	fs.IntVar(&options.Tesseract.PSM, "ocr-psm", options.Tesseract.PSM, "tesseract page segmentation mode, -1 for tesseract's default")
+	fs.IntVar(&options.Tesseract.OEM, "ocr-oem", options.Tesseract.OEM, "tesseract ocr engine mode, -1 for tesseract's default")
+	fs.StringVar(&options.Tesseract.UserWords, "ocr-user-words", options.Tesseract.UserWords, "file with extra words for tesseract, one per line")
+	fs.Func("ocr-var", "tesseract config variable as name=value, can be repeated", func(s string) error {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 {
+			return errors.New("expected name=value")
+		}
+		if options.Tesseract.Variables == nil {
+			options.Tesseract.Variables = map[string]string{}
+		}
+		options.Tesseract.Variables[parts[0]] = parts[1]
+		return nil
+	})
+	fs.Func("ocr-ignore-image", "perceptual hash, from the image-hash command, of an image never ocr'd, can be repeated", func(s string) error {
+		options.ImageFilter.Ignore = append(options.ImageFilter.Ignore, s)
+		return nil
+	})
+	fs.IntVar(&options.ImageFilter.MaxRepeats, "ocr-max-image-repeats", options.ImageFilter.MaxRepeats, "times the same image, e.g. a letterhead, is ocr'd, 0 ocrs every copy")
+	fs.Func("normalize", "clean up the extracted text: true, false or a comma separated list of unicode, headers-footers, dehyphenate, junk and whitespace (default true)", func(s string) error {
+		return options.Normalize.Set(s)
+	})
+	fs.BoolVar(&options.Normalize.KeepRaw, "raw-content", options.Normalize.KeepRaw, "keep the text as extracted in rawContent when the normalization changed it")
+	fs.StringVar(config, "extract-config", "", "json file with text extraction settings, flags take precedence")
+	fs.StringVar(&options.Layout, "ocr-layout", options.Layout, "save the position of ocr'd words as hocr or alto")
+	fs.IntVar(&options.Workers, "ocr-workers", options.Workers, "pages ocr'd in parallel")
+	fs.DurationVar(&options.CommandTimeout, "command-timeout", options.CommandTimeout, "timeout of each pdftotext, pdftohtml and tesseract call")
+	fs.DurationVar(&options.DocumentTimeout, "document-timeout", options.DocumentTimeout, "timeout of the extraction of each document")
+}
+
+// parseExtractFlags parses the command line. With -extract-config the file
+// is read over the default options and the command line is parsed again, so
+// the flags given take precedence over the file.
+func parseExtractFlags(fs *flag.FlagSet, argv []string, options *extracttext.Options, config *string) error {
+	err := fs.Parse(argv)
+	if err != nil || *config == "" {
+		return err
+	}
+	*options = *extracttext.DefaultOptions()
+	err = extracttext.LoadOptions(*config, options)
 	if err != nil {
-		os.Exit(1)
+		return err
 	}
-	log.WithFields(log.Fields{
-		"expediente":  args.exp,
-		"actuaciones": len(args.exp.Actuaciones),
-	}).Printf("finished")
+	return fs.Parse(argv)
+}
+
+// extractDocument sets the text of a documento, reusing the cached text of
+// documents with the same content.
+func extractDocument(args *arguments, doc *shared.Documento) error {
+	sf, err := args.fm.SavedFileForURL(doc.URL)
+	if err != nil {
+		return err
+	}
+	key := args.extractOptions.CacheKey(sf.Hash())
+	result := &extracttext.Result{}
+	if args.fm.ReadCache(key, result) {
+		log.WithFields(log.Fields{
+			"url": doc.URL,
+		}).Info("using cached text")
+	} else {
+		reader, err := args.fm.GetReader(doc.URL)
+		if err != nil {
+			return err
+		}
+		result, err = extracttext.Extract(sf.ContentType, reader, args.extractOptions)
+		if result == nil {
+			return err
+		}
+		if err == nil {
+			args.fm.WriteCache(key, result)
+		}
+	}
+	doc.Pages = extracttext.Normalize(result.Pages, &args.extractOptions.Normalize)
+	doc.Content = extracttext.PagesText(doc.Pages)
+	if raw := extracttext.PagesText(result.Pages); args.extractOptions.Normalize.KeepRaw && raw != doc.Content {
+		doc.RawContent = raw
+	}
+	doc.Confidence = extracttext.DocumentConfidence(doc.Pages)
+	doc.Metadata = result.Metadata
+	doc.Signatures = result.Signatures
+	doc.Codes = result.Codes
+	saveLayout(args, sf, doc, result.Layout)
+	saveTables(args, sf, doc, result.Tables)
+	return nil
+}
+
+// saveTables stores the tables of a documento as csv files next to it.
+func saveTables(args *arguments, sf *shared.SavedFile, doc *shared.Documento, tables []shared.Table) {
+	doc.Tables = make([]shared.Table, 0, len(tables))
+	for i, table := range tables {
+		ext := fmt.Sprintf(".%d.csv", i+1)
+		err := args.fm.SaveDerivedFile(shared.TablesDirectory, sf, ext, table.CSV())
+		if err == nil {
+			table.CSVURL = args.fm.DerivedURL(shared.TablesDirectory, sf, ext)
+		}
+		doc.Tables = append(doc.Tables, table)
+	}
+}
+
+// extractChildren saves the files contained in a documento, like the files
+// attached to a pdf or the members of an archive, and extracts them as its
+// children.
+func extractChildren(args *arguments, doc *shared.Documento, depth int) {
+	if depth >= args.extractOptions.Archive.MaxDepth {
+		return
+	}
+	sf, err := args.fm.SavedFileForURL(doc.URL)
+	if err != nil {
+		return
+	}
+	content, err := os.ReadFile(args.fm.DestinationPath(sf))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"url":   doc.URL,
+			"error": err.Error(),
+		}).Error("failed to read content file")
+		return
+	}
+	contentType := sf.ContentType
+	if contentType == "" {
+		// entries migrated from the per-url metadata files have no type
+		contentType = shared.DetectContentType(content)
+	}
+	attachments, err := extracttext.Attachments(contentType, content, args.extractOptions)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"url":   doc.URL,
+			"error": err.Error(),
+		}).Warn("failed to read attachments")
+		return
+	}
+	names := map[string]bool{}
+	for i, attachment := range attachments {
+		name := attachment.Name
+		if name == "" || names[name] {
+			name = fmt.Sprintf("%d-%s", i+1, name)
+		}
+		names[name] = true
+		childSf, err := args.fm.SaveChildFile(doc.URL, name, attachment.Content)
+		if err != nil {
+			continue
+		}
+		child := &shared.Documento{
+			URL:                childSf.SourceURL,
+			ActuacionID:        doc.ActuacionID,
+			NumeroDeExpediente: doc.NumeroDeExpediente,
+			Type:               doc.Type,
+			Nombre:             name,
+			Parent:             doc.URL,
+		}
+		log.WithFields(log.Fields{
+			"url":  doc.URL,
+			"name": name,
+		}).Info("extracting attachment")
+		err = extractDocument(args, child)
+		if err == nil {
+			child.MirrorURL, _ = args.fm.DestinationURLforSourceURL(child.URL)
+		}
+		extractChildren(args, child, depth+1)
+		doc.Children = append(doc.Children, child)
+	}
+}
+
+// saveLayout stores the word positions of a documento next to it.
+func saveLayout(args *arguments, sf *shared.SavedFile, doc *shared.Documento, layout *extracttext.Layout) {
+	if layout == nil || len(layout.Pages) == 0 {
+		return
+	}
+	format := args.extractOptions.Layout
+	ext := extracttext.LayoutExtension(format)
+	err := args.fm.SaveDerivedFile(shared.LayoutDirectory, sf, ext, layout.Encode(format))
+	if err != nil {
+		return
+	}
+	doc.LayoutURL = args.fm.DerivedURL(shared.LayoutDirectory, sf, ext)
+}
 
+// build extracts the documentos of the expediente and writes the manifest,
+// the json and the snapshot.
+func build(args *arguments) error {
+	var blacklist *regexp.Regexp
 	if args.blacklistRegex != "" {
-		_, err := regexp.Match(args.blacklistRegex, []byte{})
+		var err error
+		blacklist, err = regexp.Compile(args.blacklistRegex)
 		if err != nil {
 			log.WithFields(log.Fields{
 				"regex": args.blacklistRegex,
 				"error": err.Error(),
 			}).Error("failed to parse blacklist regex")
-			os.Exit(1)
+			return err
 		}
 	}
 
 	for _, act := range args.exp.Actuaciones {
 		for _, doc := range act.Documentos {
-			if args.blacklistRegex != "" {
-				if match, _ := regexp.Match(args.blacklistRegex, []byte(doc.URL)); match {
-					log.WithFields(log.Fields{
-						"url": doc.URL,
-					}).Info("skipping blacklisted URL")
-					continue
-				}
+			if blacklist != nil && blacklist.MatchString(doc.URL) {
+				log.WithFields(log.Fields{
+					"url": doc.URL,
+				}).Info("skipping blacklisted URL")
+				continue
 			}
 			fetcher.Download(args.fm, doc.URL)
-			reader, err := args.fm.GetReader(doc.URL)
+			err := extractDocument(args, doc)
 			if err != nil {
 				continue
 			}
-			doc.Content, _ = extracttext.GetDocumentText(reader, args.parseImages)
 			doc.MirrorURL, _ = args.fm.DestinationURLforSourceURL(doc.URL)
+			extractChildren(args, doc, 0)
+		}
+		if unknown := act.CheckFirmantes(); len(unknown) > 0 {
+			log.WithFields(log.Fields{
+				"actuacion": act.ActId,
+				"firmantes": act.Firmantes,
+				"signers":   unknown,
+			}).Warn("documents signed by someone not in firmantes")
 		}
 	}
+	err := args.fm.WriteManifest([]*shared.Expediente{args.exp})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"pdfs":  args.fm.Directory,
+			"error": err.Error(),
+		}).Error("failed to write manifest")
+		return err
+	}
+
 	fp, err := os.Create(args.jsonPath)
 	if err != nil {
 		log.WithFields(log.Fields{
+			"json":  args.jsonPath,
+			"error": err.Error(),
+		}).Error("failed to create json")
+		return err
+	}
+	err = json.NewEncoder(fp).Encode(args.exp)
+	if closeErr := fp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"json":  args.jsonPath,
 			"error": err.Error(),
-		})
+		}).Error("failed to write json")
+		return err
+	}
+
+	if args.snapshotPath != "" {
+		return writeSnapshot(args)
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if command, found := commands[os.Args[1]]; found {
+			err := command.run(os.Args[2:])
+			if err != nil {
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	args, err := parseArguments()
+	if err != nil {
+		os.Exit(1)
+	}
+	log.WithFields(log.Fields{
+		"expediente":  args.exp,
+		"actuaciones": len(args.exp.Actuaciones),
+	}).Printf("finished")
+
+	err = build(args)
+	// the archive is closed before exiting, so its records and cdx index
+	// are complete even when the build failed
+	args.fm.Close()
+	if args.warc != nil {
+		closeErr := args.warc.Close()
+		if closeErr != nil {
+			log.WithFields(log.Fields{
+				"error": closeErr.Error(),
+			}).Error("failed to close warc")
+			if err == nil {
+				err = closeErr
+			}
+		}
+	}
+	if err != nil {
 		os.Exit(2)
 	}
-	defer fp.Close()
-	json.NewEncoder(fp).Encode(args.exp)
 }
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	extracttext "github.com/odia/juscaba/extracttext"
+	shared "github.com/odia/juscaba/shared"
+)
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	notADirectory := filepath.Join(dir, "file")
+	err := os.WriteFile(notADirectory, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		pdfs      string
+		blacklist string
+		fails     bool
+	}{
+		{"empty expediente", filepath.Join(dir, "pdfs"), "", false},
+		{"manifest can not be written", notADirectory, "", true},
+		{"invalid blacklist", filepath.Join(dir, "pdfs"), "(", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.MkdirAll(filepath.Join(dir, "pdfs"), 0755)
+			jsonPath := filepath.Join(t.TempDir(), "expediente.json")
+			args := &arguments{
+				blacklistRegex: test.blacklist,
+				jsonPath:       jsonPath,
+				fm:             &shared.FileManager{Directory: test.pdfs},
+				exp:            &shared.Expediente{Ficha: &shared.Ficha{}, Actuaciones: []*shared.Actuacion{}},
+				extractOptions: extracttext.DefaultOptions(),
+			}
+			defer args.fm.Close()
+			err := build(args)
+			if (err != nil) != test.fails {
+				t.Fatalf("build() error = %v, want failure %v", err, test.fails)
+			}
+			_, statErr := os.Stat(jsonPath)
+			if written := statErr == nil; written == test.fails {
+				t.Errorf("json written = %v, want %v", written, !test.fails)
+			}
+		})
+	}
+}
+
+// writeLegacyFile saves content the way old versions did: a content file and
+// a per-url metadata file without the content type.
+func writeLegacyFile(t *testing.T, dir, url string, content []byte) {
+	t.Helper()
+	sf := shared.NewSavedFile(url, shared.GetSha1(url)+filepath.Ext(url))
+	sf.FetchDate = time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+	metadata, err := json.Marshal(sf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, shared.GetSha1(url)+".json"), metadata, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, sf.DestinationFilename), content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testZip builds a zip with the files in the given order, as name and
+// content pairs.
+func testZip(t *testing.T, files ...string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for i := 0; i+1 < len(files); i += 2 {
+		f, err := w.Create(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(files[i+1]))
+	}
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// TestExtractChildrenLegacy checks that the adjuntos of documents saved by
+// old versions, whose index entries have no content type, are extracted.
+func TestExtractChildrenLegacy(t *testing.T) {
+	attached, err := os.ReadFile(filepath.Join("extracttext", "testdata", "attached.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		url     string
+		content []byte
+		want    []string
+	}{
+		{"pdf with an attachment", "https://example.com/escrito.pdf", attached, []string{"anexo.txt"}},
+		// the url of a zip adjunto can end in .pdf
+		{"zip named like a pdf", "https://example.com/adjunto.pdf", testZip(t, "a.txt", "uno", "b.txt", "dos"), []string{"a.txt", "b.txt"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeLegacyFile(t, dir, test.url, test.content)
+			options := extracttext.DefaultOptions()
+			options.PDFBackend = extracttext.GoBackend
+			options.Images = false
+			args := &arguments{
+				fm:             &shared.FileManager{Directory: dir},
+				extractOptions: options,
+			}
+			defer args.fm.Close()
+			sf, err := args.fm.SavedFileForURL(test.url)
+			if err != nil {
+				t.Fatalf("the legacy entry was not migrated: %v", err)
+			}
+			if sf.ContentType != "" {
+				t.Fatalf("ContentType = %q, want a legacy entry without it", sf.ContentType)
+			}
+			doc := &shared.Documento{URL: test.url, Type: shared.RegularAttachment}
+			extractChildren(args, doc, 0)
+			got := make([]string, 0)
+			for _, child := range doc.Children {
+				got = append(got, child.Nombre)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("children = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseExtractFlags(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.json")
+	err := os.WriteFile(config, []byte(`{
+		"dpi": 400,
+		"deskew": true,
+		"tesseract": {"languages": ["spa", "por"], "psm": 6, "variables": {"a": "1"}},
+		"commandTimeout": "90s"
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := filepath.Join(dir, "unknown.json")
+	err = os.WriteFile(unknown, []byte(`{"dpis": 400}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nullVariables := filepath.Join(dir, "null.json")
+	err = os.WriteFile(nullVariables, []byte(`{"tesseract": {"variables": null}}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type want struct {
+		dpi            int
+		deskew         bool
+		languages      []string
+		psm            int
+		variables      map[string]string
+		commandTimeout time.Duration
+	}
+	defaults := extracttext.DefaultOptions()
+	tests := []struct {
+		name  string
+		argv  []string
+		want  want
+		fails bool
+	}{
+		{
+			"flags only",
+			[]string{"-ocr-dpi=200", "-ocr-var", "b=2"},
+			want{200, false, []string{"spa"}, -1, map[string]string{"b": "2"}, defaults.CommandTimeout},
+			false,
+		},
+		{
+			"config only",
+			[]string{"-extract-config", config},
+			want{400, true, []string{"spa", "por"}, 6, map[string]string{"a": "1"}, 90 * time.Second},
+			false,
+		},
+		{
+			"flags take precedence over the config",
+			[]string{"-ocr-dpi=200", "-extract-config", config, "-ocr-languages=eng", "-command-timeout=10s"},
+			want{200, true, []string{"eng"}, 6, map[string]string{"a": "1"}, 10 * time.Second},
+			false,
+		},
+		{
+			"flags turn off config settings",
+			[]string{"-extract-config", config, "-ocr-deskew=false", "-ocr-psm=-1"},
+			want{400, false, []string{"spa", "por"}, -1, map[string]string{"a": "1"}, 90 * time.Second},
+			false,
+		},
+		{
+			"variables are added to the config ones",
+			[]string{"-extract-config", config, "-ocr-var", "b=2"},
+			want{400, true, []string{"spa", "por"}, 6, map[string]string{"a": "1", "b": "2"}, 90 * time.Second},
+			false,
+		},
+		{
+			"variables over a config without them",
+			[]string{"-extract-config", nullVariables, "-ocr-var", "b=2"},
+			want{defaults.DPI, false, []string{"spa"}, -1, map[string]string{"b": "2"}, defaults.CommandTimeout},
+			false,
+		},
+		{"unknown config setting", []string{"-extract-config", unknown}, want{}, true},
+		{"missing config", []string{"-extract-config", filepath.Join(dir, "missing.json")}, want{}, true},
+		{"invalid flag", []string{"-ocr-dpi=alta"}, want{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("builder", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			options := extracttext.DefaultOptions()
+			var extractConfig string
+			extractFlags(fs, options, &extractConfig)
+			err := parseExtractFlags(fs, test.argv, options, &extractConfig)
+			if (err != nil) != test.fails {
+				t.Fatalf("parseExtractFlags() error = %v, want failure %v", err, test.fails)
+			}
+			if test.fails {
+				return
+			}
+			got := want{options.DPI, options.Deskew, options.Tesseract.Languages, options.Tesseract.PSM, options.Tesseract.Variables, options.CommandTimeout}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("options = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path"
+
+	shared "github.com/odia/juscaba/shared"
+)
+
+func manifest(argv []string) error {
+	var pdfsPath, mirrorBaseURL string
+	flags := newFlagSet("manifest")
+	flags.StringVar(&pdfsPath, "pdfs", "", "pdfs path")
+	flags.StringVar(&mirrorBaseURL, "mirror-base-url", "", "base url for documents")
+	flags.Parse(argv)
+
+	exps := make([]*shared.Expediente, 0, flags.NArg())
+	for _, p := range flags.Args() {
+		exp, err := shared.ReadExpediente(p)
+		if err != nil {
+			return err
+		}
+		exps = append(exps, exp)
+	}
+
+	fm := &shared.FileManager{
+		Directory:     pdfsPath,
+		MirrorBaseURL: mirrorBaseURL,
+	}
+	defer fm.Close()
+	m, err := fm.BuildManifest(nil, exps)
+	if err != nil {
+		return err
+	}
+	return m.Write(path.Join(pdfsPath, shared.ManifestFilename), path.Join(pdfsPath, shared.ManifestCSVFilename))
+}
@@ -0,0 +1,92 @@
+package pdf
+
+import (
+	"time"
+)
+
+// EmbeddedFile is a file attached to a pdf, from the document's embedded
+// files or from a file attachment annotation.
+type EmbeddedFile struct {
+	Name        string
+	Description string
+	// ContentType is the /Subtype of the embedded file stream, if any.
+	ContentType string
+	ModDate     time.Time
+	Data        []byte
+}
+
+// EmbeddedFiles returns the attached files. Files whose data can not be
+// decoded are skipped.
+func (r *Reader) EmbeddedFiles() []*EmbeddedFile {
+	files := make([]*EmbeddedFile, 0)
+	seen := map[Ref]bool{}
+	add := func(filespec Object) {
+		spec := r.Dict(filespec)
+		if spec == nil {
+			return
+		}
+		ef := r.Dict(spec["EF"])
+		obj := ef["UF"]
+		if obj == nil {
+			obj = ef["F"]
+		}
+		if ref, ok := obj.(Ref); ok {
+			if seen[ref] {
+				return
+			}
+			seen[ref] = true
+		}
+		stream, ok := r.Resolve(obj).(*Stream)
+		if !ok {
+			return
+		}
+		data, err := r.StreamData(stream)
+		if err != nil {
+			return
+		}
+		file := &EmbeddedFile{
+			Name:        TextString(r.Resolve(spec["UF"])),
+			Description: TextString(r.Resolve(spec["Desc"])),
+			ContentType: TextString(r.Resolve(stream.Dict["Subtype"])),
+			Data:        data,
+		}
+		if file.Name == "" {
+			file.Name = TextString(r.Resolve(spec["F"]))
+		}
+		file.ModDate, _ = Date(r.Resolve(r.Dict(stream.Dict["Params"])["ModDate"]))
+		files = append(files, file)
+	}
+
+	visited := map[Ref]bool{}
+	var walk func(obj Object, depth int)
+	walk = func(obj Object, depth int) {
+		if ref, ok := obj.(Ref); ok {
+			if visited[ref] {
+				return
+			}
+			visited[ref] = true
+		}
+		node := r.Dict(obj)
+		if node == nil || depth > maxNesting {
+			return
+		}
+		names := r.Array(node["Names"])
+		for i := 1; i < len(names); i += 2 {
+			add(names[i])
+		}
+		for _, kid := range r.Array(node["Kids"]) {
+			walk(kid, depth+1)
+		}
+	}
+	walk(r.Dict(r.Catalog()["Names"])["EmbeddedFiles"], 0)
+
+	for _, page := range r.Pages() {
+		for _, annot := range r.Array(page["Annots"]) {
+			dict := r.Dict(annot)
+			if dict["Subtype"] == Name("FileAttachment") {
+				add(dict["FS"])
+			}
+		}
+	}
+	return files
+}
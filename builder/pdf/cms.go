@@ -0,0 +1,309 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	// register the digests used by signatures
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+var ErrInvalidSignature = errors.New("invalid pdf signature")
+var ErrUnsupportedSignature = errors.New("unsupported pdf signature")
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidRSAPSS        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}
+	oidMGF1          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 8}
+	oidECDSA         = asn1.ObjectIdentifier{1, 2, 840, 10045}
+)
+
+// pkcs1v15Algorithms are the rsa signature algorithms with pkcs#1 v1.5
+// padding. Signers use both rsaEncryption and the ones naming the digest.
+var pkcs1v15Algorithms = map[string]bool{
+	"1.2.840.113549.1.1.1":  true,
+	"1.2.840.113549.1.1.5":  true,
+	"1.2.840.113549.1.1.11": true,
+	"1.2.840.113549.1.1.12": true,
+	"1.2.840.113549.1.1.13": true,
+	"1.2.840.113549.1.1.14": true,
+}
+
+var digestAlgorithms = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+	"2.16.840.1.101.3.4.2.4": crypto.SHA224,
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// pssParameters are the RSASSA-PSS-params of rfc 4055.
+type pssParameters struct {
+	Hash         pkix.AlgorithmIdentifier `asn1:"explicit,optional,tag:0"`
+	MGF          pkix.AlgorithmIdentifier `asn1:"explicit,optional,tag:1"`
+	SaltLength   int                      `asn1:"explicit,optional,default:20,tag:2"`
+	TrailerField int                      `asn1:"explicit,optional,default:1,tag:3"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// Verification is what could be learned of a signature.
+type Verification struct {
+	// Certificate of the signer, nil when it is not included.
+	Certificate *x509.Certificate
+	// Certificates has the signer's and any intermediate certificates.
+	Certificates []*x509.Certificate
+	// SigningTime is the signed signing time attribute, zero when missing.
+	SigningTime time.Time
+}
+
+// VerifySignature checks that the signature matches the signed bytes and the
+// certificate of the signer. Trust in the certificate is not checked. The
+// verification is returned along errors once the signer is known.
+func VerifySignature(sig *Signature, signed []byte) (*Verification, error) {
+	if sig.SubFilter == "adbe.x509.rsa_sha1" {
+		return verifyRSASHA1(sig, signed)
+	}
+	var info contentInfo
+	_, err := asn1.Unmarshal(sig.Contents, &info)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("%w: content type %s", ErrUnsupportedSignature, info.ContentType)
+	}
+	var sd signedData
+	_, err = asn1.Unmarshal(info.Content.Bytes, &sd)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, fmt.Errorf("%w: no signer", ErrInvalidSignature)
+	}
+	v := &Verification{}
+	if len(sd.Certificates.Bytes) > 0 {
+		v.Certificates, err = x509.ParseCertificates(sd.Certificates.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+		}
+	}
+	si := sd.SignerInfos[0]
+	v.Certificate = signerCertificate(si.SID, v.Certificates)
+	if v.Certificate == nil {
+		return v, fmt.Errorf("%w: missing signer certificate", ErrInvalidSignature)
+	}
+	hash, found := digestAlgorithms[si.DigestAlgorithm.Algorithm.String()]
+	if !found {
+		return v, fmt.Errorf("%w: digest %s", ErrUnsupportedSignature, si.DigestAlgorithm.Algorithm)
+	}
+
+	// adbe.pkcs7.sha1 signs the sha1 of the byte range, other sub filters
+	// sign the byte range itself
+	content := signed
+	if len(sd.EncapContentInfo.Content.Bytes) > 0 {
+		var encapsulated []byte
+		_, err = asn1.Unmarshal(sd.EncapContentInfo.Content.Bytes, &encapsulated)
+		if err != nil {
+			return v, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+		}
+		digest := crypto.SHA1.New()
+		digest.Write(signed)
+		if !bytes.Equal(digest.Sum(nil), encapsulated) {
+			return v, fmt.Errorf("%w: document digest does not match", ErrInvalidSignature)
+		}
+		content = encapsulated
+	}
+	h := hash.New()
+	h.Write(content)
+	digest := h.Sum(nil)
+
+	if len(si.SignedAttrs.Bytes) > 0 {
+		var attributes []attribute
+		_, err = asn1.UnmarshalWithParams(setOf(si.SignedAttrs.Bytes), &attributes, "set")
+		if err != nil {
+			return v, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+		}
+		var messageDigest []byte
+		for _, attr := range attributes {
+			switch {
+			case attr.Type.Equal(oidMessageDigest):
+				asn1.Unmarshal(attr.Values.Bytes, &messageDigest)
+			case attr.Type.Equal(oidSigningTime):
+				asn1.Unmarshal(attr.Values.Bytes, &v.SigningTime)
+			}
+		}
+		if !bytes.Equal(messageDigest, digest) {
+			return v, fmt.Errorf("%w: document digest does not match", ErrInvalidSignature)
+		}
+		// the signature is over the der encoding of the attributes as
+		// a set, not with the implicit tag
+		h = hash.New()
+		h.Write(setOf(si.SignedAttrs.Bytes))
+		digest = h.Sum(nil)
+	}
+	err = checkSignature(v.Certificate, si.SignatureAlgorithm, hash, digest, si.Signature)
+	return v, err
+}
+
+// setOf encodes content as a der set.
+func setOf(content []byte) []byte {
+	b, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: content})
+	return b
+}
+
+func signerCertificate(sid asn1.RawValue, certificates []*x509.Certificate) *x509.Certificate {
+	if sid.Class == asn1.ClassContextSpecific {
+		for _, c := range certificates {
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c
+			}
+		}
+		return nil
+	}
+	var ias issuerAndSerial
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+		return nil
+	}
+	for _, c := range certificates {
+		if c.SerialNumber.Cmp(ias.Serial) == 0 && bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) {
+			return c
+		}
+	}
+	return nil
+}
+
+func hasPrefix(oid, prefix asn1.ObjectIdentifier) bool {
+	return len(oid) >= len(prefix) && oid[:len(prefix)].Equal(prefix)
+}
+
+func checkSignature(c *x509.Certificate, algorithm pkix.AlgorithmIdentifier, hash crypto.Hash, digest, signature []byte) error {
+	switch key := c.PublicKey.(type) {
+	case *rsa.PublicKey:
+		var err error
+		switch {
+		case pkcs1v15Algorithms[algorithm.Algorithm.String()]:
+			err = rsa.VerifyPKCS1v15(key, hash, digest, signature)
+		case algorithm.Algorithm.Equal(oidRSAPSS):
+			var options *rsa.PSSOptions
+			options, err = pssOptions(algorithm.Parameters, hash)
+			if err != nil {
+				return err
+			}
+			err = rsa.VerifyPSS(key, hash, digest, signature, options)
+		default:
+			return fmt.Errorf("%w: signature algorithm %s", ErrUnsupportedSignature, algorithm.Algorithm)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: signature does not match the certificate", ErrInvalidSignature)
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !hasPrefix(algorithm.Algorithm, oidECDSA) {
+			break
+		}
+		if !ecdsa.VerifyASN1(key, digest, signature) {
+			return fmt.Errorf("%w: signature does not match the certificate", ErrInvalidSignature)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: signature algorithm %s", ErrUnsupportedSignature, algorithm.Algorithm)
+}
+
+// pssOptions reads the RSASSA-PSS parameters. Only those whose digest and
+// mask generation digest are the digest of the signer are supported.
+func pssOptions(parameters asn1.RawValue, hash crypto.Hash) (*rsa.PSSOptions, error) {
+	var params pssParameters
+	if len(parameters.FullBytes) > 0 {
+		if _, err := asn1.Unmarshal(parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+		}
+	}
+	// sha1 is the default of both digests
+	pssHash, mgfHash := crypto.SHA1, crypto.SHA1
+	if len(params.Hash.Algorithm) > 0 {
+		pssHash = digestAlgorithms[params.Hash.Algorithm.String()]
+	}
+	if len(params.MGF.Algorithm) > 0 {
+		if !params.MGF.Algorithm.Equal(oidMGF1) {
+			return nil, fmt.Errorf("%w: mask generation %s", ErrUnsupportedSignature, params.MGF.Algorithm)
+		}
+		var mgf pkix.AlgorithmIdentifier
+		if _, err := asn1.Unmarshal(params.MGF.Parameters.FullBytes, &mgf); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+		}
+		mgfHash = digestAlgorithms[mgf.Algorithm.String()]
+	}
+	if pssHash != hash || mgfHash != hash || params.TrailerField != 1 {
+		return nil, fmt.Errorf("%w: rsassa-pss parameters", ErrUnsupportedSignature)
+	}
+	return &rsa.PSSOptions{SaltLength: params.SaltLength, Hash: hash}, nil
+}
+
+// verifyRSASHA1 checks the pkcs#1 signatures of adbe.x509.rsa_sha1, whose
+// certificate is in the signature dictionary.
+func verifyRSASHA1(sig *Signature, signed []byte) (*Verification, error) {
+	c, err := x509.ParseCertificate(sig.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+	v := &Verification{Certificate: c, Certificates: []*x509.Certificate{c}}
+	key, ok := c.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return v, fmt.Errorf("%w: not an rsa key", ErrUnsupportedSignature)
+	}
+	var signature []byte
+	if _, err := asn1.Unmarshal(sig.Contents, &signature); err != nil {
+		return v, fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+	h := crypto.SHA1.New()
+	h.Write(signed)
+	if rsa.VerifyPKCS1v15(key, crypto.SHA1, h.Sum(nil), signature) != nil {
+		return v, fmt.Errorf("%w: signature does not match the certificate", ErrInvalidSignature)
+	}
+	return v, nil
+}
@@ -0,0 +1,79 @@
+package pdf
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"testing"
+)
+
+// pssAlgorithm is RSASSA-PSS with the given digest for the signature and
+// the mask generation.
+func pssAlgorithm(t *testing.T, hash asn1.ObjectIdentifier) pkix.AlgorithmIdentifier {
+	t.Helper()
+	mgfHash, err := asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := asn1.Marshal(pssParameters{
+		Hash:         pkix.AlgorithmIdentifier{Algorithm: hash},
+		MGF:          pkix.AlgorithmIdentifier{Algorithm: oidMGF1, Parameters: asn1.RawValue{FullBytes: mgfHash}},
+		SaltLength:   32,
+		TrailerField: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oidRSAPSS, Parameters: asn1.RawValue{FullBytes: params}}
+}
+
+func TestCheckSignatureRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &x509.Certificate{PublicKey: &key.PublicKey}
+	sum := sha256.Sum256([]byte("contenido firmado"))
+	digest := sum[:]
+	pkcs1v15, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pss, err := rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest, &rsa.PSSOptions{SaltLength: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sha256OID := asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	sha384OID := asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	rsaEncryption := pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}}
+	sha256WithRSA := pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}}
+	oaep := pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 7}}
+
+	tests := []struct {
+		name      string
+		algorithm pkix.AlgorithmIdentifier
+		signature []byte
+		want      error
+	}{
+		{"rsaEncryption", rsaEncryption, pkcs1v15, nil},
+		{"sha256WithRSAEncryption", sha256WithRSA, pkcs1v15, nil},
+		{"pss", pssAlgorithm(t, sha256OID), pss, nil},
+		{"pss signature as pkcs#1 v1.5", sha256WithRSA, pss, ErrInvalidSignature},
+		{"pkcs#1 v1.5 signature as pss", pssAlgorithm(t, sha256OID), pkcs1v15, ErrInvalidSignature},
+		{"pss with another digest", pssAlgorithm(t, sha384OID), pss, ErrUnsupportedSignature},
+		{"not a signature algorithm", oaep, pkcs1v15, ErrUnsupportedSignature},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkSignature(c, test.algorithm, crypto.SHA256, digest, test.signature)
+			if !errors.Is(err, test.want) {
+				t.Errorf("checkSignature() error = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
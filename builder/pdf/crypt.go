@@ -0,0 +1,274 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"hash"
+)
+
+// ErrPassword is returned for encrypted files that can not be opened with
+// the empty user password.
+var ErrPassword = errors.New("pdf needs a password")
+
+var ErrUnsupportedEncryption = errors.New("unsupported pdf encryption")
+
+var passwordPadding = []byte{
+	0x28, 0xbf, 0x4e, 0x5e, 0x4e, 0x75, 0x8a, 0x41, 0x64, 0x00, 0x4e, 0x56, 0xff, 0xfa, 0x01, 0x08,
+	0x2e, 0x2e, 0x00, 0xb6, 0xd0, 0x68, 0x3e, 0x80, 0x2f, 0x0c, 0xa9, 0xfe, 0x64, 0x53, 0x69, 0x7a,
+}
+
+const (
+	cryptIdentity = iota
+	cryptRC4
+	cryptAES
+)
+
+// decrypter implements the standard security handler, opening files with
+// the empty user password.
+type decrypter struct {
+	key []byte
+	// aes256 files use the file key for every object
+	aes256  bool
+	strings int
+	streams int
+}
+
+func bytesOf(obj Object) []byte {
+	s, _ := obj.(String)
+	return []byte(s)
+}
+
+func (r *Reader) cryptMethod(encrypt Dict, filter Object) int {
+	name, _ := r.Resolve(filter).(Name)
+	if name == "" || name == "Identity" {
+		return cryptIdentity
+	}
+	cf := r.Dict(r.Dict(encrypt["CF"])[name])
+	switch method, _ := r.Resolve(cf["CFM"]).(Name); method {
+	case "AESV2", "AESV3":
+		return cryptAES
+	case "None":
+		return cryptIdentity
+	}
+	return cryptRC4
+}
+
+func newDecrypter(r *Reader, obj Object) (*decrypter, error) {
+	encrypt, ok := obj.(Dict)
+	if !ok {
+		return nil, ErrUnsupportedEncryption
+	}
+	if filter, _ := r.Resolve(encrypt["Filter"]).(Name); filter != "Standard" {
+		return nil, ErrUnsupportedEncryption
+	}
+	v, _ := r.Int(encrypt["V"])
+	revision, _ := r.Int(encrypt["R"])
+	o := bytesOf(r.Resolve(encrypt["O"]))
+	u := bytesOf(r.Resolve(encrypt["U"]))
+	d := &decrypter{strings: cryptRC4, streams: cryptRC4}
+	if v >= 4 {
+		d.strings = r.cryptMethod(encrypt, encrypt["StrF"])
+		d.streams = r.cryptMethod(encrypt, encrypt["StmF"])
+	}
+
+	if revision >= 5 {
+		if len(u) < 48 {
+			return nil, ErrUnsupportedEncryption
+		}
+		d.aes256 = true
+		check := hashV5(revision, nil, u[32:40], nil)
+		if !bytes.Equal(check, u[:32]) {
+			return nil, ErrPassword
+		}
+		intermediate := hashV5(revision, nil, u[40:48], nil)
+		ue := bytesOf(r.Resolve(encrypt["UE"]))
+		if len(ue) < 32 {
+			return nil, ErrUnsupportedEncryption
+		}
+		block, err := aes.NewCipher(intermediate)
+		if err != nil {
+			return nil, err
+		}
+		d.key = make([]byte, 32)
+		cipher.NewCBCDecrypter(block, make([]byte, 16)).CryptBlocks(d.key, ue[:32])
+		return d, nil
+	}
+
+	length := 40
+	if l, ok := r.Int(encrypt["Length"]); ok && v > 1 {
+		length = l
+	}
+	var id []byte
+	if ids := r.Array(r.Trailer["ID"]); len(ids) > 0 {
+		id = bytesOf(r.Resolve(ids[0]))
+	}
+	p, _ := r.Int(encrypt["P"])
+	h := md5.New()
+	h.Write(passwordPadding)
+	h.Write(o)
+	pb := make([]byte, 4)
+	binary.LittleEndian.PutUint32(pb, uint32(int32(p)))
+	h.Write(pb)
+	h.Write(id)
+	if metadata, ok := r.Resolve(encrypt["EncryptMetadata"]).(bool); revision >= 4 && ok && !metadata {
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+	key := h.Sum(nil)
+	n := length / 8
+	if revision == 2 || n < 5 || n > 16 {
+		n = 5
+	}
+	if revision >= 3 {
+		for i := 0; i < 50; i++ {
+			sum := md5.Sum(key[:n])
+			key = sum[:]
+		}
+	}
+	d.key = key[:n]
+
+	// check the user password
+	if revision == 2 {
+		c, _ := rc4.NewCipher(d.key)
+		check := make([]byte, 32)
+		c.XORKeyStream(check, passwordPadding)
+		if !bytes.Equal(check, u) {
+			return nil, ErrPassword
+		}
+		return d, nil
+	}
+	h = md5.New()
+	h.Write(passwordPadding)
+	h.Write(id)
+	check := h.Sum(nil)
+	for i := 0; i < 20; i++ {
+		k := make([]byte, len(d.key))
+		for j := range k {
+			k[j] = d.key[j] ^ byte(i)
+		}
+		c, _ := rc4.NewCipher(k)
+		c.XORKeyStream(check, check)
+	}
+	if len(u) < 16 || !bytes.Equal(check, u[:16]) {
+		return nil, ErrPassword
+	}
+	return d, nil
+}
+
+// hashV5 is the password hash of revisions 5 and 6.
+func hashV5(revision int, password, salt, udata []byte) []byte {
+	input := append(append(append([]byte{}, password...), salt...), udata...)
+	sum := sha256.Sum256(input)
+	k := sum[:]
+	if revision == 5 {
+		return k
+	}
+	for i := 0; ; i++ {
+		var k1 []byte
+		for j := 0; j < 64; j++ {
+			k1 = append(k1, password...)
+			k1 = append(k1, k...)
+			k1 = append(k1, udata...)
+		}
+		block, _ := aes.NewCipher(k[:16])
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+		mod := 0
+		for _, b := range e[:16] {
+			mod += int(b)
+		}
+		var h hash.Hash
+		switch mod % 3 {
+		case 0:
+			h = sha256.New()
+		case 1:
+			h = sha512.New384()
+		case 2:
+			h = sha512.New()
+		}
+		h.Write(e)
+		k = h.Sum(nil)
+		if i >= 63 && int(e[len(e)-1]) <= i-31 {
+			break
+		}
+	}
+	return k[:32]
+}
+
+func (d *decrypter) objectKey(ref Ref, aesMethod bool) []byte {
+	if d.aes256 {
+		return d.key
+	}
+	h := md5.New()
+	h.Write(d.key)
+	h.Write([]byte{byte(ref.Num), byte(ref.Num >> 8), byte(ref.Num >> 16), byte(ref.Gen), byte(ref.Gen >> 8)})
+	if aesMethod {
+		h.Write([]byte("sAlT"))
+	}
+	key := h.Sum(nil)
+	n := len(d.key) + 5
+	if n > 16 {
+		n = 16
+	}
+	return key[:n]
+}
+
+func (d *decrypter) decrypt(method int, data []byte, ref Ref) []byte {
+	switch method {
+	case cryptRC4:
+		c, err := rc4.NewCipher(d.objectKey(ref, false))
+		if err != nil {
+			return data
+		}
+		out := make([]byte, len(data))
+		c.XORKeyStream(out, data)
+		return out
+	case cryptAES:
+		if len(data) < 32 || len(data)%16 != 0 {
+			return data
+		}
+		block, err := aes.NewCipher(d.objectKey(ref, true))
+		if err != nil {
+			return data
+		}
+		out := make([]byte, len(data)-16)
+		cipher.NewCBCDecrypter(block, data[:16]).CryptBlocks(out, data[16:])
+		if pad := int(out[len(out)-1]); pad > 0 && pad <= 16 && pad <= len(out) {
+			out = out[:len(out)-pad]
+		}
+		return out
+	}
+	return data
+}
+
+func (d *decrypter) decryptStream(data []byte, ref Ref) []byte {
+	return d.decrypt(d.streams, data, ref)
+}
+
+// decryptObject decrypts the strings of an indirect object. Streams are
+// decrypted when their data is read.
+func (d *decrypter) decryptObject(obj Object, ref Ref) Object {
+	switch o := obj.(type) {
+	case String:
+		return String(d.decrypt(d.strings, o, ref))
+	case Array:
+		for i, v := range o {
+			o[i] = d.decryptObject(v, ref)
+		}
+	case Dict:
+		for k, v := range o {
+			o[k] = d.decryptObject(v, ref)
+		}
+	case *Stream:
+		for k, v := range o.Dict {
+			o.Dict[k] = d.decryptObject(v, ref)
+		}
+	}
+	return obj
+}
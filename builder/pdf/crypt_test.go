@@ -0,0 +1,183 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testEncryption is a standard security handler with an empty user
+// password.
+type testEncryption struct {
+	v, revision, length int
+	// method is the crypt filter of V4 and later, AESV2 or AESV3
+	method string
+	p      int32
+	id     []byte
+	// wrongU breaks the user password check
+	wrongU bool
+	key    []byte
+}
+
+// encryptionDict computes the file key and returns the /Encrypt dictionary.
+func (e *testEncryption) encryptionDict() string {
+	o := bytes.Repeat([]byte{0x4f}, 32)
+	if e.revision >= 5 {
+		e.key = bytes.Repeat([]byte{0x6b}, 32)
+		validationSalt, keySalt := []byte("validsal"), []byte("keysalt!")
+		sum := sha256.Sum256(validationSalt)
+		u := append(append(sum[:], validationSalt...), keySalt...)
+		intermediate := sha256.Sum256(keySalt)
+		block, _ := aes.NewCipher(intermediate[:])
+		ue := make([]byte, 32)
+		cipher.NewCBCEncrypter(block, make([]byte, 16)).CryptBlocks(ue, e.key)
+		if e.wrongU {
+			u[0] ^= 0xff
+		}
+		return fmt.Sprintf("<< /Filter /Standard /V %d /R %d /Length %d /CF << /StdCF << /CFM /%s /Length 32 >> >> "+
+			"/StmF /StdCF /StrF /StdCF /O <%x%x> /U <%x> /OE <%x> /UE <%x> /P %d >>",
+			e.v, e.revision, e.length, e.method, o, make([]byte, 16), u, make([]byte, 32), ue, e.p)
+	}
+
+	n := e.length / 8
+	h := md5.New()
+	h.Write(passwordPadding)
+	h.Write(o)
+	binary.Write(h, binary.LittleEndian, e.p)
+	h.Write(e.id)
+	key := h.Sum(nil)[:n]
+	if e.revision >= 3 {
+		for i := 0; i < 50; i++ {
+			sum := md5.Sum(key)
+			key = sum[:n]
+		}
+	}
+	e.key = key
+	var u []byte
+	if e.revision == 2 {
+		u = rc4XOR(key, passwordPadding)
+	} else {
+		sum := md5.Sum(append(append([]byte{}, passwordPadding...), e.id...))
+		u = sum[:]
+		for i := 0; i < 20; i++ {
+			k := make([]byte, len(key))
+			for j := range k {
+				k[j] = key[j] ^ byte(i)
+			}
+			u = rc4XOR(k, u)
+		}
+		u = append(u, make([]byte, 16)...)
+	}
+	if e.wrongU {
+		u[0] ^= 0xff
+	}
+	filters := ""
+	if e.v >= 4 {
+		filters = fmt.Sprintf("/CF << /StdCF << /CFM /%s /Length 16 >> >> /StmF /StdCF /StrF /StdCF ", e.method)
+	}
+	return fmt.Sprintf("<< /Filter /Standard /V %d /R %d /Length %d %s/O <%x> /U <%x> /P %d >>",
+		e.v, e.revision, e.length, filters, o, u, e.p)
+}
+
+func rc4XOR(key, data []byte) []byte {
+	c, _ := rc4.NewCipher(key)
+	out := make([]byte, len(data))
+	c.XORKeyStream(out, data)
+	return out
+}
+
+// encrypt encrypts the data of object num, generation 0.
+func (e *testEncryption) encrypt(num int, data []byte) []byte {
+	key := e.key
+	if e.revision < 5 {
+		h := md5.New()
+		h.Write(e.key)
+		h.Write([]byte{byte(num), byte(num >> 8), byte(num >> 16), 0, 0})
+		if e.method != "" {
+			h.Write([]byte("sAlT"))
+		}
+		key = h.Sum(nil)
+		if n := len(e.key) + 5; n < 16 {
+			key = key[:n]
+		}
+	}
+	if e.method == "" {
+		return rc4XOR(key, data)
+	}
+	pad := 16 - len(data)%16
+	data = append(append([]byte{}, data...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	iv := []byte("initializationv!")
+	block, _ := aes.NewCipher(key)
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	return append(iv, out...)
+}
+
+// encryptedHello is helloObjects encrypted, with an /Info dictionary.
+func encryptedHello(e *testEncryption) []byte {
+	encrypt := e.encryptionDict()
+	objects := append([]string{}, helloObjects[:3]...)
+	content := e.encrypt(4, []byte("BT /F1 12 Tf 72 712 Td (Hola mundo) Tj ET"))
+	objects = append(objects, stream("", string(content)), helloObjects[4],
+		fmt.Sprintf("<< /Title <%x> /Author <%x> >>",
+			e.encrypt(6, []byte("Sentencia definitiva")), e.encrypt(6, []byte("Juzgado 1"))),
+		encrypt)
+	return buildPDFWithTrailer(objects, fmt.Sprintf("/Info 6 0 R /Encrypt 7 0 R /ID [<%x> <%x>] ", e.id, e.id))
+}
+
+func TestOpenEncrypted(t *testing.T) {
+	id := []byte("juscaba-id-00001")
+	// print, copy, fill forms, assemble and print in high quality
+	p := int32(-1 &^ 8 &^ 32 &^ 512)
+	tests := []struct {
+		name       string
+		encryption testEncryption
+	}{
+		{"rc4 40 bits", testEncryption{v: 1, revision: 2, length: 40}},
+		{"rc4 128 bits", testEncryption{v: 2, revision: 3, length: 128}},
+		{"aes 128 bits", testEncryption{v: 4, revision: 4, length: 128, method: "AESV2"}},
+		{"aes 256 bits", testEncryption{v: 5, revision: 5, length: 256, method: "AESV3"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := test.encryption
+			e.p, e.id = p, id
+			r, err := Open(encryptedHello(&e))
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if !r.Encrypted() {
+				t.Errorf("Encrypted() = false")
+			}
+			if got, ok := r.Permissions(); !ok || got != int(p) {
+				t.Errorf("Permissions() = %d, %v, want %d", got, ok, p)
+			}
+			if got := r.Text(); len(got) != 1 || !strings.Contains(got[0], "Hola mundo") {
+				t.Errorf("Text() = %q", got)
+			}
+			info := r.Info()
+			got := []string{TextString(r.Resolve(info["Title"])), TextString(r.Resolve(info["Author"]))}
+			if want := []string{"Sentencia definitiva", "Juzgado 1"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("Info() = %q, want %q", got, want)
+			}
+
+			e.wrongU = true
+			r, err = Open(encryptedHello(&e))
+			if !errors.Is(err, ErrPassword) {
+				t.Errorf("Open() with another user password error = %v, want %v", err, ErrPassword)
+			}
+			if r == nil || !r.Encrypted() {
+				t.Errorf("Open() with another user password = %v, want the reader", r)
+			}
+		})
+	}
+}
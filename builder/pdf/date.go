@@ -0,0 +1,57 @@
+package pdf
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date parses a pdf date string, D:YYYYMMDDHHmmSSOHH'mm', where every field
+// after the year is optional.
+func Date(obj Object) (time.Time, bool) {
+	s := strings.TrimSpace(TextString(obj))
+	s = strings.TrimPrefix(s, "D:")
+	if len(s) < 4 {
+		return time.Time{}, false
+	}
+	fields := []int{0, 1, 1, 0, 0, 0}
+	widths := []int{4, 2, 2, 2, 2, 2}
+	pos := 0
+	for i, width := range widths {
+		if pos+width > len(s) || !isDigits(s[pos:pos+width]) {
+			if i == 0 {
+				return time.Time{}, false
+			}
+			break
+		}
+		fields[i], _ = strconv.Atoi(s[pos : pos+width])
+		pos += width
+	}
+	location := time.UTC
+	rest := s[pos:]
+	if len(rest) > 0 && (rest[0] == '+' || rest[0] == '-') {
+		rest = strings.ReplaceAll(rest[1:], "'", "")
+		hours, minutes := 0, 0
+		if len(rest) >= 2 && isDigits(rest[:2]) {
+			hours, _ = strconv.Atoi(rest[:2])
+		}
+		if len(rest) >= 4 && isDigits(rest[2:4]) {
+			minutes, _ = strconv.Atoi(rest[2:4])
+		}
+		offset := hours*3600 + minutes*60
+		if s[pos] == '-' {
+			offset = -offset
+		}
+		location = time.FixedZone("", offset)
+	}
+	return time.Date(fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, location), true
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,170 @@
+package pdf
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// winAnsiNames are the glyph names of WinAnsiEncoding from 0x20 to 0xff.
+var winAnsiNames = strings.Fields(`
+space exclam quotedbl numbersign dollar percent ampersand quotesingle
+parenleft parenright asterisk plus comma hyphen period slash
+zero one two three four five six seven eight nine colon semicolon less equal greater question
+at A B C D E F G H I J K L M N O P Q R S T U V W X Y Z bracketleft backslash bracketright asciicircum underscore
+grave a b c d e f g h i j k l m n o p q r s t u v w x y z braceleft bar braceright asciitilde .notdef
+Euro .notdef quotesinglbase florin quotedblbase ellipsis dagger daggerdbl
+circumflex perthousand Scaron guilsinglleft OE .notdef Zcaron .notdef
+.notdef quoteleft quoteright quotedblleft quotedblright bullet endash emdash
+tilde trademark scaron guilsinglright oe .notdef zcaron Ydieresis
+nbspace exclamdown cent sterling currency yen brokenbar section
+dieresis copyright ordfeminine guillemotleft logicalnot sfthyphen registered macron
+degree plusminus twosuperior threesuperior acute mu paragraph periodcentered
+cedilla onesuperior ordmasculine guillemotright onequarter onehalf threequarters questiondown
+Agrave Aacute Acircumflex Atilde Adieresis Aring AE Ccedilla Egrave Eacute Ecircumflex Edieresis Igrave Iacute Icircumflex Idieresis
+Eth Ntilde Ograve Oacute Ocircumflex Otilde Odieresis multiply Oslash Ugrave Uacute Ucircumflex Udieresis Yacute Thorn germandbls
+agrave aacute acircumflex atilde adieresis aring ae ccedilla egrave eacute ecircumflex edieresis igrave iacute icircumflex idieresis
+eth ntilde ograve oacute ocircumflex otilde odieresis divide oslash ugrave uacute ucircumflex udieresis yacute thorn ydieresis
+`)
+
+// cp1252 are the characters of WinAnsiEncoding from 0x80 to 0x9f.
+var cp1252 = []rune("€\x00‚ƒ„…†‡ˆ‰Š‹Œ\x00Ž\x00\x00‘’“”•–—˜™š›œ\x00žŸ")
+
+// macRoman are the characters of MacRomanEncoding from 0x80 to 0xff.
+var macRoman = []rune("ÄÅÇÉÑÖÜáàâäãåçéèêëíìîïñóòôöõúùûü†°¢£§•¶ß®©™´¨≠ÆØ∞±≤≥¥µ∂∑∏π∫ªºΩæø¿¡¬√ƒ≈∆«»… ÀÃÕŒœ–—“”‘’÷◊ÿŸ⁄€‹›ﬁﬂ‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ")
+
+// standardNames are the glyph names of StandardEncoding that differ from
+// ascii.
+var standardNames = map[byte]string{
+	0x27: "quoteright", 0x60: "quoteleft",
+	0xa1: "exclamdown", 0xa2: "cent", 0xa3: "sterling", 0xa4: "fraction", 0xa5: "yen",
+	0xa6: "florin", 0xa7: "section", 0xa8: "currency", 0xa9: "quotesingle",
+	0xaa: "quotedblleft", 0xab: "guillemotleft", 0xac: "guilsinglleft",
+	0xad: "guilsinglright", 0xae: "fi", 0xaf: "fl", 0xb1: "endash", 0xb2: "dagger",
+	0xb3: "daggerdbl", 0xb4: "periodcentered", 0xb6: "paragraph", 0xb7: "bullet",
+	0xb8: "quotesinglbase", 0xb9: "quotedblbase", 0xba: "quotedblright",
+	0xbb: "guillemotright", 0xbc: "ellipsis", 0xbd: "perthousand", 0xbf: "questiondown",
+	0xc1: "grave", 0xc2: "acute", 0xc3: "circumflex", 0xc4: "tilde", 0xc5: "macron",
+	0xc6: "breve", 0xc7: "dotaccent", 0xc8: "dieresis", 0xca: "ring", 0xcb: "cedilla",
+	0xcd: "hungarumlaut", 0xce: "ogonek", 0xcf: "caron", 0xd0: "emdash", 0xe1: "AE",
+	0xe3: "ordfeminine", 0xe8: "Lslash", 0xe9: "Oslash", 0xea: "OE", 0xeb: "ordmasculine",
+	0xf1: "ae", 0xf5: "dotlessi", 0xf8: "lslash", 0xf9: "oslash", 0xfa: "oe", 0xfb: "germandbls",
+}
+
+var glyphs = map[string]string{
+	"fi": "fi", "fl": "fl", "ff": "ff", "ffi": "ffi", "ffl": "ffl",
+	"dotlessi": "ı", "lslash": "ł", "Lslash": "Ł", "ring": "˚", "ogonek": "˛",
+	"caron": "ˇ", "breve": "˘", "dotaccent": "˙", "hungarumlaut": "˝",
+	"fraction": "⁄", "minus": "−", "space": " ", "nbspace": " ", "sfthyphen": "-",
+	"hyphen": "-", "quotesingle": "'", "grave": "`", "bullet": "•",
+	"Euro": "€", "nonbreakingspace": " ", "middot": "·", "commaaccent": ",",
+	"Omega": "Ω", "mu": "µ", "Delta": "∆", "notequal": "≠", "lessequal": "≤",
+	"greaterequal": "≥", "infinity": "∞", "summation": "∑", "product": "∏",
+	"pi": "π", "integral": "∫", "radical": "√", "approxequal": "≈", "lozenge": "◊",
+	"partialdiff": "∂",
+}
+
+var winAnsi, standard, macRomanEncoding [256]string
+
+func init() {
+	for i, name := range winAnsiNames {
+		c := 0x20 + i
+		var r rune
+		switch {
+		case c < 0x80:
+			r = rune(c)
+		case c < 0xa0:
+			r = cp1252[c-0x80]
+		default:
+			r = rune(c)
+		}
+		if name == ".notdef" || r == 0 {
+			continue
+		}
+		if _, found := glyphs[name]; !found {
+			glyphs[name] = string(r)
+		}
+		winAnsi[c] = string(r)
+	}
+	for c := 0x20; c < 0x7f; c++ {
+		standard[c] = string(rune(c))
+		macRomanEncoding[c] = string(rune(c))
+	}
+	for c, name := range standardNames {
+		standard[c] = glyphs[name]
+	}
+	for i, r := range macRoman {
+		macRomanEncoding[0x80+i] = string(r)
+	}
+}
+
+// glyphText returns the text of a glyph name, following the adobe glyph list
+// conventions for uniXXXX, uXXXX, ligatures and suffixes.
+func glyphText(name string) string {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	if text, found := glyphs[name]; found {
+		return text
+	}
+	if strings.Contains(name, "_") {
+		var b strings.Builder
+		for _, part := range strings.Split(name, "_") {
+			b.WriteString(glyphText(part))
+		}
+		return b.String()
+	}
+	if strings.HasPrefix(name, "uni") && len(name) >= 7 && (len(name)-3)%4 == 0 {
+		units := make([]uint16, 0, (len(name)-3)/4)
+		for i := 3; i < len(name); i += 4 {
+			v, err := strconv.ParseUint(name[i:i+4], 16, 16)
+			if err != nil {
+				return ""
+			}
+			units = append(units, uint16(v))
+		}
+		return string(utf16.Decode(units))
+	}
+	if strings.HasPrefix(name, "u") && len(name) >= 5 && len(name) <= 7 {
+		if v, err := strconv.ParseUint(name[1:], 16, 32); err == nil {
+			return string(rune(v))
+		}
+	}
+	return ""
+}
+
+// TextString decodes a pdf text string, in utf-16 when it starts with a byte
+// order mark and in PDFDocEncoding otherwise.
+func TextString(obj Object) string {
+	s, ok := obj.(String)
+	if !ok {
+		if n, ok := obj.(Name); ok {
+			return string(n)
+		}
+		return ""
+	}
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		return utf16BE(s[2:])
+	}
+	if len(s) >= 3 && s[0] == 0xef && s[1] == 0xbb && s[2] == 0xbf {
+		return string(s[3:])
+	}
+	var b strings.Builder
+	for _, c := range s {
+		// PDFDocEncoding matches WinAnsiEncoding for the characters in use
+		if text := winAnsi[c]; text != "" && c >= 0x80 {
+			b.WriteString(text)
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+func utf16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,332 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrUnsupportedFilter = errors.New("unsupported pdf filter")
+
+// maxStreamSize limits decoded streams, so compression bombs do not exhaust
+// the memory.
+const maxStreamSize = 256 << 20
+
+// imageFilters are left encoded, they only matter to render images.
+var imageFilters = map[Name]bool{
+	"DCTDecode":      true,
+	"JPXDecode":      true,
+	"CCITTFaxDecode": true,
+	"JBIG2Decode":    true,
+	"DCT":            true,
+	"CCF":            true,
+}
+
+// StreamData returns the decrypted and decoded content of a stream. Image
+// compression filters are not decoded.
+func (r *Reader) StreamData(stream *Stream) ([]byte, error) {
+	data := stream.raw
+	if r.crypt != nil && stream.ref.Num != 0 && stream.Dict["Type"] != Name("XRef") {
+		data = r.crypt.decryptStream(data, stream.ref)
+	}
+	filters := r.Resolve(stream.Dict["Filter"])
+	if filters == nil {
+		filters = r.Resolve(stream.Dict["F"])
+	}
+	params := r.Resolve(stream.Dict["DecodeParms"])
+	if params == nil {
+		params = r.Resolve(stream.Dict["DP"])
+	}
+	var filterList Array
+	var paramList Array
+	switch f := filters.(type) {
+	case Name:
+		filterList = Array{f}
+		paramList = Array{params}
+	case Array:
+		filterList = f
+		paramList, _ = params.(Array)
+	}
+	for i, filter := range filterList {
+		name, _ := r.Resolve(filter).(Name)
+		var param Dict
+		if i < len(paramList) {
+			param = r.Dict(paramList[i])
+		}
+		if imageFilters[name] {
+			return data, nil
+		}
+		var err error
+		data, err = r.decode(name, param, data)
+		if err != nil {
+			return data, err
+		}
+	}
+	return data, nil
+}
+
+func (r *Reader) decode(name Name, param Dict, data []byte) ([]byte, error) {
+	switch name {
+	case "FlateDecode", "Fl":
+		decoded, err := inflate(data)
+		if err != nil {
+			return decoded, err
+		}
+		return r.unpredict(param, decoded)
+	case "LZWDecode", "LZW":
+		earlyChange := 1
+		if v, ok := r.Int(param["EarlyChange"]); ok {
+			earlyChange = v
+		}
+		decoded, err := lzwDecode(data, earlyChange)
+		if err != nil {
+			return decoded, err
+		}
+		return r.unpredict(param, decoded)
+	case "ASCIIHexDecode", "AHx":
+		l := &lexer{data: append(append([]byte{}, data...), '>')}
+		return []byte(l.hexString()), nil
+	case "ASCII85Decode", "A85":
+		return ascii85Decode(data)
+	case "RunLengthDecode", "RL":
+		return runLengthDecode(data), nil
+	case "Crypt":
+		// the identity crypt filter, other crypt filters are applied
+		// when decrypting
+		return data, nil
+	}
+	return data, fmt.Errorf("%w: %s", ErrUnsupportedFilter, name)
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxStreamSize))
+	if err == io.ErrUnexpectedEOF {
+		// keep what could be read of truncated streams
+		err = nil
+	}
+	return data, err
+}
+
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err == nil {
+		defer zr.Close()
+		decoded, err := readLimited(zr)
+		if err == nil || len(decoded) > 0 {
+			return decoded, nil
+		}
+	}
+	// some writers omit the zlib header
+	fr := flate.NewReader(bytes.NewReader(data))
+	defer fr.Close()
+	return readLimited(fr)
+}
+
+// unpredict reverses the png and tiff predictors.
+func (r *Reader) unpredict(param Dict, data []byte) ([]byte, error) {
+	predictor, _ := r.Int(param["Predictor"])
+	if predictor < 2 {
+		return data, nil
+	}
+	colors, bpc, columns := 1, 8, 1
+	if v, ok := r.Int(param["Colors"]); ok && v > 0 {
+		colors = v
+	}
+	if v, ok := r.Int(param["BitsPerComponent"]); ok && v > 0 {
+		bpc = v
+	}
+	if v, ok := r.Int(param["Columns"]); ok && v > 0 {
+		columns = v
+	}
+	bpp := (colors*bpc + 7) / 8
+	rowSize := (colors*bpc*columns + 7) / 8
+	if predictor == 2 {
+		if bpc != 8 {
+			return data, fmt.Errorf("%w: tiff predictor with %d bits", ErrUnsupportedFilter, bpc)
+		}
+		out := append([]byte{}, data...)
+		for row := 0; row+rowSize <= len(out); row += rowSize {
+			for i := bpp; i < rowSize; i++ {
+				out[row+i] += out[row+i-bpp]
+			}
+		}
+		return out, nil
+	}
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowSize)
+	for pos := 0; pos < len(data); pos += rowSize + 1 {
+		if pos+rowSize+1 > len(data) {
+			break
+		}
+		kind := data[pos]
+		row := append([]byte{}, data[pos+1:pos+1+rowSize]...)
+		for i := range row {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left = row[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up = prev[i]
+			switch kind {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+// lzwDecode decodes the lzw variant of pdf and tiff, with codes from 9 to 12
+// bits, most significant bit first.
+func lzwDecode(data []byte, earlyChange int) ([]byte, error) {
+	const clear, eod = 256, 257
+	var out bytes.Buffer
+	table := make([][]byte, 258, 4096)
+	reset := func() {
+		table = table[:258]
+		for i := 0; i < 256; i++ {
+			table[i] = []byte{byte(i)}
+		}
+	}
+	reset()
+	width := 9
+	var bits, nbits uint
+	var prev []byte
+	for _, c := range data {
+		bits = bits<<8 | uint(c)
+		nbits += 8
+		for nbits >= uint(width) {
+			code := int(bits >> (nbits - uint(width)) & (1<<uint(width) - 1))
+			nbits -= uint(width)
+			switch {
+			case code == clear:
+				reset()
+				width = 9
+				prev = nil
+				continue
+			case code == eod:
+				return out.Bytes(), nil
+			}
+			var entry []byte
+			switch {
+			case code < len(table):
+				entry = table[code]
+			case code == len(table) && prev != nil:
+				entry = append(append([]byte{}, prev...), prev[0])
+			default:
+				return out.Bytes(), fmt.Errorf("%w: invalid lzw code", ErrSyntax)
+			}
+			out.Write(entry)
+			if out.Len() > maxStreamSize {
+				return out.Bytes(), nil
+			}
+			if prev != nil && len(table) < 4096 {
+				table = append(table, append(append([]byte{}, prev...), entry[0]))
+			}
+			prev = entry
+			if next := len(table) + earlyChange; next >= 1<<uint(width) && width < 12 {
+				width++
+			}
+		}
+	}
+	return out.Bytes(), nil
+}
+
+func ascii85Decode(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	var group [5]byte
+	n := 0
+	flush := func(count int) {
+		v := uint32(0)
+		for i := 0; i < 5; i++ {
+			c := byte('u')
+			if i < count {
+				c = group[i]
+			}
+			v = v*85 + uint32(c-'!')
+		}
+		b := []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+		out.Write(b[:count-1])
+	}
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '~':
+			if n > 1 {
+				flush(n)
+			}
+			return out.Bytes(), nil
+		case c == 'z' && n == 0:
+			out.Write([]byte{0, 0, 0, 0})
+		case c >= '!' && c <= 'u':
+			group[n] = c
+			n++
+			if n == 5 {
+				flush(5)
+				n = 0
+			}
+		case isWhite(c):
+		default:
+			return out.Bytes(), fmt.Errorf("%w: invalid ascii85 data", ErrSyntax)
+		}
+	}
+	if n > 1 {
+		flush(n)
+	}
+	return out.Bytes(), nil
+}
+
+func runLengthDecode(data []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(data); {
+		n := int(data[i])
+		i++
+		switch {
+		case n == 128:
+			return out.Bytes()
+		case n < 128:
+			end := i + n + 1
+			if end > len(data) {
+				end = len(data)
+			}
+			out.Write(data[i:end])
+			i = end
+		default:
+			if i < len(data) {
+				out.Write(bytes.Repeat(data[i:i+1], 257-n))
+			}
+			i++
+		}
+	}
+	return out.Bytes()
+}
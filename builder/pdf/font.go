@@ -0,0 +1,324 @@
+package pdf
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+type codespace struct {
+	length int
+	lo, hi []byte
+}
+
+// cmap maps character codes to text, as read from a ToUnicode stream.
+type cmap struct {
+	codespaces []codespace
+	text       map[string]string
+}
+
+func (c *cmap) inCodespace(code []byte) bool {
+	for _, space := range c.codespaces {
+		if space.length != len(code) {
+			continue
+		}
+		inside := true
+		for i := range code {
+			if code[i] < space.lo[i] || code[i] > space.hi[i] {
+				inside = false
+				break
+			}
+		}
+		if inside {
+			return true
+		}
+	}
+	return false
+}
+
+// nextCode returns the length of the code at the start of s.
+func (c *cmap) nextCode(s []byte, defaultLength int) int {
+	for n := 1; n <= 4 && n <= len(s); n++ {
+		if c.inCodespace(s[:n]) {
+			return n
+		}
+	}
+	if defaultLength > len(s) {
+		return len(s)
+	}
+	return defaultLength
+}
+
+func unicodeText(s String) string {
+	if len(s) == 1 {
+		return string(rune(s[0]))
+	}
+	return utf16BE(s)
+}
+
+// incrementText adds offset to the last character of a bfrange destination.
+func incrementText(s String, offset int) string {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	if len(units) == 0 {
+		return string(rune(int(s[0]) + offset))
+	}
+	runes := utf16.Decode(units)
+	runes[len(runes)-1] += rune(offset)
+	return string(runes)
+}
+
+func codeKey(b []byte) string {
+	return string(b)
+}
+
+// incrementCode returns the code after lo plus offset, with the length of lo.
+func incrementCode(lo []byte, offset int) []byte {
+	code := append([]byte{}, lo...)
+	for i := len(code) - 1; i >= 0 && offset > 0; i-- {
+		v := int(code[i]) + offset
+		code[i] = byte(v)
+		offset = v >> 8
+	}
+	return code
+}
+
+func codeValue(b []byte) int {
+	v := 0
+	for _, c := range b {
+		v = v<<8 | int(c)
+	}
+	return v
+}
+
+// maxRangeSize limits bfranges, so broken cmaps do not exhaust the memory.
+const maxRangeSize = 1 << 16
+
+func parseCMap(data []byte) *cmap {
+	c := &cmap{text: map[string]string{}}
+	l := &lexer{data: data}
+	operands := make([]Object, 0)
+	for {
+		tok, err := l.token()
+		if err != nil {
+			break
+		}
+		kw, ok := tok.(Keyword)
+		if !ok || kw == "[" {
+			obj, _ := l.objectFrom(tok, 0)
+			operands = append(operands, obj)
+			continue
+		}
+		switch kw {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, ok1 := operands[i].(String)
+				hi, ok2 := operands[i+1].(String)
+				if ok1 && ok2 && len(lo) == len(hi) && len(lo) > 0 {
+					c.codespaces = append(c.codespaces, codespace{length: len(lo), lo: lo, hi: hi})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok := operands[i].(String)
+				if !ok {
+					continue
+				}
+				switch dst := operands[i+1].(type) {
+				case String:
+					c.text[codeKey(src)] = unicodeText(dst)
+				case Name:
+					c.text[codeKey(src)] = glyphText(string(dst))
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(String)
+				hi, ok2 := operands[i+1].(String)
+				if !ok1 || !ok2 || len(lo) != len(hi) {
+					continue
+				}
+				n := codeValue(hi) - codeValue(lo)
+				if n < 0 || n > maxRangeSize {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case String:
+					for j := 0; j <= n; j++ {
+						c.text[codeKey(incrementCode(lo, j))] = incrementText(dst, j)
+					}
+				case Array:
+					for j := 0; j <= n && j < len(dst); j++ {
+						if s, ok := dst[j].(String); ok {
+							c.text[codeKey(incrementCode(lo, j))] = unicodeText(s)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return c
+}
+
+// glyph is a character code shown with a font.
+type glyph struct {
+	code  []byte
+	text  string
+	width float64
+}
+
+type font struct {
+	composite bool
+	toUnicode *cmap
+	encoding  [256]string
+	// widths are in text space units, a thousandth of the font size for
+	// most fonts
+	widths       map[int]float64
+	defaultWidth float64
+}
+
+func (r *Reader) loadFont(dict Dict) *font {
+	f := &font{widths: map[int]float64{}, defaultWidth: 0.5}
+	subtype, _ := r.Resolve(dict["Subtype"]).(Name)
+	baseFont, _ := r.Resolve(dict["BaseFont"]).(Name)
+	if strings.Contains(string(baseFont), "Courier") {
+		f.defaultWidth = 0.6
+	}
+	if stream, ok := r.Resolve(dict["ToUnicode"]).(*Stream); ok {
+		if data, err := r.StreamData(stream); err == nil {
+			f.toUnicode = parseCMap(data)
+		}
+	}
+	if subtype == "Type0" {
+		f.composite = true
+		descendants := r.Array(dict["DescendantFonts"])
+		if len(descendants) > 0 {
+			r.loadCIDWidths(f, r.Dict(descendants[0]))
+		}
+		return f
+	}
+	r.loadEncoding(f, dict, subtype)
+	scale := 0.001
+	if subtype == "Type3" {
+		if m := r.Array(dict["FontMatrix"]); len(m) > 0 {
+			if v, ok := r.Float(m[0]); ok {
+				scale = v
+			}
+		}
+	}
+	if descriptor := r.Dict(dict["FontDescriptor"]); descriptor != nil {
+		if v, ok := r.Float(descriptor["MissingWidth"]); ok && v > 0 {
+			f.defaultWidth = v * scale
+		}
+	}
+	first, _ := r.Int(dict["FirstChar"])
+	for i, w := range r.Array(dict["Widths"]) {
+		if v, ok := r.Float(w); ok {
+			f.widths[first+i] = v * scale
+		}
+	}
+	return f
+}
+
+func (r *Reader) loadEncoding(f *font, dict Dict, subtype Name) {
+	f.encoding = standard
+	if subtype == "TrueType" {
+		f.encoding = winAnsi
+	}
+	setBase := func(name Name) {
+		switch name {
+		case "WinAnsiEncoding":
+			f.encoding = winAnsi
+		case "MacRomanEncoding":
+			f.encoding = macRomanEncoding
+		case "StandardEncoding":
+			f.encoding = standard
+		}
+	}
+	switch encoding := r.Resolve(dict["Encoding"]).(type) {
+	case Name:
+		setBase(encoding)
+	case Dict:
+		if base, ok := r.Resolve(encoding["BaseEncoding"]).(Name); ok {
+			setBase(base)
+		}
+		code := 0
+		for _, item := range r.Array(encoding["Differences"]) {
+			switch v := r.Resolve(item).(type) {
+			case int64:
+				code = int(v)
+			case Name:
+				if code >= 0 && code < 256 {
+					f.encoding[code] = glyphText(string(v))
+				}
+				code++
+			}
+		}
+	}
+}
+
+func (r *Reader) loadCIDWidths(f *font, descendant Dict) {
+	f.defaultWidth = 1
+	if v, ok := r.Float(descendant["DW"]); ok {
+		f.defaultWidth = v / 1000
+	}
+	w := r.Array(descendant["W"])
+	for i := 0; i < len(w); {
+		first, ok := r.Int(w[i])
+		if !ok || i+1 >= len(w) {
+			return
+		}
+		if widths, ok := r.Resolve(w[i+1]).(Array); ok {
+			for j, v := range widths {
+				if width, ok := r.Float(v); ok {
+					f.widths[first+j] = width / 1000
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(w) {
+			return
+		}
+		last, _ := r.Int(w[i+1])
+		width, _ := r.Float(w[i+2])
+		for c := first; c <= last && c-first <= maxRangeSize; c++ {
+			f.widths[c] = width / 1000
+		}
+		i += 3
+	}
+}
+
+// decode splits a shown string into glyphs.
+func (f *font) decode(s []byte) []glyph {
+	glyphs := make([]glyph, 0, len(s))
+	defaultLength := 1
+	if f.composite {
+		defaultLength = 2
+	}
+	for len(s) > 0 {
+		n := defaultLength
+		if f.toUnicode != nil && len(f.toUnicode.codespaces) > 0 {
+			n = f.toUnicode.nextCode(s, defaultLength)
+		} else if n > len(s) {
+			n = len(s)
+		}
+		code := s[:n]
+		s = s[n:]
+		g := glyph{code: code}
+		if f.toUnicode != nil {
+			g.text = f.toUnicode.text[codeKey(code)]
+		}
+		if g.text == "" && !f.composite {
+			g.text = f.encoding[code[0]]
+		}
+		g.width = f.defaultWidth
+		if w, found := f.widths[codeValue(code)]; found {
+			g.width = w
+		}
+		glyphs = append(glyphs, g)
+	}
+	return glyphs
+}
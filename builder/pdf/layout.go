@@ -0,0 +1,91 @@
+package pdf
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// charWidth estimates the width of a character column, the median width of
+// the characters of the spans.
+func charWidth(spans []Span) float64 {
+	widths := make([]float64, 0, len(spans))
+	for _, s := range spans {
+		n := utf8.RuneCountInString(s.Text)
+		if n > 0 && s.X1 > s.X0 {
+			widths = append(widths, (s.X1-s.X0)/float64(n))
+		}
+	}
+	if len(widths) == 0 {
+		return 5
+	}
+	sort.Float64s(widths)
+	return widths[len(widths)/2]
+}
+
+// LayoutText places the spans of a page on a grid of characters, keeping
+// columns aligned, so tables can be told apart from running text.
+func LayoutText(spans []Span) string {
+	if len(spans) == 0 {
+		return ""
+	}
+	sorted := append([]Span{}, spans...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Y > sorted[j].Y
+	})
+	lines := make([][]Span, 0)
+	lineY := math.Inf(1)
+	for _, s := range sorted {
+		tolerance := math.Max(s.Size, 1) * 0.5
+		if len(lines) == 0 || math.Abs(s.Y-lineY) > tolerance {
+			lines = append(lines, []Span{})
+			lineY = s.Y
+		}
+		lines[len(lines)-1] = append(lines[len(lines)-1], s)
+	}
+
+	width := charWidth(spans)
+	left := math.Inf(1)
+	for _, s := range spans {
+		left = math.Min(left, s.X0)
+	}
+	var b strings.Builder
+	previousY := lines[0][0].Y
+	for i, line := range lines {
+		sort.SliceStable(line, func(i, j int) bool {
+			return line[i].X0 < line[j].X0
+		})
+		if i > 0 {
+			b.WriteString("\n")
+			// a blank line for each missing line of text
+			gap := previousY - line[0].Y
+			blanks := int(gap/(math.Max(line[0].Size, 1)*1.2)) - 1
+			if blanks > 3 {
+				blanks = 3
+			}
+			for j := 0; j < blanks; j++ {
+				b.WriteString("\n")
+			}
+		}
+		previousY = line[0].Y
+		column := 0
+		lastX := math.Inf(-1)
+		for _, s := range line {
+			target := int(math.Round((s.X0 - left) / width))
+			adjacent := s.X0-lastX < math.Max(s.Size, 1)*0.15
+			if column > 0 && !adjacent && target <= column {
+				target = column + 1
+			}
+			if target > column && !(adjacent && column > 0) {
+				b.WriteString(strings.Repeat(" ", target-column))
+				column = target
+			}
+			b.WriteString(s.Text)
+			column += utf8.RuneCountInString(s.Text)
+			lastX = s.X1
+		}
+	}
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,333 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var ErrSyntax = errors.New("pdf syntax error")
+
+// Object is one of nil, bool, int64, float64, Name, String, Array, Dict,
+// *Stream, Ref or Keyword.
+type Object interface{}
+
+type Name string
+
+// String is a pdf string as stored in the file, see TextString to decode it.
+type String []byte
+
+type Array []Object
+
+type Dict map[Name]Object
+
+// Keyword is a bare word: an operator in content streams, or a structural
+// keyword like obj or R.
+type Keyword string
+
+type Ref struct {
+	Num int
+	Gen int
+}
+
+type Stream struct {
+	Dict Dict
+	// raw is the content as stored in the file, encrypted and encoded.
+	raw []byte
+	ref Ref
+}
+
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isWhite(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if !isWhite(c) {
+			return
+		}
+		l.pos++
+	}
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func (l *lexer) name() Name {
+	var b []byte
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isWhite(c) || isDelimiter(c) {
+			break
+		}
+		if c == '#' && l.pos+2 < len(l.data) {
+			hi, ok1 := unhex(l.data[l.pos+1])
+			lo, ok2 := unhex(l.data[l.pos+2])
+			if ok1 && ok2 {
+				b = append(b, hi<<4|lo)
+				l.pos += 3
+				continue
+			}
+		}
+		b = append(b, c)
+		l.pos++
+	}
+	return Name(b)
+}
+
+func (l *lexer) literalString() String {
+	var b []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return String(b)
+			}
+		case '\r':
+			// end of lines are read as \n
+			if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+				l.pos++
+			}
+			c = '\n'
+		case '\\':
+			if l.pos >= len(l.data) {
+				return String(b)
+			}
+			c = l.data[l.pos]
+			l.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return String(b)
+}
+
+func (l *lexer) hexString() String {
+	var b []byte
+	half := -1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		if c == '>' {
+			break
+		}
+		v, ok := unhex(c)
+		if !ok {
+			continue
+		}
+		if half < 0 {
+			half = int(v)
+		} else {
+			b = append(b, byte(half<<4)|v)
+			half = -1
+		}
+	}
+	if half >= 0 {
+		b = append(b, byte(half<<4))
+	}
+	return String(b)
+}
+
+// token returns the next object or keyword, without building arrays and
+// dictionaries: their delimiters are returned as keywords.
+func (l *lexer) token() (Object, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.EOF
+	}
+	c := l.data[l.pos]
+	switch c {
+	case '/':
+		l.pos++
+		return l.name(), nil
+	case '(':
+		l.pos++
+		return l.literalString(), nil
+	case '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return Keyword("<<"), nil
+		}
+		l.pos++
+		return l.hexString(), nil
+	case '>':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return Keyword(">>"), nil
+		}
+		l.pos++
+		return nil, ErrSyntax
+	case '[', ']', '{', '}':
+		l.pos++
+		return Keyword(c), nil
+	case ')':
+		l.pos++
+		return nil, ErrSyntax
+	}
+	start := l.pos
+	for l.pos < len(l.data) && !isWhite(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9') {
+		if i, err := strconv.ParseInt(word, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(word, 64); err == nil {
+			return f, nil
+		}
+		// malformed numbers like 0.-5 are read as 0
+		if word == "-" || word == "." || word == "+" {
+			return int64(0), nil
+		}
+	}
+	return Keyword(word), nil
+}
+
+// object reads a whole object, following references and building arrays
+// and dictionaries.
+func (l *lexer) object() (Object, error) {
+	tok, err := l.token()
+	if err != nil {
+		return nil, err
+	}
+	return l.objectFrom(tok, 0)
+}
+
+const maxNesting = 64
+
+func (l *lexer) objectFrom(tok Object, depth int) (Object, error) {
+	if depth > maxNesting {
+		return nil, fmt.Errorf("%w: nesting too deep", ErrSyntax)
+	}
+	switch t := tok.(type) {
+	case Keyword:
+		switch t {
+		case "<<":
+			dict := Dict{}
+			for {
+				tok, err := l.token()
+				if err != nil {
+					return dict, err
+				}
+				if tok == Keyword(">>") {
+					return dict, nil
+				}
+				key, ok := tok.(Name)
+				if !ok {
+					// skip garbage keys
+					continue
+				}
+				tok, err = l.token()
+				if err != nil {
+					return dict, err
+				}
+				if tok == Keyword(">>") {
+					dict[key] = nil
+					return dict, nil
+				}
+				value, err := l.objectFrom(tok, depth+1)
+				if err != nil {
+					return dict, err
+				}
+				dict[key] = value
+			}
+		case "[":
+			array := Array{}
+			for {
+				tok, err := l.token()
+				if err != nil {
+					return array, err
+				}
+				if tok == Keyword("]") {
+					return array, nil
+				}
+				value, err := l.objectFrom(tok, depth+1)
+				if err != nil {
+					return array, err
+				}
+				array = append(array, value)
+			}
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return t, nil
+	case int64:
+		// a reference is two integers followed by R
+		save := l.pos
+		gen, err := l.token()
+		if g, ok := gen.(int64); ok && err == nil {
+			r, err := l.token()
+			if r == Keyword("R") && err == nil {
+				return Ref{Num: int(t), Gen: int(g)}, nil
+			}
+		}
+		l.pos = save
+		return t, nil
+	}
+	return tok, nil
+}
@@ -0,0 +1,607 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var ErrNotPDF = errors.New("not a pdf file")
+
+type xrefEntry struct {
+	offset int
+	// stream is the object stream holding a compressed object, 0 for
+	// objects stored directly in the file.
+	stream int
+	index  int
+}
+
+// Reader gives access to the objects of a pdf file held in memory.
+type Reader struct {
+	data    []byte
+	xref    map[int]xrefEntry
+	Trailer Dict
+	// Version is the pdf version of the header, or of the catalog when
+	// it is newer.
+	Version string
+	// Repaired is true when the cross reference table was broken and the
+	// objects were found by scanning the file.
+	Repaired bool
+
+	objects map[int]Object
+	streams map[int]*objectStream
+	crypt   *decrypter
+	encrypt Ref
+	// resolving are the objects whose stream length is being read, so
+	// a length that refers to its own stream ends.
+	resolving map[int]bool
+}
+
+type objectStream struct {
+	data    []byte
+	offsets map[int]int
+}
+
+var headerRegexp = regexp.MustCompile(`%PDF-(\d\.\d)`)
+
+// Open reads the structure of a pdf file. Files with a broken cross
+// reference table are repaired by scanning for objects. Malformed files
+// return ErrSyntax, they never panic.
+func Open(data []byte) (r *Reader, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			r, err = nil, fmt.Errorf("%w: %v", ErrSyntax, e)
+		}
+	}()
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	match := headerRegexp.FindSubmatch(head)
+	if match == nil {
+		return nil, ErrNotPDF
+	}
+	r = &Reader{
+		data:      data,
+		xref:      map[int]xrefEntry{},
+		Version:   string(match[1]),
+		objects:   map[int]Object{},
+		streams:   map[int]*objectStream{},
+		resolving: map[int]bool{},
+	}
+	err = r.readXrefChain()
+	if err != nil || r.Trailer == nil || r.Trailer["Root"] == nil {
+		r.repair()
+	}
+	if r.Trailer == nil || r.Trailer["Root"] == nil {
+		return nil, fmt.Errorf("%w: missing catalog", ErrSyntax)
+	}
+	if encrypt, found := r.Trailer["Encrypt"]; found && encrypt != nil {
+		if ref, ok := encrypt.(Ref); ok {
+			r.encrypt = ref
+		}
+		r.crypt, err = newDecrypter(r, r.Resolve(encrypt))
+		if err != nil {
+			return r, err
+		}
+		// objects read so far were not decrypted
+		r.objects = map[int]Object{}
+		r.streams = map[int]*objectStream{}
+	}
+	if v, ok := r.Resolve(r.Catalog()["Version"]).(Name); ok && string(v) > r.Version {
+		r.Version = string(v)
+	}
+	return r, nil
+}
+
+// Catalog returns the root dictionary of the document.
+func (r *Reader) Catalog() Dict {
+	d, _ := r.Resolve(r.Trailer["Root"]).(Dict)
+	return d
+}
+
+// Info returns the document information dictionary, which may be nil.
+func (r *Reader) Info() Dict {
+	d, _ := r.Resolve(r.Trailer["Info"]).(Dict)
+	return d
+}
+
+// Encrypted tells if the file uses a security handler.
+func (r *Reader) Encrypted() bool {
+	return r.Trailer["Encrypt"] != nil
+}
+
+func (r *Reader) startxref() (int, error) {
+	tail := r.data
+	if len(tail) > 2048 {
+		tail = tail[len(tail)-2048:]
+	}
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return 0, fmt.Errorf("%w: missing startxref", ErrSyntax)
+	}
+	l := &lexer{data: tail, pos: i + len("startxref")}
+	tok, err := l.token()
+	offset, ok := tok.(int64)
+	if err != nil || !ok || offset < 0 || int(offset) >= len(r.data) {
+		return 0, fmt.Errorf("%w: invalid startxref", ErrSyntax)
+	}
+	return int(offset), nil
+}
+
+func (r *Reader) readXrefChain() error {
+	offset, err := r.startxref()
+	if err != nil {
+		return err
+	}
+	seen := map[int]bool{}
+	for !seen[offset] {
+		seen[offset] = true
+		trailer, err := r.readXref(offset)
+		if err != nil {
+			return err
+		}
+		if r.Trailer == nil {
+			r.Trailer = trailer
+		} else {
+			for k, v := range trailer {
+				if _, found := r.Trailer[k]; !found {
+					r.Trailer[k] = v
+				}
+			}
+		}
+		if stm, ok := trailer["XRefStm"].(int64); ok && !seen[int(stm)] {
+			seen[int(stm)] = true
+			if _, err := r.readXref(int(stm)); err != nil {
+				return err
+			}
+		}
+		prev, ok := trailer["Prev"].(int64)
+		if !ok || prev < 0 || int(prev) >= len(r.data) {
+			break
+		}
+		offset = int(prev)
+	}
+	return nil
+}
+
+// setEntry keeps the entries of newer sections, which are read first.
+func (r *Reader) setEntry(num int, entry xrefEntry) {
+	if _, found := r.xref[num]; !found {
+		r.xref[num] = entry
+	}
+}
+
+func (r *Reader) readXref(offset int) (Dict, error) {
+	l := &lexer{data: r.data, pos: offset}
+	tok, err := l.token()
+	if err != nil {
+		return nil, err
+	}
+	if tok == Keyword("xref") {
+		return r.readXrefTable(l)
+	}
+	l.pos = offset
+	obj, _, err := r.parseIndirect(offset)
+	if err != nil {
+		return nil, err
+	}
+	stream, ok := obj.(*Stream)
+	if !ok || stream.Dict["Type"] != Name("XRef") {
+		return nil, fmt.Errorf("%w: invalid xref at %d", ErrSyntax, offset)
+	}
+	return stream.Dict, r.readXrefStream(stream)
+}
+
+func (r *Reader) readXrefTable(l *lexer) (Dict, error) {
+	for {
+		tok, err := l.token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == Keyword("trailer") {
+			obj, err := l.object()
+			if err != nil {
+				return nil, err
+			}
+			trailer, ok := obj.(Dict)
+			if !ok {
+				return nil, fmt.Errorf("%w: invalid trailer", ErrSyntax)
+			}
+			return trailer, nil
+		}
+		start, ok := tok.(int64)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid xref table", ErrSyntax)
+		}
+		tok, err = l.token()
+		count, ok := tok.(int64)
+		if err != nil || !ok {
+			return nil, fmt.Errorf("%w: invalid xref subsection", ErrSyntax)
+		}
+		for i := int64(0); i < count; i++ {
+			offset, err1 := l.token()
+			_, err2 := l.token()
+			kind, err3 := l.token()
+			if err1 != nil || err2 != nil || err3 != nil {
+				return nil, fmt.Errorf("%w: truncated xref table", ErrSyntax)
+			}
+			off, ok := offset.(int64)
+			if !ok || off < 0 {
+				return nil, fmt.Errorf("%w: invalid xref entry", ErrSyntax)
+			}
+			num := int(start + i)
+			if kind == Keyword("n") {
+				r.setEntry(num, xrefEntry{offset: int(off)})
+			} else {
+				r.setEntry(num, xrefEntry{offset: -1})
+			}
+		}
+	}
+}
+
+func (r *Reader) readXrefStream(stream *Stream) error {
+	data, err := r.StreamData(stream)
+	if err != nil {
+		return err
+	}
+	widths, _ := stream.Dict["W"].(Array)
+	if len(widths) != 3 {
+		return fmt.Errorf("%w: invalid xref stream widths", ErrSyntax)
+	}
+	w := [3]int{}
+	for i, v := range widths {
+		n, _ := v.(int64)
+		// fields wider than 8 bytes do not fit an int
+		if n < 0 || n > 8 {
+			return fmt.Errorf("%w: invalid xref stream widths", ErrSyntax)
+		}
+		w[i] = int(n)
+	}
+	size, _ := stream.Dict["Size"].(int64)
+	index, _ := stream.Dict["Index"].(Array)
+	if len(index) == 0 {
+		index = Array{int64(0), size}
+	}
+	field := func(b []byte) int {
+		v := 0
+		for _, c := range b {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+	entrySize := w[0] + w[1] + w[2]
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, _ := index[i].(int64)
+		count, _ := index[i+1].(int64)
+		if start < 0 || count < 0 {
+			return fmt.Errorf("%w: invalid xref stream index", ErrSyntax)
+		}
+		for j := int64(0); j < count; j++ {
+			if pos+entrySize > len(data) {
+				return nil
+			}
+			entry := data[pos : pos+entrySize]
+			pos += entrySize
+			kind := 1
+			if w[0] > 0 {
+				kind = field(entry[:w[0]])
+			}
+			a := field(entry[w[0] : w[0]+w[1]])
+			b := field(entry[w[0]+w[1]:])
+			num := int(start + j)
+			switch kind {
+			case 0:
+				r.setEntry(num, xrefEntry{offset: -1})
+			case 1:
+				if a >= len(r.data) {
+					a = -1
+				}
+				r.setEntry(num, xrefEntry{offset: a})
+			case 2:
+				r.setEntry(num, xrefEntry{stream: a, index: b})
+			}
+		}
+	}
+	return nil
+}
+
+var objRegexp = regexp.MustCompile(`(?:^|[^0-9])(\d+)[ \t\r\n\f\x00]+(\d+)[ \t\r\n\f\x00]+obj\b`)
+
+// repair rebuilds the cross reference table by scanning the file for
+// objects, and the trailer from the last trailer or xref stream found.
+func (r *Reader) repair() {
+	r.Repaired = true
+	r.xref = map[int]xrefEntry{}
+	r.objects = map[int]Object{}
+	for _, match := range objRegexp.FindAllSubmatchIndex(r.data, -1) {
+		num, _ := strconv.Atoi(string(r.data[match[2]:match[3]]))
+		r.xref[num] = xrefEntry{offset: match[2]}
+	}
+	trailer := Dict{}
+	for num, entry := range r.xref {
+		obj, _, err := r.parseIndirect(entry.offset)
+		if err != nil {
+			continue
+		}
+		switch o := obj.(type) {
+		case *Stream:
+			if o.Dict["Type"] == Name("XRef") && trailer["Root"] == nil {
+				for k, v := range o.Dict {
+					trailer[k] = v
+				}
+			}
+			if o.Dict["Type"] == Name("ObjStm") {
+				r.indexObjectStream(num, o)
+			}
+		case Dict:
+			if o["Type"] == Name("Catalog") && trailer["Root"] == nil {
+				trailer["Root"] = Ref{Num: num}
+			}
+		}
+	}
+	delete(trailer, "Prev")
+	delete(trailer, "XRefStm")
+	r.Trailer = trailer
+	r.scanTrailers()
+}
+
+// scanTrailers reads every trailer dictionary in the file, later ones win.
+func (r *Reader) scanTrailers() {
+	data := r.data
+	for i := 0; ; {
+		j := bytes.Index(data[i:], []byte("trailer"))
+		if j < 0 {
+			return
+		}
+		l := &lexer{data: data, pos: i + j + len("trailer")}
+		if obj, err := l.object(); err == nil {
+			if d, ok := obj.(Dict); ok && d["Root"] != nil {
+				for k, v := range d {
+					if k != "Prev" && k != "XRefStm" {
+						r.Trailer[k] = v
+					}
+				}
+			}
+		}
+		i += j + len("trailer")
+	}
+}
+
+// indexObjectStream adds the objects of an object stream found while
+// repairing, unless they are stored directly in the file.
+func (r *Reader) indexObjectStream(num int, stream *Stream) {
+	objStm, err := r.objectStream(num, stream)
+	if err != nil {
+		return
+	}
+	for n, i := range objStm.offsets {
+		if _, found := r.xref[n]; !found {
+			r.xref[n] = xrefEntry{stream: num, index: i}
+		}
+	}
+}
+
+// parseIndirect parses "num gen obj ... endobj" at offset.
+func (r *Reader) parseIndirect(offset int) (Object, Ref, error) {
+	if offset < 0 || offset >= len(r.data) {
+		return nil, Ref{}, fmt.Errorf("%w: offset out of range", ErrSyntax)
+	}
+	l := &lexer{data: r.data, pos: offset}
+	num, err1 := l.token()
+	gen, err2 := l.token()
+	kw, err3 := l.token()
+	n, ok1 := num.(int64)
+	g, ok2 := gen.(int64)
+	if err1 != nil || err2 != nil || err3 != nil || !ok1 || !ok2 || kw != Keyword("obj") {
+		return nil, Ref{}, fmt.Errorf("%w: expected object at %d", ErrSyntax, offset)
+	}
+	ref := Ref{Num: int(n), Gen: int(g)}
+	obj, err := l.object()
+	if err != nil {
+		return nil, ref, err
+	}
+	dict, ok := obj.(Dict)
+	if !ok {
+		return obj, ref, nil
+	}
+	save := l.pos
+	tok, err := l.token()
+	if err != nil || tok != Keyword("stream") {
+		l.pos = save
+		return dict, ref, nil
+	}
+	start := l.pos
+	if start < len(r.data) && r.data[start] == '\r' {
+		start++
+	}
+	if start < len(r.data) && r.data[start] == '\n' {
+		start++
+	}
+	end := -1
+	if length, ok := r.resolveLength(dict["Length"]); ok && start+length <= len(r.data) {
+		rest := bytes.TrimLeft(r.data[start+length:min(start+length+32, len(r.data))], " \t\r\n\f\x00")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			end = start + length
+		}
+	}
+	if end < 0 {
+		i := bytes.Index(r.data[start:], []byte("endstream"))
+		if i < 0 {
+			return nil, ref, fmt.Errorf("%w: unterminated stream", ErrSyntax)
+		}
+		end = start + i
+		for end > start && (r.data[end-1] == '\n' || r.data[end-1] == '\r') {
+			end--
+		}
+	}
+	return &Stream{Dict: dict, raw: r.data[start:end], ref: ref}, ref, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// resolveLength reads a stream length, which may be an indirect object.
+// Lengths that refer back to a stream being read, or nest too deep, are
+// not resolved.
+func (r *Reader) resolveLength(obj Object) (int, bool) {
+	if ref, ok := obj.(Ref); ok {
+		entry, found := r.xref[ref.Num]
+		if !found || entry.stream != 0 || entry.offset < 0 || r.resolving[ref.Num] || len(r.resolving) >= maxNesting {
+			return 0, false
+		}
+		r.resolving[ref.Num] = true
+		obj, _, _ = r.parseIndirect(entry.offset)
+		delete(r.resolving, ref.Num)
+	}
+	n, ok := obj.(int64)
+	return int(n), ok && n >= 0
+}
+
+func (r *Reader) objectStream(num int, stream *Stream) (*objectStream, error) {
+	if objStm, found := r.streams[num]; found {
+		return objStm, nil
+	}
+	data, err := r.StreamData(stream)
+	if err != nil {
+		return nil, err
+	}
+	count, _ := stream.Dict["N"].(int64)
+	first, _ := stream.Dict["First"].(int64)
+	if first < 0 || int(first) > len(data) {
+		return nil, fmt.Errorf("%w: invalid object stream", ErrSyntax)
+	}
+	objStm := &objectStream{data: data, offsets: map[int]int{}}
+	l := &lexer{data: data[:first]}
+	for i := int64(0); i < count; i++ {
+		n, err1 := l.token()
+		off, err2 := l.token()
+		nv, ok1 := n.(int64)
+		ov, ok2 := off.(int64)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 {
+			break
+		}
+		if ov < 0 || int(first+ov) >= len(data) {
+			continue
+		}
+		objStm.offsets[int(nv)] = int(first + ov)
+	}
+	r.streams[num] = objStm
+	return objStm, nil
+}
+
+// Object returns the indirect object with the given number.
+func (r *Reader) Object(num int) Object {
+	if obj, found := r.objects[num]; found {
+		return obj
+	}
+	// mark the object while it is read, so reference loops end
+	r.objects[num] = nil
+	obj := r.readObject(num)
+	r.objects[num] = obj
+	return obj
+}
+
+func (r *Reader) readObject(num int) Object {
+	entry, found := r.xref[num]
+	if !found {
+		return nil
+	}
+	if entry.stream != 0 {
+		container, ok := r.Object(entry.stream).(*Stream)
+		if !ok {
+			return nil
+		}
+		objStm, err := r.objectStream(entry.stream, container)
+		if err != nil {
+			return nil
+		}
+		offset, found := objStm.offsets[num]
+		if !found || offset < 0 || offset >= len(objStm.data) {
+			return nil
+		}
+		l := &lexer{data: objStm.data, pos: offset}
+		obj, _ := l.object()
+		return obj
+	}
+	if entry.offset < 0 {
+		return nil
+	}
+	obj, ref, err := r.parseIndirect(entry.offset)
+	if err != nil || ref.Num != num {
+		return nil
+	}
+	if r.crypt != nil && num != r.encrypt.Num {
+		obj = r.crypt.decryptObject(obj, ref)
+	}
+	return obj
+}
+
+// Resolve follows references until a direct object.
+func (r *Reader) Resolve(obj Object) Object {
+	for i := 0; i < 32; i++ {
+		ref, ok := obj.(Ref)
+		if !ok {
+			return obj
+		}
+		obj = r.Object(ref.Num)
+	}
+	return nil
+}
+
+// Dict resolves a dictionary, or the dictionary of a stream.
+func (r *Reader) Dict(obj Object) Dict {
+	switch o := r.Resolve(obj).(type) {
+	case Dict:
+		return o
+	case *Stream:
+		return o.Dict
+	}
+	return nil
+}
+
+// Int resolves a number as an int.
+func (r *Reader) Int(obj Object) (int, bool) {
+	switch o := r.Resolve(obj).(type) {
+	case int64:
+		return int(o), true
+	case float64:
+		return int(o), true
+	}
+	return 0, false
+}
+
+// Float resolves a number as a float64.
+func (r *Reader) Float(obj Object) (float64, bool) {
+	switch o := r.Resolve(obj).(type) {
+	case int64:
+		return float64(o), true
+	case float64:
+		return o, true
+	}
+	return 0, false
+}
+
+// Array resolves an array.
+func (r *Reader) Array(obj Object) Array {
+	a, _ := r.Resolve(obj).(Array)
+	return a
+}
+
+// Permissions returns the /P flags of the security handler, and false for
+// files that are not encrypted.
+func (r *Reader) Permissions() (int, bool) {
+	encrypt := r.Dict(r.Trailer["Encrypt"])
+	if encrypt == nil {
+		return 0, false
+	}
+	p, ok := r.Int(encrypt["P"])
+	return int(int32(p)), ok
+}
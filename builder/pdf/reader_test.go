@@ -0,0 +1,209 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF numbers the objects from 1 and, when xref is set, ends the file
+// with a cross reference table. Without it the file has to be repaired.
+func buildPDF(objects []string, xref bool) []byte {
+	if !xref {
+		var b bytes.Buffer
+		b.WriteString("%PDF-1.4\n")
+		for i, obj := range objects {
+			fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+		}
+		b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+		return b.Bytes()
+	}
+	return buildPDFWithTrailer(objects, "")
+}
+
+// buildPDFWithTrailer is buildPDF with a cross reference table and more
+// trailer entries, e.g. /Info or /Encrypt.
+func buildPDFWithTrailer(objects []string, trailer string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	start := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R %s>>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, start)
+	return b.Bytes()
+}
+
+// buildXrefStreamPDF ends the file with an xref stream with the given
+// widths and entries, which must be already encoded.
+func buildXrefStreamPDF(objects []string, widths string, entries []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	start := b.Len()
+	fmt.Fprintf(&b, "%d 0 obj\n<< /Type /XRef /Size %d /W %s /Root 1 0 R /Length %d >>\nstream\n",
+		len(objects)+1, len(objects)+2, widths, len(entries))
+	b.Write(entries)
+	fmt.Fprintf(&b, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", start)
+	return b.Bytes()
+}
+
+func stream(dict, data string) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+var helloObjects = []string{
+	"<< /Type /Catalog /Pages 2 0 R >>",
+	"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+	"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+	stream("", "BT /F1 12 Tf 72 712 Td (Hola mundo) Tj ET"),
+	"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+}
+
+// exercise calls everything that reads objects, none of it may panic.
+func exercise(r *Reader) {
+	for num := 0; num < 10; num++ {
+		r.Object(num)
+	}
+	r.Text()
+	r.LayoutText()
+	r.Signatures()
+	r.EmbeddedFiles()
+}
+
+func TestOpen(t *testing.T) {
+	// the xref stream entries of the objects of helloObjects, type 1 with
+	// a 2 byte offset
+	helloStream := buildXrefStreamPDF(helloObjects, "[1 2 1]", nil)
+	var entries []byte
+	entries = append(entries, 0, 0, 0, 0)
+	for i := range helloObjects {
+		offset := bytes.Index(helloStream, []byte(fmt.Sprintf("\n%d 0 obj", i+1))) + 1
+		entries = append(entries, 1, byte(offset>>8), byte(offset), 0)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		repaired bool
+	}{
+		{"xref table", buildPDF(helloObjects, true), false},
+		{"xref stream", buildXrefStreamPDF(helloObjects, "[1 2 1]", entries), false},
+		{"repaired", buildPDF(helloObjects, false), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := Open(test.data)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if r.Repaired != test.repaired {
+				t.Errorf("Repaired = %v, want %v", r.Repaired, test.repaired)
+			}
+			texts := r.Text()
+			if len(texts) != 1 || !strings.Contains(texts[0], "Hola mundo") {
+				t.Errorf("Text() = %q, want one page with %q", texts, "Hola mundo")
+			}
+		})
+	}
+}
+
+func TestOpenNotPDF(t *testing.T) {
+	_, err := Open([]byte("<html></html>"))
+	if !errors.Is(err, ErrNotPDF) {
+		t.Errorf("Open() error = %v, want %v", err, ErrNotPDF)
+	}
+}
+
+// TestOpenMalformed has inputs that used to crash the parser.
+func TestOpenMalformed(t *testing.T) {
+	objectStreamHeader := "3 -100 "
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{
+			"negative xref stream width",
+			buildXrefStreamPDF(helloObjects, "[1 -1 2]", []byte{1, 0, 9, 1, 0, 9}),
+		},
+		{
+			"too wide xref stream width",
+			buildXrefStreamPDF(helloObjects, "[1 9 2]", bytes.Repeat([]byte{1}, 24)),
+		},
+		{
+			"negative object stream offset",
+			buildPDF([]string{
+				"<< /Type /Catalog /Pages 3 0 R >>",
+				stream(fmt.Sprintf("/Type /ObjStm /N 1 /First %d", len(objectStreamHeader)),
+					objectStreamHeader+"<< /Type /Pages /Kids [] /Count 0 >>"),
+			}, false),
+		},
+		{
+			"self referential stream length",
+			buildPDF([]string{
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+				"<< /Length 4 0 R >>\nstream\nBT ET\nendstream",
+			}, false),
+		},
+		{
+			"mutually referential stream lengths",
+			buildPDF([]string{
+				"<< /Type /Catalog /Pages 2 0 R >>",
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+				"<< /Length 5 0 R >>\nstream\nBT ET\nendstream",
+				"<< /Length 4 0 R >>\nstream\nBT ET\nendstream",
+			}, true),
+		},
+		{
+			"deeply nested arrays",
+			buildPDF([]string{
+				"<< /Type /Catalog /Pages 2 0 R /X " + strings.Repeat("[", 100000) + " >>",
+			}, false),
+		},
+		{
+			"truncated",
+			buildPDF(helloObjects, true)[:200],
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := Open(test.data)
+			if err != nil {
+				return
+			}
+			exercise(r)
+		})
+	}
+}
+
+func TestSelfReferentialLength(t *testing.T) {
+	r, err := Open(buildPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+		"<< /Length 3 0 R >>\nstream\nBT ET\nendstream",
+	}, true))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	stream, ok := r.Object(3).(*Stream)
+	if !ok {
+		t.Fatalf("Object(3) = %T, want *Stream", r.Object(3))
+	}
+	data, err := r.StreamData(stream)
+	if err != nil || string(data) != "BT ET" {
+		t.Errorf("StreamData() = %q, %v, want %q", data, err, "BT ET")
+	}
+}
@@ -0,0 +1,149 @@
+package pdf
+
+import (
+	"sort"
+	"time"
+)
+
+// Signature is a signature dictionary and the bytes it signs.
+type Signature struct {
+	// Field is the full name of the signature form field.
+	Field     string
+	Name      string
+	Reason    string
+	Location  string
+	SubFilter string
+	// Time is the /M entry, the signing time according to the signer's
+	// computer.
+	Time      time.Time
+	ByteRange []int
+	// Contents is the pkcs#7 or cms signature, read from the file
+	// between the two signed ranges.
+	Contents []byte
+	// Certificate is the der encoded certificate of adbe.x509.rsa_sha1
+	// signatures.
+	Certificate []byte
+}
+
+// SignedData returns the bytes covered by the byte range, or false when the
+// range falls outside the file.
+func (r *Reader) SignedData(sig *Signature) ([]byte, bool) {
+	if len(sig.ByteRange) != 4 {
+		return nil, false
+	}
+	data := make([]byte, 0)
+	for i := 0; i < 4; i += 2 {
+		start, length := sig.ByteRange[i], sig.ByteRange[i+1]
+		if start < 0 || length < 0 || start+length > len(r.data) {
+			return nil, false
+		}
+		data = append(data, r.data[start:start+length]...)
+	}
+	return data, true
+}
+
+// Size is the length of the file.
+func (r *Reader) Size() int {
+	return len(r.data)
+}
+
+// Signatures returns the signatures of the document, in the order they were
+// applied.
+func (r *Reader) Signatures() []*Signature {
+	signatures := make([]*Signature, 0)
+	seen := map[int]bool{}
+	add := func(field string, v Object) {
+		dict := r.Dict(v)
+		if dict == nil || dict["ByteRange"] == nil {
+			return
+		}
+		sig := r.signature(dict)
+		if sig == nil || seen[sig.ByteRange[2]] {
+			return
+		}
+		seen[sig.ByteRange[2]] = true
+		sig.Field = field
+		signatures = append(signatures, sig)
+	}
+	var walk func(obj Object, parent string, fieldType Name, depth int)
+	walk = func(obj Object, parent string, fieldType Name, depth int) {
+		field := r.Dict(obj)
+		if field == nil || depth > maxNesting {
+			return
+		}
+		name := parent
+		if partial := TextString(r.Resolve(field["T"])); partial != "" {
+			if name != "" {
+				name += "."
+			}
+			name += partial
+		}
+		if ft, ok := r.Resolve(field["FT"]).(Name); ok {
+			fieldType = ft
+		}
+		if fieldType == "Sig" && field["V"] != nil {
+			add(name, field["V"])
+		}
+		for _, kid := range r.Array(field["Kids"]) {
+			walk(kid, name, fieldType, depth+1)
+		}
+	}
+	for _, field := range r.Array(r.Dict(r.Catalog()["AcroForm"])["Fields"]) {
+		walk(field, "", "", 0)
+	}
+	if len(signatures) == 0 {
+		// some writers do not list the signature in the form
+		nums := make([]int, 0, len(r.xref))
+		for num := range r.xref {
+			nums = append(nums, num)
+		}
+		sort.Ints(nums)
+		for _, num := range nums {
+			if dict, ok := r.Object(num).(Dict); ok && dict["Type"] == Name("Sig") {
+				add("", dict)
+			}
+		}
+	}
+	sort.SliceStable(signatures, func(i, j int) bool {
+		return signatures[i].ByteRange[2] < signatures[j].ByteRange[2]
+	})
+	return signatures
+}
+
+func (r *Reader) signature(dict Dict) *Signature {
+	byteRange := r.Array(dict["ByteRange"])
+	if len(byteRange) != 4 {
+		return nil
+	}
+	sig := &Signature{
+		Name:      TextString(r.Resolve(dict["Name"])),
+		Reason:    TextString(r.Resolve(dict["Reason"])),
+		Location:  TextString(r.Resolve(dict["Location"])),
+		SubFilter: TextString(r.Resolve(dict["SubFilter"])),
+		ByteRange: make([]int, 4),
+	}
+	for i, v := range byteRange {
+		sig.ByteRange[i], _ = r.Int(v)
+	}
+	sig.Time, _ = Date(r.Resolve(dict["M"]))
+	// the contents are not encrypted, so they are read from the gap
+	// between the ranges instead of the parsed object
+	start := sig.ByteRange[0] + sig.ByteRange[1]
+	end := sig.ByteRange[2]
+	if start >= 0 && start < end && end <= len(r.data) {
+		gap := r.data[start:end]
+		l := &lexer{data: gap}
+		if tok, err := l.token(); err == nil {
+			if s, ok := tok.(String); ok {
+				sig.Contents = []byte(s)
+			}
+		}
+	}
+	if certificate, ok := r.Resolve(dict["Cert"]).(String); ok {
+		sig.Certificate = []byte(certificate)
+	} else if certificates := r.Array(dict["Cert"]); len(certificates) > 0 {
+		certificate, _ := r.Resolve(certificates[0]).(String)
+		sig.Certificate = []byte(certificate)
+	}
+	return sig
+}
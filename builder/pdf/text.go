@@ -0,0 +1,479 @@
+package pdf
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strings"
+)
+
+// maxFormDepth limits nested form xobjects.
+const maxFormDepth = 8
+
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// mul returns m × n.
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2], m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2], m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4], m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func translate(x, y float64) matrix {
+	return matrix{1, 0, 0, 1, x, y}
+}
+
+type graphicsState struct {
+	ctm       matrix
+	font      *font
+	fontSize  float64
+	charSpace float64
+	wordSpace float64
+	scale     float64
+	leading   float64
+	rise      float64
+}
+
+// Span is a string shown on a page. The coordinates are in pdf units from
+// the bottom left corner, Y is the baseline.
+type Span struct {
+	Text string
+	X0   float64
+	X1   float64
+	Y    float64
+	Size float64
+}
+
+// textWriter joins the shown strings, breaking lines when the baseline
+// changes and adding spaces between separated strings.
+type textWriter struct {
+	b        strings.Builder
+	spans    []Span
+	started  bool
+	lastX    float64
+	lastY    float64
+	lastSize float64
+}
+
+func (w *textWriter) add(text string, x0, y0, x1, size float64) {
+	if text == "" {
+		return
+	}
+	w.spans = append(w.spans, Span{Text: text, X0: x0, X1: x1, Y: y0, Size: size})
+	if w.started {
+		tolerance := math.Max(size, w.lastSize) * 0.5
+		gap := x0 - w.lastX
+		written := w.b.String()
+		endsWithSpace := strings.HasSuffix(written, " ") || strings.HasSuffix(written, "\n")
+		switch {
+		case math.Abs(y0-w.lastY) > tolerance:
+			w.b.WriteString("\n")
+		case gap > size*0.15 && !endsWithSpace && !strings.HasPrefix(text, " "):
+			w.b.WriteString(" ")
+		}
+	}
+	w.b.WriteString(text)
+	w.started = true
+	w.lastX = x1
+	w.lastY = y0
+	w.lastSize = size
+}
+
+type interpreter struct {
+	r     *Reader
+	w     *textWriter
+	fonts map[Ref]*font
+	forms map[Ref]bool
+	state graphicsState
+	stack []graphicsState
+	tm    matrix
+	tlm   matrix
+	depth int
+}
+
+func (in *interpreter) font(resources Dict, name Name) *font {
+	obj := in.r.Dict(resources["Font"])[name]
+	ref, isRef := obj.(Ref)
+	if isRef {
+		if f, found := in.fonts[ref]; found {
+			return f
+		}
+	}
+	dict := in.r.Dict(obj)
+	if dict == nil {
+		return nil
+	}
+	f := in.r.loadFont(dict)
+	if isRef {
+		in.fonts[ref] = f
+	}
+	return f
+}
+
+func (in *interpreter) show(s String) {
+	f := in.state.font
+	if f == nil {
+		return
+	}
+	st := &in.state
+	textMatrix := func() matrix {
+		return matrix{st.fontSize * st.scale, 0, 0, st.fontSize, 0, st.rise}.mul(in.tm).mul(st.ctm)
+	}
+	start := textMatrix()
+	size := math.Hypot(start[2], start[3])
+	var text strings.Builder
+	for _, g := range f.decode(s) {
+		text.WriteString(g.text)
+		tx := g.width*st.fontSize + st.charSpace
+		if len(g.code) == 1 && g.code[0] == ' ' {
+			tx += st.wordSpace
+		}
+		in.tm = translate(tx*st.scale, 0).mul(in.tm)
+	}
+	end := textMatrix()
+	in.w.add(text.String(), start[4], start[5], end[4], size)
+}
+
+func (in *interpreter) nextLine(tx, ty float64) {
+	in.tlm = translate(tx, ty).mul(in.tlm)
+	in.tm = in.tlm
+}
+
+func number(obj Object) float64 {
+	switch v := obj.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+func numbers(operands []Object, n int) ([]float64, bool) {
+	if len(operands) < n {
+		return nil, false
+	}
+	values := make([]float64, n)
+	for i, obj := range operands[len(operands)-n:] {
+		values[i] = number(obj)
+	}
+	return values, true
+}
+
+func (in *interpreter) form(resources Dict, name Name) {
+	obj := in.r.Dict(resources["XObject"])[name]
+	ref, _ := obj.(Ref)
+	stream, ok := in.r.Resolve(obj).(*Stream)
+	if !ok || stream.Dict["Subtype"] != Name("Form") || in.depth >= maxFormDepth || in.forms[ref] {
+		return
+	}
+	data, err := in.r.StreamData(stream)
+	if err != nil {
+		return
+	}
+	formResources := in.r.Dict(stream.Dict["Resources"])
+	if formResources == nil {
+		formResources = resources
+	}
+	saved := in.state
+	savedStack := len(in.stack)
+	if m := in.r.Array(stream.Dict["Matrix"]); len(m) == 6 {
+		var fm matrix
+		for i := range fm {
+			fm[i], _ = in.r.Float(m[i])
+		}
+		in.state.ctm = fm.mul(in.state.ctm)
+	}
+	if ref.Num != 0 {
+		in.forms[ref] = true
+		defer delete(in.forms, ref)
+	}
+	in.depth++
+	in.run(data, formResources)
+	in.depth--
+	in.state = saved
+	in.stack = in.stack[:savedStack]
+}
+
+// skipInlineImage moves past the data of an inline image, after ID.
+func skipInlineImage(l *lexer) {
+	for l.pos < len(l.data) {
+		i := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if i < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		end := l.pos + i
+		l.pos = end + 2
+		if end > 0 && isWhite(l.data[end-1]) && (l.pos >= len(l.data) || isWhite(l.data[l.pos])) {
+			return
+		}
+	}
+}
+
+func (in *interpreter) run(data []byte, resources Dict) {
+	l := &lexer{data: data}
+	operands := make([]Object, 0, 8)
+	for {
+		tok, err := l.token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			operands = operands[:0]
+			continue
+		}
+		kw, isKeyword := tok.(Keyword)
+		if !isKeyword || kw == "[" || kw == "<<" {
+			obj, err := l.objectFrom(tok, 0)
+			if err == nil {
+				operands = append(operands, obj)
+			}
+			continue
+		}
+		in.operator(l, kw, operands, resources)
+		operands = operands[:0]
+	}
+}
+
+func (in *interpreter) operator(l *lexer, op Keyword, operands []Object, resources Dict) {
+	st := &in.state
+	switch op {
+	case "q":
+		in.stack = append(in.stack, in.state)
+	case "Q":
+		if len(in.stack) > 0 {
+			in.state = in.stack[len(in.stack)-1]
+			in.stack = in.stack[:len(in.stack)-1]
+		}
+	case "cm":
+		if v, ok := numbers(operands, 6); ok {
+			st.ctm = matrix{v[0], v[1], v[2], v[3], v[4], v[5]}.mul(st.ctm)
+		}
+	case "BT":
+		in.tm = identity
+		in.tlm = identity
+	case "Tf":
+		if len(operands) >= 2 {
+			name, _ := operands[len(operands)-2].(Name)
+			st.font = in.font(resources, name)
+			st.fontSize = number(operands[len(operands)-1])
+		}
+	case "Tc":
+		if v, ok := numbers(operands, 1); ok {
+			st.charSpace = v[0]
+		}
+	case "Tw":
+		if v, ok := numbers(operands, 1); ok {
+			st.wordSpace = v[0]
+		}
+	case "Tz":
+		if v, ok := numbers(operands, 1); ok {
+			st.scale = v[0] / 100
+		}
+	case "TL":
+		if v, ok := numbers(operands, 1); ok {
+			st.leading = v[0]
+		}
+	case "Ts":
+		if v, ok := numbers(operands, 1); ok {
+			st.rise = v[0]
+		}
+	case "Td":
+		if v, ok := numbers(operands, 2); ok {
+			in.nextLine(v[0], v[1])
+		}
+	case "TD":
+		if v, ok := numbers(operands, 2); ok {
+			st.leading = -v[1]
+			in.nextLine(v[0], v[1])
+		}
+	case "Tm":
+		if v, ok := numbers(operands, 6); ok {
+			in.tlm = matrix{v[0], v[1], v[2], v[3], v[4], v[5]}
+			in.tm = in.tlm
+		}
+	case "T*":
+		in.nextLine(0, -st.leading)
+	case "Tj":
+		if len(operands) > 0 {
+			if s, ok := operands[len(operands)-1].(String); ok {
+				in.show(s)
+			}
+		}
+	case "'":
+		in.nextLine(0, -st.leading)
+		if len(operands) > 0 {
+			if s, ok := operands[len(operands)-1].(String); ok {
+				in.show(s)
+			}
+		}
+	case "\"":
+		if len(operands) >= 3 {
+			st.wordSpace = number(operands[len(operands)-3])
+			st.charSpace = number(operands[len(operands)-2])
+		}
+		in.nextLine(0, -st.leading)
+		if len(operands) > 0 {
+			if s, ok := operands[len(operands)-1].(String); ok {
+				in.show(s)
+			}
+		}
+	case "TJ":
+		if len(operands) == 0 {
+			return
+		}
+		items, _ := operands[len(operands)-1].(Array)
+		for _, item := range items {
+			switch v := item.(type) {
+			case String:
+				in.show(v)
+			case int64, float64:
+				tx := -number(v) / 1000 * st.fontSize * st.scale
+				in.tm = translate(tx, 0).mul(in.tm)
+			}
+		}
+	case "Do":
+		if len(operands) > 0 {
+			if name, ok := operands[len(operands)-1].(Name); ok {
+				in.form(resources, name)
+			}
+		}
+	case "BI":
+		for {
+			tok, err := l.token()
+			if err != nil {
+				return
+			}
+			if tok == Keyword("ID") {
+				break
+			}
+		}
+		skipInlineImage(l)
+	}
+}
+
+// Pages returns the page dictionaries in order, with their inherited
+// attributes set.
+func (r *Reader) Pages() []Dict {
+	pages := make([]Dict, 0)
+	visited := map[Ref]bool{}
+	var walk func(obj Object, inherited Dict, depth int)
+	walk = func(obj Object, inherited Dict, depth int) {
+		if ref, ok := obj.(Ref); ok {
+			if visited[ref] {
+				return
+			}
+			visited[ref] = true
+		}
+		node := r.Dict(obj)
+		if node == nil || depth > maxNesting {
+			return
+		}
+		attributes := Dict{}
+		for k, v := range inherited {
+			attributes[k] = v
+		}
+		for _, k := range []Name{"Resources", "MediaBox", "CropBox", "Rotate"} {
+			if v, found := node[k]; found {
+				attributes[k] = v
+			}
+		}
+		kids, hasKids := r.Resolve(node["Kids"]).(Array)
+		if node["Type"] == Name("Pages") || (hasKids && node["Type"] != Name("Page")) {
+			for _, kid := range kids {
+				walk(kid, attributes, depth+1)
+			}
+			return
+		}
+		page := Dict{}
+		for k, v := range node {
+			page[k] = v
+		}
+		for k, v := range attributes {
+			page[k] = v
+		}
+		pages = append(pages, page)
+	}
+	walk(r.Catalog()["Pages"], Dict{}, 0)
+	return pages
+}
+
+// PageContent returns the concatenated content streams of a page.
+func (r *Reader) PageContent(page Dict) []byte {
+	var streams Array
+	switch contents := r.Resolve(page["Contents"]).(type) {
+	case *Stream:
+		streams = Array{contents}
+	case Array:
+		streams = contents
+	}
+	var b bytes.Buffer
+	for _, obj := range streams {
+		stream, ok := r.Resolve(obj).(*Stream)
+		if !ok {
+			continue
+		}
+		data, err := r.StreamData(stream)
+		if err != nil && len(data) == 0 {
+			continue
+		}
+		b.Write(data)
+		b.WriteString("\n")
+	}
+	return b.Bytes()
+}
+
+func (r *Reader) interpret(page Dict) *textWriter {
+	in := &interpreter{
+		r:     r,
+		w:     &textWriter{},
+		fonts: map[Ref]*font{},
+		forms: map[Ref]bool{},
+		state: graphicsState{ctm: identity, scale: 1},
+		tm:    identity,
+		tlm:   identity,
+	}
+	in.run(r.PageContent(page), r.Dict(page["Resources"]))
+	return in.w
+}
+
+// PageText returns the text of a page in the order it is drawn, a line per
+// baseline.
+func (r *Reader) PageText(page Dict) string {
+	text := r.interpret(page).b.String()
+	if text != "" {
+		text += "\n"
+	}
+	return text
+}
+
+// Text returns the text of every page.
+func (r *Reader) Text() []string {
+	pages := r.Pages()
+	texts := make([]string, len(pages))
+	for i, page := range pages {
+		texts[i] = r.PageText(page)
+	}
+	return texts
+}
+
+// PageSpans returns the strings shown on a page, in the order they are drawn.
+func (r *Reader) PageSpans(page Dict) []Span {
+	return r.interpret(page).spans
+}
+
+// LayoutText returns the text of every page with its physical layout, like
+// pdftotext -layout.
+func (r *Reader) LayoutText() []string {
+	pages := r.Pages()
+	texts := make([]string, len(pages))
+	for i, page := range pages {
+		texts[i] = LayoutText(r.PageSpans(page))
+	}
+	return texts
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	extracttext "github.com/odia/juscaba/extracttext"
+	shared "github.com/odia/juscaba/shared"
+	log "github.com/sirupsen/logrus"
+)
+
+const lowConfidenceReason = "low-confidence"
+const littleTextReason = "little-text"
+
+// QualityDocument is a documento that probably needs manual transcription.
+type QualityDocument struct {
+	URL                string   `json:"url"`
+	Nombre             string   `json:"nombre"`
+	ActuacionID        string   `json:"actuacionId"`
+	NumeroDeExpediente string   `json:"numeroDeExpediente"`
+	Pages              int      `json:"pages"`
+	OCRPages           int      `json:"ocrPages"`
+	Confidence         float64  `json:"confidence"`
+	CharsPerPage       float64  `json:"charsPerPage"`
+	Reasons            []string `json:"reasons"`
+}
+
+type QualityReport struct {
+	MinConfidence float64            `json:"minConfidence"`
+	MinChars      float64            `json:"minChars"`
+	Checked       int                `json:"checked"`
+	Documents     []*QualityDocument `json:"documents"`
+}
+
+// documentQuality returns nil when the documento looks fine.
+func documentQuality(doc *shared.Documento, minConfidence, minChars float64) *QualityDocument {
+	q := &QualityDocument{
+		URL:                doc.URL,
+		Nombre:             doc.Nombre,
+		ActuacionID:        doc.ActuacionID,
+		NumeroDeExpediente: doc.NumeroDeExpediente,
+		Pages:              len(doc.Pages),
+		Confidence:         doc.Confidence,
+		Reasons:            make([]string, 0),
+	}
+	for _, page := range doc.Pages {
+		if page.Source == shared.OCRSource {
+			q.OCRPages++
+		}
+	}
+	pages := len(doc.Pages)
+	if pages == 0 {
+		pages = 1
+	}
+	q.CharsPerPage = float64(extracttext.TextChars(doc.Content)) / float64(pages)
+	if q.OCRPages > 0 && q.Confidence < minConfidence {
+		q.Reasons = append(q.Reasons, lowConfidenceReason)
+	}
+	if q.CharsPerPage < minChars {
+		q.Reasons = append(q.Reasons, littleTextReason)
+	}
+	if len(q.Reasons) == 0 {
+		return nil
+	}
+	return q
+}
+
+func quality(argv []string) error {
+	var reportPath string
+	var minConfidence, minChars float64
+	flags := newFlagSet("quality")
+	flags.StringVar(&reportPath, "report", "", "json report destination path (default stdout)")
+	flags.Float64Var(&minConfidence, "min-confidence", 60, "documents whose ocr'd pages have a lower mean confidence are listed")
+	flags.Float64Var(&minChars, "min-chars", 100, "documents with fewer non blank characters per page are listed")
+	flags.Parse(argv)
+
+	report := &QualityReport{
+		MinConfidence: minConfidence,
+		MinChars:      minChars,
+		Documents:     make([]*QualityDocument, 0),
+	}
+	for _, p := range flags.Args() {
+		exp, err := shared.ReadExpediente(p)
+		if err != nil {
+			return err
+		}
+		for _, doc := range exp.Documentos() {
+			report.Checked++
+			if q := documentQuality(doc, minConfidence, minChars); q != nil {
+				report.Documents = append(report.Documents, q)
+			}
+		}
+	}
+
+	out := os.Stdout
+	if reportPath != "" {
+		var err error
+		out, err = os.Create(reportPath)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"report": reportPath,
+				"error":  err.Error(),
+			}).Error("failed to create report")
+			return err
+		}
+		defer out.Close()
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(report)
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"checked": report.Checked,
+		"flagged": len(report.Documents),
+	}).Info("checked text quality")
+	return nil
+}
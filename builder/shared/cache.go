@@ -0,0 +1,81 @@
+package shared
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const CacheDirectory = "cache"
+
+func (s *FileManager) cachePath(key string) string {
+	return path.Join(s.Directory, CacheDirectory, key+".json")
+}
+
+// ReadCache decodes the cache entry with the given key into v. It returns
+// false if there is no valid entry.
+func (s *FileManager) ReadCache(key string, v interface{}) bool {
+	fp, err := os.Open(s.cachePath(key))
+	if err != nil {
+		return false
+	}
+	defer fp.Close()
+	err = json.NewDecoder(fp).Decode(v)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"key":   key,
+			"error": err.Error(),
+		}).Warn("error decoding cache entry")
+		return false
+	}
+	return true
+}
+
+func (s *FileManager) WriteCache(key string, v interface{}) error {
+	err := os.MkdirAll(path.Join(s.Directory, CacheDirectory), 0755)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("failed to create cache directory")
+		return err
+	}
+	p := s.cachePath(key)
+	tmpPath := p + ".tmp"
+	fp, err := os.Create(tmpPath)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"key":   key,
+			"error": err.Error(),
+		}).Error("failed to create cache entry")
+		return err
+	}
+	err = json.NewEncoder(fp).Encode(v)
+	fp.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		log.WithFields(log.Fields{
+			"key":   key,
+			"error": err.Error(),
+		}).Error("failed to write cache entry")
+		return err
+	}
+	return os.Rename(tmpPath, p)
+}
+
+// RemoveCache removes every cache entry whose key starts with prefix.
+func (s *FileManager) RemoveCache(prefix string) error {
+	matches, err := filepath.Glob(path.Join(s.Directory, CacheDirectory, prefix+"*.json"))
+	if err != nil {
+		return err
+	}
+	for _, p := range matches {
+		err = os.Remove(p)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
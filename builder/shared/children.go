@@ -0,0 +1,29 @@
+package shared
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ChildURL identifies a file contained in the document at parentURL, e.g. an
+// attachment of a pdf.
+func ChildURL(parentURL, name string) string {
+	return fmt.Sprintf("%s#%s", parentURL, url.PathEscape(name))
+}
+
+// SaveChildFile stores a file contained in the document at parentURL. Files
+// already saved with the same content are not written again.
+func (s *FileManager) SaveChildFile(parentURL, name string, content []byte) (*SavedFile, error) {
+	sf := NewSavedFile(ChildURL(parentURL, name), "")
+	sf.Parent = parentURL
+	sf.SetContent(content)
+	sf.DestinationFilename = sf.ContentHash + ContentTypeExtension(sf.ContentType)
+	if saved, err := s.SavedFileForURL(sf.SourceURL); err == nil && saved.ContentHash == sf.ContentHash {
+		return saved, nil
+	}
+	err := s.SaveSavedFile(sf, content)
+	if err != nil {
+		return nil, err
+	}
+	return sf, nil
+}
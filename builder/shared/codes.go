@@ -0,0 +1,10 @@
+package shared
+
+// Code is a qr code or barcode found in a page of a document.
+type Code struct {
+	Page int `json:"page"`
+	// Type is the symbology as reported by zbar, e.g. QR-Code or CODE-128.
+	Type string `json:"type"`
+	// Data is the decoded payload, often a verification url.
+	Data string `json:"data"`
+}
@@ -0,0 +1,95 @@
+package shared
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const PDFContentType = "application/pdf"
+const DOCXContentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+const ODTContentType = "application/vnd.oasis.opendocument.text"
+const RTFContentType = "application/rtf"
+const HTMLContentType = "text/html"
+const TextContentType = "text/plain"
+const ZipContentType = "application/zip"
+const RARContentType = "application/vnd.rar"
+const PNGContentType = "image/png"
+const JPEGContentType = "image/jpeg"
+const GIFContentType = "image/gif"
+const BMPContentType = "image/bmp"
+const TIFFContentType = "image/tiff"
+
+var contentTypeExtensions = map[string]string{
+	PDFContentType:  ".pdf",
+	DOCXContentType: ".docx",
+	ODTContentType:  ".odt",
+	RTFContentType:  ".rtf",
+	HTMLContentType: ".html",
+	TextContentType: ".txt",
+	ZipContentType:  ".zip",
+	RARContentType:  ".rar",
+	PNGContentType:  ".png",
+	JPEGContentType: ".jpg",
+	GIFContentType:  ".gif",
+	BMPContentType:  ".bmp",
+	TIFFContentType: ".tif",
+}
+
+// ContentTypeExtension returns the file extension for a MIME type, .bin for
+// unknown types.
+func ContentTypeExtension(contentType string) string {
+	if ext, found := contentTypeExtensions[contentType]; found {
+		return ext
+	}
+	return ".bin"
+}
+
+// zipContentType tells office documents apart from other zip files.
+func zipContentType(content []byte) string {
+	r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return ZipContentType
+	}
+	for _, f := range r.File {
+		switch f.Name {
+		case "word/document.xml":
+			return DOCXContentType
+		case "mimetype":
+			fp, err := f.Open()
+			if err != nil {
+				continue
+			}
+			mimetype, _ := io.ReadAll(io.LimitReader(fp, 256))
+			fp.Close()
+			if strings.TrimSpace(string(mimetype)) == ODTContentType {
+				return ODTContentType
+			}
+		}
+	}
+	return ZipContentType
+}
+
+// DetectContentType returns the MIME type of a document from its content.
+func DetectContentType(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte("%PDF-")):
+		return PDFContentType
+	case bytes.HasPrefix(content, []byte(`{\rtf`)):
+		return RTFContentType
+	case bytes.HasPrefix(content, []byte("Rar!\x1a\x07")):
+		return RARContentType
+	case bytes.HasPrefix(content, []byte("II*\x00")), bytes.HasPrefix(content, []byte("MM\x00*")):
+		return TIFFContentType
+	}
+	contentType := http.DetectContentType(content)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if contentType == ZipContentType {
+		return zipContentType(content)
+	}
+	return contentType
+}
@@ -0,0 +1,67 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LayoutDirectory holds the word positions of the ocr'd documents.
+const LayoutDirectory = "layout"
+
+// DerivedDirectories hold files generated from the content of saved files,
+// named after the content hash.
+var DerivedDirectories = []string{LayoutDirectory, TablesDirectory}
+
+// DerivedFilename is the name of a file derived from a saved file.
+func (sf *SavedFile) DerivedFilename(ext string) string {
+	return sf.Hash() + ext
+}
+
+// SaveDerivedFile writes a file derived from sf to the given directory of the
+// store.
+func (s *FileManager) SaveDerivedFile(directory string, sf *SavedFile, ext string, content []byte) error {
+	dir := path.Join(s.Directory, directory)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"directory": dir,
+			"error":     err.Error(),
+		}).Error("failed to create derived files directory")
+		return err
+	}
+	p := path.Join(dir, sf.DerivedFilename(ext))
+	err = os.WriteFile(p, content, 0644)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Error("failed to write derived file")
+	}
+	return err
+}
+
+// DerivedURL is the mirror url of a file derived from sf.
+func (s *FileManager) DerivedURL(directory string, sf *SavedFile, ext string) string {
+	return fmt.Sprintf("%s/%s/%s", s.MirrorBaseURL, directory, sf.DerivedFilename(ext))
+}
+
+// RemoveDerivedFiles removes every derived file of a content hash.
+func (s *FileManager) RemoveDerivedFiles(hash string) error {
+	for _, directory := range DerivedDirectories {
+		matches, err := filepath.Glob(path.Join(s.Directory, directory, hash+".*"))
+		if err != nil {
+			return err
+		}
+		for _, p := range matches {
+			err = os.Remove(p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -1,7 +1,12 @@
 package shared
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 const FichaType = "ficha"
@@ -109,6 +114,86 @@ type Expediente struct {
 	Actuaciones []*Actuacion
 }
 
+// ReadExpediente loads an expediente from a json written by the builder.
+func ReadExpediente(p string) (*Expediente, error) {
+	fp, err := os.Open(p)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Error("failed to open expediente json")
+		return nil, err
+	}
+	defer fp.Close()
+	exp := &Expediente{}
+	err = json.NewDecoder(fp).Decode(exp)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Error("failed to decode expediente json")
+		return nil, err
+	}
+	return exp, nil
+}
+
+// Documentos returns every documento of every actuacion, including the
+// documentos they contain.
+func (exp *Expediente) Documentos() []*Documento {
+	documentos := make([]*Documento, 0)
+	for _, act := range exp.Actuaciones {
+		for _, doc := range act.Documentos {
+			documentos = append(documentos, doc.WithChildren()...)
+		}
+	}
+	return documentos
+}
+
+// WithChildren returns the documento followed by the documentos it contains,
+// recursively.
+func (d *Documento) WithChildren() []*Documento {
+	documentos := []*Documento{d}
+	for _, child := range d.Children {
+		documentos = append(documentos, child.WithChildren()...)
+	}
+	return documentos
+}
+
+const TextLayerSource = "text"
+const OCRSource = "ocr"
+
+// Page is the text of a single page of a document and how it was obtained.
+type Page struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+	Source string `json:"source"`
+	// Confidence is tesseract's mean word confidence, from 0 to 100, of the
+	// ocr'd pages.
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// PDFMetadata is read from the document information dictionary and the
+// structure of a pdf.
+type PDFMetadata struct {
+	Pages            int        `json:"pages"`
+	Title            string     `json:"title,omitempty"`
+	Author           string     `json:"author,omitempty"`
+	Subject          string     `json:"subject,omitempty"`
+	Creator          string     `json:"creator,omitempty"`
+	Producer         string     `json:"producer,omitempty"`
+	CreationDate     *time.Time `json:"creationDate,omitempty"`
+	ModificationDate *time.Time `json:"modificationDate,omitempty"`
+	Version          string     `json:"version"`
+	Encrypted        bool       `json:"encrypted"`
+	// NeedsPassword is set for encrypted files that can not be opened with
+	// the empty user password, whose other fields could not be read.
+	NeedsPassword bool `json:"needsPassword,omitempty"`
+	// Permissions lists what the security handler of encrypted files
+	// allows, e.g. "print" or "copy".
+	Permissions []string `json:"permissions,omitempty"`
+	FileSize    int64    `json:"fileSize"`
+}
+
 type Documento struct {
 	URL                string
 	MirrorURL          string
@@ -117,6 +202,28 @@ type Documento struct {
 	Type               int    `json:"type"`
 	Nombre             string `json:"nombre"`
 	Content            string `json:"content"`
+	// RawContent is the text as extracted, before it was normalized.
+	RawContent string `json:"rawContent,omitempty"`
+	Pages      []Page `json:"pages,omitempty"`
+	// Confidence is the mean confidence of the ocr'd pages, if any.
+	Confidence float64 `json:"confidence,omitempty"`
+	// LayoutURL points to the hOCR or ALTO file with the position of the
+	// ocr'd words.
+	LayoutURL string `json:"layoutURL,omitempty"`
+	// Metadata is only set for pdfs.
+	Metadata *PDFMetadata `json:"metadata,omitempty"`
+	// Signatures are the digital signatures of a pdf, in the order they
+	// were applied.
+	Signatures []Signature `json:"signatures,omitempty"`
+	// Tables are the tables found in the text layer of a pdf.
+	Tables []Table `json:"tables,omitempty"`
+	// Codes are the qr codes and barcodes found in the pages.
+	Codes []Code `json:"codes,omitempty"`
+	// Parent is the url of the documento that contains this one.
+	Parent string `json:"parent,omitempty"`
+	// Children are the documentos contained in this one, e.g. the files
+	// attached to a pdf.
+	Children []*Documento `json:"children,omitempty"`
 }
 
 func (d *Documento) GetURL() string {
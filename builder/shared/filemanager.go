@@ -1,12 +1,15 @@
 package shared
 
 import (
-	"encoding/json"
+	"crypto/sha1"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -19,6 +22,13 @@ type SavedFile struct {
 	SourceURL           string    `json:"sourceURL"`
 	DestinationFilename string    `json:"destinationFilename"`
 	FetchDate           time.Time `json:"fetchDate"`
+	ContentHash         string    `json:"contentHash,omitempty"`
+	Size                int64     `json:"size,omitempty"`
+	SHA256              string    `json:"sha256,omitempty"`
+	ContentType         string    `json:"contentType,omitempty"`
+	// Parent is the source url of the document that contains this file,
+	// for attachments that were not downloaded on their own.
+	Parent string `json:"parent,omitempty"`
 }
 
 func NewSavedFile(sourceURL, destinationFilename string) *SavedFile {
@@ -29,19 +39,88 @@ func NewSavedFile(sourceURL, destinationFilename string) *SavedFile {
 	}
 }
 
+// Hash returns the content hash of the saved file. Entries saved before the
+// hash was stored are named after it.
+func (sf *SavedFile) Hash() string {
+	if sf.ContentHash != "" {
+		return sf.ContentHash
+	}
+	return strings.TrimSuffix(sf.DestinationFilename, path.Ext(sf.DestinationFilename))
+}
+
 type FileManager struct {
 	Directory     string
 	MirrorBaseURL string
+
+	indexOnce sync.Once
+	index     *Index
+	indexErr  error
 }
 
-func (s *FileManager) metadataPath(url string) string {
-	return path.Join(s.Directory, GetSha1(url)+".json")
+// Index opens the metadata index of the directory. If there is no index yet,
+// the per-URL metadata files of previous versions are imported into it.
+func (s *FileManager) Index() (*Index, error) {
+	s.indexOnce.Do(func() {
+		p := path.Join(s.Directory, IndexFilename)
+		_, statErr := os.Stat(p)
+		s.index, s.indexErr = OpenIndex(p)
+		if s.indexErr != nil || !errors.Is(statErr, os.ErrNotExist) {
+			return
+		}
+		migrated, err := MigrateLegacyMetadata(s.index, s.Directory, false)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"directory": s.Directory,
+				"error":     err.Error(),
+			}).Warn("failed to import legacy metadata files")
+			return
+		}
+		if migrated > 0 {
+			log.WithFields(log.Fields{
+				"directory": s.Directory,
+				"migrated":  migrated,
+			}).Info("imported legacy metadata files into index")
+		}
+	})
+	return s.index, s.indexErr
+}
+
+func (s *FileManager) Close() error {
+	if s.index == nil {
+		return nil
+	}
+	return s.index.Close()
 }
 
-func (s *FileManager) destinationPath(sf *SavedFile) string {
+func (s *FileManager) DestinationPath(sf *SavedFile) string {
 	return path.Join(s.Directory, sf.DestinationFilename)
 }
 
+// SetContent fills the content dependent fields of the saved file.
+func (sf *SavedFile) SetContent(content []byte) {
+	sf.ContentHash = fmt.Sprintf("%x", sha1.Sum(content))
+	sf.Size = int64(len(content))
+	sf.SHA256 = fmt.Sprintf("%x", sha256.Sum256(content))
+	sf.ContentType = DetectContentType(content)
+}
+
+// ContentSHA256 returns the SHA-256 of the saved file, reading its content
+// when it was not stored on download.
+func (s *FileManager) ContentSHA256(sf *SavedFile) (string, error) {
+	if sf.SHA256 != "" {
+		return sf.SHA256, nil
+	}
+	content, err := os.ReadFile(s.DestinationPath(sf))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"url":   sf.SourceURL,
+			"error": err.Error(),
+		}).Error("failed to read content file")
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(content)), nil
+}
+
 func (s *FileManager) DestinationURLforSourceURL(url string) (string, error) {
 	sf, err := s.SavedFileForURL(url)
 	if err != nil {
@@ -51,40 +130,40 @@ func (s *FileManager) DestinationURLforSourceURL(url string) (string, error) {
 }
 
 func (s *FileManager) IsSaved(url string) bool {
-	_, err := os.Stat(s.metadataPath(url))
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
+	idx, err := s.Index()
+	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err.Error(),
-		}).Error("file exists")
+		}).Error("failed to open index")
+		return false
 	}
-	return err == nil
+	_, found := idx.Get(url)
+	return found
 }
 
 func (s *FileManager) SavedFileForURL(url string) (*SavedFile, error) {
-	reader, err := os.Open(s.metadataPath(url))
+	idx, err := s.Index()
 	if err != nil {
+		return nil, err
+	}
+	savedFile, found := idx.Get(url)
+	if !found {
 		log.WithFields(log.Fields{
-			"url":   url,
-			"error": err.Error(),
-		}).Info("error reading metadata file")
+			"url": url,
+		}).Info("url not found in index")
 		return nil, FileNotSaved
 	}
-	defer reader.Close()
-
-	var savedFile SavedFile
-	err = json.NewDecoder(reader).Decode(&savedFile)
-	if err != nil {
+	if savedFile.DestinationFilename == "" {
 		log.WithFields(log.Fields{
-			"url":   url,
-			"error": err.Error(),
-		}).Warn("error decoding metadata file")
+			"url": url,
+		}).Warn("index entry has no destination file")
 		return nil, InvalidSavedFile
 	}
-	return &savedFile, nil
+	return savedFile, nil
 }
 
 func (s *FileManager) SaveSavedFile(sf *SavedFile, content []byte) error {
-	contentWriter, err := os.Create(s.destinationPath(sf))
+	contentWriter, err := os.Create(s.DestinationPath(sf))
 	if err != nil {
 		log.WithFields(log.Fields{
 			"savedFile": sf,
@@ -102,37 +181,80 @@ func (s *FileManager) SaveSavedFile(sf *SavedFile, content []byte) error {
 		return err
 	}
 
-	metadataWriter, err := os.Create(s.metadataPath(sf.SourceURL))
+	idx, err := s.Index()
 	if err != nil {
-		log.WithFields(log.Fields{
-			"savedFile": sf,
-			"error":     err.Error(),
-		}).Error("failed to create metadata file")
 		return err
 	}
-	defer metadataWriter.Close()
-	err = json.NewEncoder(metadataWriter).Encode(sf)
+	err = idx.Put(sf)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"savedFile": sf,
 			"error":     err.Error(),
-		}).Error("failed to write metadata file")
+		}).Error("failed to write metadata")
 		return err
 	}
 	return nil
 }
 
+// ContentType returns the MIME type of a saved file. Entries migrated from
+// the per-url metadata files do not have it, so it is detected from the
+// content file.
+func (s *FileManager) ContentType(sf *SavedFile) (string, error) {
+	if sf.ContentType != "" {
+		return sf.ContentType, nil
+	}
+	content, err := os.ReadFile(s.DestinationPath(sf))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"url":   sf.SourceURL,
+			"error": err.Error(),
+		}).Error("failed to read content file")
+		return "", err
+	}
+	return DetectContentType(content), nil
+}
+
+// RemoveUnreferencedFile removes the content file of a saved file, and the
+// files and cache entries derived from it, when no entry of the index names
+// it anymore. It is
+// used after a url is downloaded again and its content changed.
+func (s *FileManager) RemoveUnreferencedFile(sf *SavedFile) error {
+	idx, err := s.Index()
+	if err != nil {
+		return err
+	}
+	referenced := idx.Find(func(other *SavedFile) bool {
+		return other.DestinationFilename == sf.DestinationFilename
+	})
+	if len(referenced) > 0 {
+		return nil
+	}
+	err = s.removeContentFile(sf.DestinationFilename, "")
+	if err != nil {
+		return err
+	}
+	if len(idx.ByContentHash(sf.Hash())) > 0 {
+		return nil
+	}
+	err = s.RemoveDerivedFiles(sf.Hash())
+	if err != nil {
+		return err
+	}
+	return s.RemoveCache(sf.Hash() + "-")
+}
+
 func (s *FileManager) GetReader(url string) (io.Reader, error) {
 	sf, err := s.SavedFileForURL(url)
 	if err != nil {
 		return nil, err
 	}
-	fp, err := os.Open(s.destinationPath(sf))
+	fp, err := os.Open(s.DestinationPath(sf))
 	if err != nil {
 		log.WithFields(log.Fields{
 			"url":   url,
 			"error": err.Error(),
 		}).Error("failed to read content file")
+		return nil, err
 	}
 	return fp, nil
 }
@@ -0,0 +1,170 @@
+package shared
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type GCFile struct {
+	DestinationFilename string   `json:"destinationFilename"`
+	SourceURLs          []string `json:"sourceURLs"`
+	Size                int64    `json:"size"`
+}
+
+type GCReport struct {
+	DryRun       bool      `json:"dryRun"`
+	Quarantine   string    `json:"quarantine,omitempty"`
+	KeptFiles    int       `json:"keptFiles"`
+	KeptBytes    int64     `json:"keptBytes"`
+	RemovedFiles int       `json:"removedFiles"`
+	RemovedBytes int64     `json:"removedBytes"`
+	Removed      []*GCFile `json:"removed"`
+}
+
+func fileSize(p string) int64 {
+	info, err := os.Stat(p)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// CollectGarbage removes the saved files whose source url is not referenced,
+// and the content files that nothing refers to. A content file shared by a
+// referenced url is kept. When quarantine is set, files are moved there along
+// with their metadata instead of being deleted.
+func (s *FileManager) CollectGarbage(referenced map[string]bool, quarantine string, dryRun bool) (*GCReport, error) {
+	idx, err := s.Index()
+	if err != nil {
+		return nil, err
+	}
+	report := &GCReport{
+		DryRun:     dryRun,
+		Quarantine: quarantine,
+		Removed:    make([]*GCFile, 0),
+	}
+
+	kept := map[string]bool{}
+	unreferenced := map[string][]*SavedFile{}
+	for _, sf := range idx.All() {
+		if referenced[sf.SourceURL] {
+			kept[sf.DestinationFilename] = true
+		} else {
+			unreferenced[sf.DestinationFilename] = append(unreferenced[sf.DestinationFilename], sf)
+		}
+	}
+	orphans, err := s.OrphanFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range orphans {
+		unreferenced[name] = []*SavedFile{}
+	}
+	for name := range kept {
+		report.KeptFiles++
+		report.KeptBytes += fileSize(path.Join(s.Directory, name))
+	}
+
+	var quarantineIndex *Index
+	if quarantine != "" && !dryRun {
+		err = os.MkdirAll(quarantine, 0755)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"quarantine": quarantine,
+				"error":      err.Error(),
+			}).Error("failed to create quarantine directory")
+			return nil, err
+		}
+		quarantineIndex, err = OpenIndex(path.Join(quarantine, IndexFilename))
+		if err != nil {
+			return nil, err
+		}
+		defer quarantineIndex.Close()
+	}
+
+	names := make([]string, 0, len(unreferenced))
+	for name := range unreferenced {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		savedFiles := unreferenced[name]
+		gcFile := &GCFile{
+			DestinationFilename: name,
+			SourceURLs:          make([]string, 0, len(savedFiles)),
+		}
+		for _, sf := range savedFiles {
+			gcFile.SourceURLs = append(gcFile.SourceURLs, sf.SourceURL)
+		}
+		removeContent := name != "" && !kept[name]
+		if removeContent {
+			gcFile.Size = fileSize(path.Join(s.Directory, name))
+		}
+		if !dryRun {
+			err = s.removeSavedFiles(idx, quarantineIndex, savedFiles)
+			if err != nil {
+				return nil, err
+			}
+			if removeContent {
+				err = s.removeContentFile(name, quarantine)
+				if err != nil {
+					return nil, err
+				}
+				hash := strings.TrimSuffix(name, path.Ext(name))
+				err = s.RemoveCache(hash + "-")
+				if err != nil {
+					return nil, err
+				}
+				err = s.RemoveDerivedFiles(hash)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		report.Removed = append(report.Removed, gcFile)
+		if removeContent {
+			report.RemovedFiles++
+			report.RemovedBytes += gcFile.Size
+		}
+	}
+	return report, nil
+}
+
+func (s *FileManager) removeSavedFiles(idx, quarantineIndex *Index, savedFiles []*SavedFile) error {
+	for _, sf := range savedFiles {
+		if quarantineIndex != nil {
+			err := quarantineIndex.Put(sf)
+			if err != nil {
+				return err
+			}
+		}
+		err := idx.Delete(sf.SourceURL)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileManager) removeContentFile(name, quarantine string) error {
+	p := path.Join(s.Directory, name)
+	var err error
+	if quarantine != "" {
+		err = os.Rename(p, path.Join(quarantine, name))
+	} else {
+		err = os.Remove(p)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.WithFields(log.Fields{
+			"path":       p,
+			"quarantine": quarantine,
+			"error":      err.Error(),
+		}).Error("failed to remove content file")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+package shared
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const IndexFilename = "index.jsonl"
+
+// indexRecord is a single line of the index file. Later records for the same
+// source URL override earlier ones, and a deleted record removes the entry.
+type indexRecord struct {
+	SavedFile
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// Index is an append-only key-value store of SavedFile entries keyed by
+// source URL. It is loaded in memory when opened and every change is appended
+// to a single file, so it can be compacted at any time.
+type Index struct {
+	path    string
+	mu      sync.Mutex
+	fp      *os.File
+	entries map[string]*SavedFile
+	invalid []int
+}
+
+func OpenIndex(p string) (*Index, error) {
+	idx := &Index{
+		path:    p,
+		entries: map[string]*SavedFile{},
+	}
+	err := idx.load()
+	if err != nil {
+		return nil, err
+	}
+	idx.fp, err = os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Error("failed to open index file")
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (idx *Index) load() error {
+	fp, err := os.Open(idx.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		log.WithFields(log.Fields{
+			"path":  idx.path,
+			"error": err.Error(),
+		}).Error("failed to read index file")
+		return err
+	}
+	defer fp.Close()
+
+	scanner := bufio.NewScanner(fp)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record indexRecord
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil || record.SourceURL == "" {
+			log.WithFields(log.Fields{
+				"path": idx.path,
+				"line": line,
+			}).Warn("skipping invalid index record")
+			idx.invalid = append(idx.invalid, line)
+			continue
+		}
+		if record.Deleted {
+			delete(idx.entries, record.SourceURL)
+			continue
+		}
+		sf := record.SavedFile
+		idx.entries[sf.SourceURL] = &sf
+	}
+	return scanner.Err()
+}
+
+func (idx *Index) append(record *indexRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = idx.fp.Write(append(line, '\n'))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  idx.path,
+			"error": err.Error(),
+		}).Error("failed to write index record")
+	}
+	return err
+}
+
+func (idx *Index) Get(url string) (*SavedFile, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	sf, found := idx.entries[url]
+	if !found {
+		return nil, false
+	}
+	entry := *sf
+	return &entry, true
+}
+
+func (idx *Index) Put(sf *SavedFile) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	err := idx.append(&indexRecord{SavedFile: *sf})
+	if err != nil {
+		return err
+	}
+	entry := *sf
+	idx.entries[sf.SourceURL] = &entry
+	return nil
+}
+
+func (idx *Index) Delete(url string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, found := idx.entries[url]; !found {
+		return nil
+	}
+	err := idx.append(&indexRecord{SavedFile: SavedFile{SourceURL: url}, Deleted: true})
+	if err != nil {
+		return err
+	}
+	delete(idx.entries, url)
+	return nil
+}
+
+// InvalidRecords returns the line numbers of the records that could not be
+// decoded when the index was opened.
+func (idx *Index) InvalidRecords() []int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return append([]int{}, idx.invalid...)
+}
+
+func (idx *Index) Len() int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return len(idx.entries)
+}
+
+// Find returns the entries matching the filter, sorted by source URL.
+func (idx *Index) Find(filter func(*SavedFile) bool) []*SavedFile {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	res := make([]*SavedFile, 0)
+	for _, sf := range idx.entries {
+		if filter == nil || filter(sf) {
+			entry := *sf
+			res = append(res, &entry)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].SourceURL < res[j].SourceURL
+	})
+	return res
+}
+
+func (idx *Index) All() []*SavedFile {
+	return idx.Find(nil)
+}
+
+func (idx *Index) FetchedBefore(t time.Time) []*SavedFile {
+	return idx.Find(func(sf *SavedFile) bool {
+		return sf.FetchDate.Before(t)
+	})
+}
+
+func (idx *Index) ByContentHash(hash string) []*SavedFile {
+	return idx.Find(func(sf *SavedFile) bool {
+		return sf.Hash() == hash
+	})
+}
+
+// Compact rewrites the index file keeping only the live entries.
+func (idx *Index) Compact() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	tmpPath := idx.path + ".tmp"
+	fp, err := os.Create(tmpPath)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  tmpPath,
+			"error": err.Error(),
+		}).Error("failed to create index file")
+		return err
+	}
+	urls := make([]string, 0, len(idx.entries))
+	for url := range idx.entries {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	w := bufio.NewWriter(fp)
+	encoder := json.NewEncoder(w)
+	for _, url := range urls {
+		err = encoder.Encode(&indexRecord{SavedFile: *idx.entries[url]})
+		if err != nil {
+			fp.Close()
+			return err
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		fp.Close()
+		return err
+	}
+	err = fp.Close()
+	if err != nil {
+		return err
+	}
+
+	idx.fp.Close()
+	err = os.Rename(tmpPath, idx.path)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  idx.path,
+			"error": err.Error(),
+		}).Error("failed to replace index file")
+		return err
+	}
+	idx.invalid = nil
+	idx.fp, err = os.OpenFile(idx.path, os.O_APPEND|os.O_WRONLY, 0644)
+	return err
+}
+
+func (idx *Index) Close() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.fp.Close()
+}
+
+// LegacyMetadataFiles lists the per-URL <sha1(url)>.json metadata files
+// written by previous versions of FileManager.
+func LegacyMetadataFiles(directory string) ([]string, error) {
+	files, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0)
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		if len(strings.TrimSuffix(name, ".json")) != 40 {
+			continue
+		}
+		res = append(res, path.Join(directory, name))
+	}
+	return res, nil
+}
+
+// MigrateLegacyMetadata imports every per-URL metadata file in the directory
+// into the index. Entries already in the index are kept. When remove is true
+// the imported files are deleted.
+func MigrateLegacyMetadata(idx *Index, directory string, remove bool) (int, error) {
+	files, err := LegacyMetadataFiles(directory)
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, p := range files {
+		body, err := os.ReadFile(p)
+		if err != nil {
+			return migrated, err
+		}
+		var sf SavedFile
+		err = json.Unmarshal(body, &sf)
+		if err != nil || sf.SourceURL == "" || GetSha1(sf.SourceURL)+".json" != path.Base(p) {
+			log.WithFields(log.Fields{
+				"path": p,
+			}).Warn("skipping invalid metadata file")
+			continue
+		}
+		if _, found := idx.Get(sf.SourceURL); !found {
+			err = idx.Put(&sf)
+			if err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+		if remove {
+			err = os.Remove(p)
+			if err != nil {
+				return migrated, err
+			}
+		}
+	}
+	return migrated, nil
+}
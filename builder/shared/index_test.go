@@ -0,0 +1,286 @@
+package shared
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testSavedFile is an entry named after a fake content hash.
+func testSavedFile(url, hash string) *SavedFile {
+	sf := NewSavedFile(url, hash+".pdf")
+	sf.FetchDate = time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+	sf.ContentHash = hash
+	return sf
+}
+
+// indexURLs returns the source urls of the entries of the index.
+func indexURLs(idx *Index) []string {
+	urls := make([]string, 0)
+	for _, sf := range idx.All() {
+		urls = append(urls, sf.SourceURL)
+	}
+	return urls
+}
+
+func TestIndexPutDelete(t *testing.T) {
+	p := path.Join(t.TempDir(), IndexFilename)
+	idx, err := OpenIndex(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := testSavedFile("https://example.com/a.pdf", "aa")
+	b := testSavedFile("https://example.com/b.pdf", "bb")
+	for _, sf := range []*SavedFile{a, b} {
+		if err := idx.Put(sf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Delete(a.SourceURL); err != nil {
+		t.Fatal(err)
+	}
+	// deleting a missing entry writes nothing
+	if err := idx.Delete("https://example.com/c.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := idx.Get(a.SourceURL); found {
+		t.Errorf("Get() found a deleted entry")
+	}
+	got, found := idx.Get(b.SourceURL)
+	if !found || !reflect.DeepEqual(got, b) {
+		t.Errorf("Get() = %+v, %v, want %+v", got, found, b)
+	}
+	// entries are copies
+	got.DestinationFilename = "cc.pdf"
+	if again, _ := idx.Get(b.SourceURL); again.DestinationFilename != b.DestinationFilename {
+		t.Errorf("changing a returned entry changed the index")
+	}
+	if idx.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", idx.Len())
+	}
+	idx.Close()
+
+	reopened, err := OpenIndex(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got := indexURLs(reopened); !reflect.DeepEqual(got, []string{b.SourceURL}) {
+		t.Errorf("reopened index entries = %v, want %v", got, []string{b.SourceURL})
+	}
+}
+
+func TestIndexLoad(t *testing.T) {
+	a := "https://example.com/a.pdf"
+	b := "https://example.com/b.pdf"
+	record := func(url, hash string, deleted bool) string {
+		line, err := json.Marshal(&indexRecord{SavedFile: *testSavedFile(url, hash), Deleted: deleted})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(line) + "\n"
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		invalid []int
+	}{
+		{
+			"later record wins",
+			record(a, "a1", false) + record(b, "bb", false) + record(a, "a2", false),
+			map[string]string{a: "a2", b: "bb"},
+			[]int{},
+		},
+		{
+			"deleted records are skipped",
+			record(a, "a1", false) + record(b, "bb", false) + record(a, "", true),
+			map[string]string{b: "bb"},
+			[]int{},
+		},
+		{
+			"put after delete",
+			record(a, "a1", false) + record(a, "", true) + record(a, "a2", false),
+			map[string]string{a: "a2"},
+			[]int{},
+		},
+		{
+			"blank lines",
+			"\n" + record(a, "a1", false) + "  \n",
+			map[string]string{a: "a1"},
+			[]int{},
+		},
+		{
+			"truncated last line",
+			record(a, "a1", false) + record(b, "bb", false)[:20],
+			map[string]string{a: "a1"},
+			[]int{2},
+		},
+		{
+			"invalid records",
+			"no es json\n" + record(a, "a1", false) + "{}\n",
+			map[string]string{a: "a1"},
+			[]int{1, 3},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := path.Join(t.TempDir(), IndexFilename)
+			err := os.WriteFile(p, []byte(test.content), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			idx, err := OpenIndex(p)
+			if err != nil {
+				t.Fatalf("OpenIndex() error = %v", err)
+			}
+			defer idx.Close()
+			got := map[string]string{}
+			for _, sf := range idx.All() {
+				got[sf.SourceURL] = sf.ContentHash
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("entries = %v, want %v", got, test.want)
+			}
+			if got := idx.InvalidRecords(); !reflect.DeepEqual(got, test.invalid) {
+				t.Errorf("InvalidRecords() = %v, want %v", got, test.invalid)
+			}
+		})
+	}
+}
+
+func TestIndexCompact(t *testing.T) {
+	p := path.Join(t.TempDir(), IndexFilename)
+	err := os.WriteFile(p, []byte("no es json\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx, err := OpenIndex(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := testSavedFile("https://example.com/a.pdf", "a1")
+	b := testSavedFile("https://example.com/b.pdf", "bb")
+	c := testSavedFile("https://example.com/c.pdf", "cc")
+	for _, sf := range []*SavedFile{a, b, c} {
+		if err := idx.Put(sf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.ContentHash = "a2"
+	if err := idx.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Delete(c.SourceURL); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.InvalidRecords()) != 0 {
+		t.Errorf("InvalidRecords() = %v after compacting, want none", idx.InvalidRecords())
+	}
+	// the index is still writable after compacting
+	d := testSavedFile("https://example.com/d.pdf", "dd")
+	if err := idx.Put(d); err != nil {
+		t.Fatal(err)
+	}
+	idx.Close()
+
+	content, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []byte
+	for _, sf := range []*SavedFile{a, b, d} {
+		line, err := json.Marshal(&indexRecord{SavedFile: *sf})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(append(want, line...), '\n')
+	}
+	if string(content) != string(want) {
+		t.Errorf("compacted index =\n%s\nwant\n%s", content, want)
+	}
+}
+
+func TestMigrateLegacyMetadata(t *testing.T) {
+	dir := t.TempDir()
+	a := NewSavedFile("https://example.com/a.pdf", "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed.pdf")
+	a.FetchDate = time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+	b := NewSavedFile("https://example.com/b.pdf", "7d793037a0760186574b0282f2f435e7e9e0bd0e.pdf")
+	b.FetchDate = a.FetchDate
+	for _, sf := range []*SavedFile{a, b} {
+		metadata, err := json.Marshal(sf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path.Join(dir, GetSha1(sf.SourceURL)+".json"), metadata, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// not metadata files: other json and a file named after another url
+	os.WriteFile(path.Join(dir, ManifestFilename), []byte("{}"), 0644)
+	misnamed, _ := json.Marshal(a)
+	os.WriteFile(path.Join(dir, GetSha1("https://example.com/c.pdf")+".json"), misnamed, 0644)
+
+	idx, err := OpenIndex(path.Join(dir, IndexFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	// an entry already in the index is kept
+	current := testSavedFile(b.SourceURL, "bb")
+	if err := idx.Put(current); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name     string
+		remove   bool
+		migrated int
+	}{
+		{"first migration", false, 1},
+		{"migrating again", false, 0},
+		{"migrating and removing", true, 0},
+	}
+	for _, step := range steps {
+		migrated, err := MigrateLegacyMetadata(idx, dir, step.remove)
+		if err != nil {
+			t.Fatalf("%s: MigrateLegacyMetadata() error = %v", step.name, err)
+		}
+		if migrated != step.migrated {
+			t.Errorf("%s: migrated %d, want %d", step.name, migrated, step.migrated)
+		}
+		if idx.Len() != 2 {
+			t.Errorf("%s: Len() = %d, want 2", step.name, idx.Len())
+		}
+	}
+
+	got, _ := idx.Get(a.SourceURL)
+	if !reflect.DeepEqual(got, a) {
+		t.Errorf("migrated entry = %+v, want %+v", got, a)
+	}
+	if got, _ := idx.Get(b.SourceURL); !reflect.DeepEqual(got, current) {
+		t.Errorf("entry in the index = %+v, want %+v", got, current)
+	}
+	// migrated entries have no content hash and are named after it
+	if got.Hash() != "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed" {
+		t.Errorf("Hash() = %q, want the name of the content file", got.Hash())
+	}
+	if found := idx.ByContentHash(got.Hash()); len(found) != 1 || found[0].SourceURL != a.SourceURL {
+		t.Errorf("ByContentHash() = %v, want the migrated entry", found)
+	}
+	files, err := LegacyMetadataFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the invalid metadata file is left
+	if len(files) != 1 || path.Base(files[0]) != GetSha1("https://example.com/c.pdf")+".json" {
+		t.Errorf("LegacyMetadataFiles() = %v after removing, want the misnamed file", files)
+	}
+}
@@ -22,7 +22,8 @@ func ReadSecret(name string) (string, error) {
 		log.WithFields(log.Fields{
 			"error": err.Error(),
 			"name":  name,
-		}).Fatal("failed to read secret")
+		}).Error("failed to read secret")
+		return "", err
 	}
 	return strings.TrimSpace(string(body)), nil
 }
@@ -0,0 +1,224 @@
+package shared
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const ManifestFilename = "manifest.json"
+const ManifestCSVFilename = "manifest.csv"
+
+type ManifestReference struct {
+	Expediente string `json:"expediente"`
+	Actuacion  string `json:"actuacion"`
+}
+
+type ManifestEntry struct {
+	SourceURL           string              `json:"sourceURL"`
+	DestinationFilename string              `json:"destinationFilename"`
+	Size                int64               `json:"size"`
+	SHA256              string              `json:"sha256"`
+	MIMEType            string              `json:"mimeType"`
+	FetchDate           time.Time           `json:"fetchDate"`
+	References          []ManifestReference `json:"references"`
+}
+
+type Manifest struct {
+	Generated     time.Time        `json:"generated"`
+	MirrorBaseURL string           `json:"mirrorBaseURL,omitempty"`
+	Entries       []*ManifestEntry `json:"entries"`
+}
+
+func ReadManifest(p string) (*Manifest, error) {
+	fp, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	manifest := &Manifest{}
+	err = json.NewDecoder(fp).Decode(manifest)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Warn("failed to decode manifest")
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// manifestEntry builds the entry of a saved file, reading its content only
+// when the hashes were not stored on download.
+func (s *FileManager) manifestEntry(sf *SavedFile, previous *ManifestEntry) (*ManifestEntry, error) {
+	entry := &ManifestEntry{
+		SourceURL:           sf.SourceURL,
+		DestinationFilename: sf.DestinationFilename,
+		Size:                sf.Size,
+		SHA256:              sf.SHA256,
+		MIMEType:            sf.ContentType,
+		FetchDate:           sf.FetchDate,
+		References:          make([]ManifestReference, 0),
+	}
+	if entry.SHA256 != "" && entry.MIMEType != "" {
+		return entry, nil
+	}
+	if previous != nil && previous.DestinationFilename == sf.DestinationFilename && previous.SHA256 != "" {
+		entry.Size = previous.Size
+		entry.SHA256 = previous.SHA256
+		entry.MIMEType = previous.MIMEType
+		return entry, nil
+	}
+	content, err := os.ReadFile(s.DestinationPath(sf))
+	if err != nil {
+		log.WithFields(log.Fields{
+			"url":   sf.SourceURL,
+			"error": err.Error(),
+		}).Error("failed to read content file")
+		return nil, err
+	}
+	entry.Size = int64(len(content))
+	entry.SHA256 = fmt.Sprintf("%x", sha256.Sum256(content))
+	entry.MIMEType = DetectContentType(content)
+	return entry, nil
+}
+
+// BuildManifest lists every saved file with the documentos of the given
+// expedientes that refer to it. References to other expedientes are kept
+// from the previous manifest, so expedientes can be built one at a time.
+func (s *FileManager) BuildManifest(previous *Manifest, exps []*Expediente) (*Manifest, error) {
+	idx, err := s.Index()
+	if err != nil {
+		return nil, err
+	}
+	rebuilt := map[string]bool{}
+	references := map[string][]ManifestReference{}
+	for _, exp := range exps {
+		if exp.Ficha != nil {
+			rebuilt[exp.NumeroDeExpediente("/")] = true
+		}
+		for _, doc := range exp.Documentos() {
+			rebuilt[doc.NumeroDeExpediente] = true
+			references[doc.URL] = append(references[doc.URL], ManifestReference{
+				Expediente: doc.NumeroDeExpediente,
+				Actuacion:  doc.ActuacionID,
+			})
+		}
+	}
+	previousEntries := map[string]*ManifestEntry{}
+	if previous != nil {
+		for _, entry := range previous.Entries {
+			previousEntries[entry.SourceURL] = entry
+		}
+	}
+
+	manifest := &Manifest{
+		Generated:     time.Now(),
+		MirrorBaseURL: s.MirrorBaseURL,
+		Entries:       make([]*ManifestEntry, 0, idx.Len()),
+	}
+	for _, sf := range idx.All() {
+		prev := previousEntries[sf.SourceURL]
+		entry, err := s.manifestEntry(sf, prev)
+		if err != nil {
+			continue
+		}
+		if prev != nil {
+			for _, ref := range prev.References {
+				if !rebuilt[ref.Expediente] {
+					entry.References = append(entry.References, ref)
+				}
+			}
+		}
+		entry.References = append(entry.References, references[sf.SourceURL]...)
+		sort.Slice(entry.References, func(i, j int) bool {
+			a, b := entry.References[i], entry.References[j]
+			if a.Expediente != b.Expediente {
+				return a.Expediente < b.Expediente
+			}
+			return a.Actuacion < b.Actuacion
+		})
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+	return manifest, nil
+}
+
+func (manifest *Manifest) writeJSON(p string) error {
+	fp, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	encoder := json.NewEncoder(fp)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(manifest)
+}
+
+// writeCSV writes a row per reference, and a row without references for the
+// files no documento refers to.
+func (manifest *Manifest) writeCSV(p string) error {
+	fp, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	w := csv.NewWriter(fp)
+	w.Write([]string{"sourceURL", "destinationFilename", "size", "sha256", "mimeType", "fetchDate", "expediente", "actuacion"})
+	for _, entry := range manifest.Entries {
+		row := []string{
+			entry.SourceURL,
+			entry.DestinationFilename,
+			strconv.FormatInt(entry.Size, 10),
+			entry.SHA256,
+			entry.MIMEType,
+			entry.FetchDate.UTC().Format(time.RFC3339),
+		}
+		if len(entry.References) == 0 {
+			w.Write(append(row, "", ""))
+		}
+		for _, ref := range entry.References {
+			w.Write(append(row, ref.Expediente, ref.Actuacion))
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// WriteManifest updates the manifest.json and manifest.csv of the directory
+// with the given expedientes.
+func (s *FileManager) WriteManifest(exps []*Expediente) error {
+	jsonPath := path.Join(s.Directory, ManifestFilename)
+	previous, _ := ReadManifest(jsonPath)
+	manifest, err := s.BuildManifest(previous, exps)
+	if err != nil {
+		return err
+	}
+	return manifest.Write(jsonPath, path.Join(s.Directory, ManifestCSVFilename))
+}
+
+func (manifest *Manifest) Write(jsonPath, csvPath string) error {
+	err := manifest.writeJSON(jsonPath)
+	if err == nil {
+		err = manifest.writeCSV(csvPath)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  jsonPath,
+			"error": err.Error(),
+		}).Error("failed to write manifest")
+		return err
+	}
+	log.WithFields(log.Fields{
+		"path":    jsonPath,
+		"entries": len(manifest.Entries),
+	}).Info("wrote manifest")
+	return nil
+}
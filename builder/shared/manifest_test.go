@@ -0,0 +1,155 @@
+package shared
+
+import (
+	"encoding/csv"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func testExpediente(numero int, docs map[string][]string) *Expediente {
+	exp := &Expediente{Ficha: &Ficha{Numero: numero, Anio: 2020}}
+	for actuacion, urls := range docs {
+		act := &Actuacion{}
+		for _, url := range urls {
+			act.Documentos = append(act.Documentos, &Documento{
+				URL:                url,
+				ActuacionID:        actuacion,
+				NumeroDeExpediente: exp.NumeroDeExpediente("/"),
+			})
+		}
+		exp.Actuaciones = append(exp.Actuaciones, act)
+	}
+	return exp
+}
+
+// manifestReferences returns the references of every entry by source url.
+func manifestReferences(t *testing.T, fm *FileManager) map[string][]ManifestReference {
+	t.Helper()
+	manifest, err := ReadManifest(path.Join(fm.Directory, ManifestFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	references := map[string][]ManifestReference{}
+	for _, entry := range manifest.Entries {
+		references[entry.SourceURL] = entry.References
+	}
+	return references
+}
+
+func TestWriteManifest(t *testing.T) {
+	fm := &FileManager{Directory: t.TempDir()}
+	defer fm.Close()
+	a := "https://example.com/a.pdf"
+	b := "https://example.com/b.pdf"
+	saveTestFile(t, fm, a, testPDF("a"))
+	saveTestFile(t, fm, b, testPDF("b"))
+	child := &Documento{URL: ChildURL(a, "anexo.pdf"), ActuacionID: "10", NumeroDeExpediente: "1/2020", Parent: a}
+	saveTestFile(t, fm, child.URL, testPDF("anexo"))
+
+	first := testExpediente(1, map[string][]string{"10": {a}})
+	first.Actuaciones[0].Documentos[0].Children = []*Documento{child}
+	second := testExpediente(2, map[string][]string{"20": {a, b}})
+	firstAgain := testExpediente(1, map[string][]string{})
+
+	steps := []struct {
+		name string
+		exps []*Expediente
+		want map[string][]ManifestReference
+	}{
+		{
+			"first expediente",
+			[]*Expediente{first},
+			map[string][]ManifestReference{
+				a:         {{"1/2020", "10"}},
+				b:         {},
+				child.URL: {{"1/2020", "10"}},
+			},
+		},
+		{
+			"second expediente keeps the references of the first",
+			[]*Expediente{second},
+			map[string][]ManifestReference{
+				a:         {{"1/2020", "10"}, {"2/2020", "20"}},
+				b:         {{"2/2020", "20"}},
+				child.URL: {{"1/2020", "10"}},
+			},
+		},
+		{
+			"first expediente rebuilt without documentos",
+			[]*Expediente{firstAgain},
+			map[string][]ManifestReference{
+				a:         {{"2/2020", "20"}},
+				b:         {{"2/2020", "20"}},
+				child.URL: {},
+			},
+		},
+	}
+	for _, step := range steps {
+		err := fm.WriteManifest(step.exps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := manifestReferences(t, fm); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: references = %v, want %v", step.name, got, step.want)
+		}
+	}
+
+	fp, err := os.Open(path.Join(fm.Directory, ManifestCSVFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fp.Close()
+	rows, err := csv.NewReader(fp).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the header, a row per reference of a and b and one for the child
+	if len(rows) != 4 {
+		t.Errorf("manifest.csv has %d rows, want 4: %v", len(rows), rows)
+	}
+}
+
+// TestManifestLegacyEntry checks that entries saved without their hashes
+// get them from the content, or from the previous manifest when the content
+// is not there.
+func TestManifestLegacyEntry(t *testing.T) {
+	fm := &FileManager{Directory: t.TempDir()}
+	defer fm.Close()
+	content := testPDF("legacy")
+	sf := saveTestFile(t, fm, "https://example.com/legacy.pdf", content)
+	want := *sf
+	idx, err := fm.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := NewSavedFile(sf.SourceURL, sf.DestinationFilename)
+	err = idx.Put(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, step := range []string{"from the content", "from the previous manifest"} {
+		manifest, err := ReadManifest(path.Join(fm.Directory, ManifestFilename))
+		if err != nil {
+			manifest = nil
+		}
+		built, err := fm.BuildManifest(manifest, []*Expediente{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(built.Entries) != 1 {
+			t.Fatalf("%s: %d entries, want 1", step, len(built.Entries))
+		}
+		entry := built.Entries[0]
+		if entry.SHA256 != want.SHA256 || entry.Size != want.Size || entry.MIMEType != want.ContentType {
+			t.Errorf("%s: entry = %+v, want the hashes of %+v", step, entry, want)
+		}
+		err = built.Write(path.Join(fm.Directory, ManifestFilename), path.Join(fm.Directory, ManifestCSVFilename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(fm.DestinationPath(sf))
+	}
+}
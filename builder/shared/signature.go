@@ -0,0 +1,92 @@
+package shared
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Signature is a digital signature embedded in a pdf.
+type Signature struct {
+	Field       string     `json:"field,omitempty"`
+	Signer      string     `json:"signer"`
+	Issuer      string     `json:"issuer,omitempty"`
+	SigningTime *time.Time `json:"signingTime,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+	Location    string     `json:"location,omitempty"`
+	SubFilter   string     `json:"subFilter,omitempty"`
+	// CoversWholeDocument is false when anything, even a later signature,
+	// was appended after signing.
+	CoversWholeDocument bool `json:"coversWholeDocument"`
+	// ModifiedAfterSigning is true when the document was changed after this
+	// signature by an update that no later signature covers.
+	ModifiedAfterSigning bool `json:"modifiedAfterSigning"`
+	// SignedAgain is true when the document was changed after this
+	// signature, but a later signature covers the whole file, as when a
+	// judge and a secretary co-sign an actuacion.
+	SignedAgain bool `json:"signedAgain,omitempty"`
+	// Valid is true when the signature matches the signed bytes and the
+	// certificate. Trust in the certificate issuer is not checked.
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+	// Firmante is true when the signer is listed in the firmantes of the
+	// actuacion.
+	Firmante bool `json:"firmante"`
+}
+
+// nameWords returns the upper case words of a name, without accents, numbers
+// or initials.
+func nameWords(s string) []string {
+	replacer := strings.NewReplacer("Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U", "Ü", "U")
+	s = replacer.Replace(strings.ToUpper(s))
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	names := make([]string, 0, len(words))
+	for _, w := range words {
+		if len([]rune(w)) > 1 {
+			names = append(names, w)
+		}
+	}
+	return names
+}
+
+// MatchesFirmantes tells if a signer is named in the firmantes of an
+// actuacion: two of its words, or its only word, must be there.
+func MatchesFirmantes(signer, firmantes string) bool {
+	listed := map[string]bool{}
+	for _, w := range nameWords(firmantes) {
+		listed[w] = true
+	}
+	words := nameWords(signer)
+	found := 0
+	for _, w := range words {
+		if listed[w] {
+			found++
+		}
+	}
+	return found > 0 && (found >= 2 || found == len(words))
+}
+
+// SignedByFirmantes tells if the documento is the pdf of the actuacion
+// itself, the one its firmantes sign. Adjuntos, cedulas and the files
+// contained in other documentos are signed by others, or not at all.
+func (d *Documento) SignedByFirmantes() bool {
+	return d.Type == RegularAttachment && d.Parent == ""
+}
+
+// CheckFirmantes marks the signatures of the documentos of an actuacion made
+// by its firmantes, and returns the signers that are not listed.
+func (actuacion *Actuacion) CheckFirmantes() []string {
+	unknown := make([]string, 0)
+	for _, doc := range actuacion.Documentos {
+		for i := range doc.Signatures {
+			sig := &doc.Signatures[i]
+			sig.Firmante = MatchesFirmantes(sig.Signer, actuacion.Firmantes)
+			if !sig.Firmante {
+				unknown = append(unknown, sig.Signer)
+			}
+		}
+	}
+	return unknown
+}
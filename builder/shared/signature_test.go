@@ -0,0 +1,24 @@
+package shared
+
+import "testing"
+
+func TestMatchesFirmantes(t *testing.T) {
+	firmantes := "PÉREZ, Juan Carlos (Juez); GOMEZ Maria (Secretaria)"
+	tests := []struct {
+		signer string
+		want   bool
+	}{
+		{"Juan Carlos Perez", true},
+		{"PEREZ JUAN C.", true},
+		{"Maria Gomez", true},
+		{"Gomez", true},
+		{"Juan Rodriguez", false},
+		{"Pedro Perez Lopez", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := MatchesFirmantes(test.signer, firmantes); got != test.want {
+			t.Errorf("MatchesFirmantes(%q) = %v, want %v", test.signer, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package shared
+
+import (
+	"bytes"
+	"encoding/csv"
+)
+
+// TablesDirectory holds the tables of the documents as csv files.
+const TablesDirectory = "tables"
+
+// Table is a table found in a page of a document.
+type Table struct {
+	Page int        `json:"page"`
+	Rows [][]string `json:"rows"`
+	// CSVURL points to the table saved as csv.
+	CSVURL string `json:"csvURL,omitempty"`
+}
+
+// CSV encodes the rows of the table.
+func (t *Table) CSV() []byte {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.WriteAll(t.Rows)
+	return b.Bytes()
+}
@@ -0,0 +1,168 @@
+package shared
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+)
+
+const InvalidMetadataProblem = "invalid-metadata"
+const MissingFileProblem = "missing-file"
+const HashMismatchProblem = "hash-mismatch"
+const InvalidDocumentProblem = "invalid-document"
+const OrphanFileProblem = "orphan-file"
+
+type VerifyProblem struct {
+	Problem             string `json:"problem"`
+	SourceURL           string `json:"sourceURL,omitempty"`
+	DestinationFilename string `json:"destinationFilename,omitempty"`
+	Detail              string `json:"detail,omitempty"`
+	Repaired            bool   `json:"repaired"`
+}
+
+type VerifyReport struct {
+	Directory string           `json:"directory"`
+	Checked   int              `json:"checked"`
+	Problems  []*VerifyProblem `json:"problems"`
+}
+
+// ValidateDocument checks that the content is a complete document of the
+// type its filename says.
+func ValidateDocument(filename string, content []byte) error {
+	contentType := DetectContentType(content)
+	switch path.Ext(filename) {
+	case ".pdf":
+		if contentType != PDFContentType {
+			return fmt.Errorf("expected a pdf, found %s", contentType)
+		}
+		tail := content
+		if len(tail) > 1024 {
+			tail = tail[len(tail)-1024:]
+		}
+		if !bytes.Contains(tail, []byte("%%EOF")) {
+			return fmt.Errorf("truncated pdf, missing %%%%EOF marker")
+		}
+	}
+	return nil
+}
+
+// VerifySavedFile checks that the destination file of a saved file exists,
+// that its content matches its hash and that it is a valid document.
+func (s *FileManager) VerifySavedFile(sf *SavedFile) *VerifyProblem {
+	if sf.DestinationFilename == "" {
+		return &VerifyProblem{
+			Problem:   InvalidMetadataProblem,
+			SourceURL: sf.SourceURL,
+			Detail:    InvalidSavedFile.Error(),
+		}
+	}
+	content, err := os.ReadFile(s.DestinationPath(sf))
+	if err != nil {
+		return &VerifyProblem{
+			Problem:             MissingFileProblem,
+			SourceURL:           sf.SourceURL,
+			DestinationFilename: sf.DestinationFilename,
+			Detail:              err.Error(),
+		}
+	}
+	hash := fmt.Sprintf("%x", sha1.Sum(content))
+	if hash != sf.Hash() {
+		return &VerifyProblem{
+			Problem:             HashMismatchProblem,
+			SourceURL:           sf.SourceURL,
+			DestinationFilename: sf.DestinationFilename,
+			Detail:              fmt.Sprintf("content hash is %s", hash),
+		}
+	}
+	err = ValidateDocument(sf.DestinationFilename, content)
+	if err != nil {
+		return &VerifyProblem{
+			Problem:             InvalidDocumentProblem,
+			SourceURL:           sf.SourceURL,
+			DestinationFilename: sf.DestinationFilename,
+			Detail:              err.Error(),
+		}
+	}
+	return nil
+}
+
+// contentFilenameRegexp matches the names of saved content files,
+// <sha1>.<ext>.
+var contentFilenameRegexp = regexp.MustCompile(`^[0-9a-f]{40}\.[0-9a-z]+$`)
+
+// isContentFile tells whether a file in the directory is named like saved
+// content. Anything else, like the index, the manifest or the README and
+// CNAME of the published repository, is not part of the store's content.
+func isContentFile(name string) bool {
+	return contentFilenameRegexp.MatchString(name)
+}
+
+// OrphanFiles returns the content files in the directory that no saved file
+// refers to.
+func (s *FileManager) OrphanFiles() ([]string, error) {
+	idx, err := s.Index()
+	if err != nil {
+		return nil, err
+	}
+	referenced := map[string]bool{}
+	for _, sf := range idx.All() {
+		referenced[sf.DestinationFilename] = true
+	}
+	legacy, err := LegacyMetadataFiles(s.Directory)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range legacy {
+		referenced[path.Base(p)] = true
+	}
+	files, err := os.ReadDir(s.Directory)
+	if err != nil {
+		return nil, err
+	}
+	orphans := make([]string, 0)
+	for _, file := range files {
+		if file.IsDir() || !isContentFile(file.Name()) || referenced[file.Name()] {
+			continue
+		}
+		orphans = append(orphans, file.Name())
+	}
+	return orphans, nil
+}
+
+// Verify checks every saved file and looks for orphaned content files.
+func (s *FileManager) Verify() (*VerifyReport, error) {
+	idx, err := s.Index()
+	if err != nil {
+		return nil, err
+	}
+	report := &VerifyReport{
+		Directory: s.Directory,
+		Problems:  make([]*VerifyProblem, 0),
+	}
+	for _, line := range idx.InvalidRecords() {
+		report.Problems = append(report.Problems, &VerifyProblem{
+			Problem: InvalidMetadataProblem,
+			Detail:  fmt.Sprintf("%s: %s line %d", InvalidSavedFile.Error(), IndexFilename, line),
+		})
+	}
+	for _, sf := range idx.All() {
+		report.Checked++
+		if problem := s.VerifySavedFile(sf); problem != nil {
+			report.Problems = append(report.Problems, problem)
+		}
+	}
+	orphans, err := s.OrphanFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range orphans {
+		report.Problems = append(report.Problems, &VerifyProblem{
+			Problem:             OrphanFileProblem,
+			DestinationFilename: name,
+		})
+	}
+	return report, nil
+}
@@ -0,0 +1,170 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// saveTestFile saves content for url the way the fetcher does.
+func saveTestFile(t *testing.T, fm *FileManager, url string, content []byte) *SavedFile {
+	t.Helper()
+	sf := NewSavedFile(url, "")
+	sf.SetContent(content)
+	sf.DestinationFilename = sf.ContentHash + ContentTypeExtension(sf.ContentType)
+	err := fm.SaveSavedFile(sf, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sf
+}
+
+func testPDF(text string) []byte {
+	return []byte(fmt.Sprintf("%%PDF-1.4\n%% %s\n%%%%EOF\n", text))
+}
+
+func writeTestFile(t *testing.T, fm *FileManager, name string, content []byte) {
+	t.Helper()
+	err := os.WriteFile(path.Join(fm.Directory, name), content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// publishedRepoFiles are in the checkout of the published repository, they
+// are never content.
+var publishedRepoFiles = []string{"README.md", "CNAME", "LICENSE", "index.html", ".nojekyll"}
+
+func newTestStore(t *testing.T) (*FileManager, *SavedFile, *SavedFile, string) {
+	fm := &FileManager{Directory: t.TempDir()}
+	t.Cleanup(func() { fm.Close() })
+	kept := saveTestFile(t, fm, "https://example.com/kept.pdf", testPDF("kept"))
+	dropped := saveTestFile(t, fm, "https://example.com/dropped.pdf", testPDF("dropped"))
+	orphan := GetSha1("orphan") + ".pdf"
+	writeTestFile(t, fm, orphan, testPDF("orphan"))
+	for _, name := range publishedRepoFiles {
+		writeTestFile(t, fm, name, []byte("published"))
+	}
+	err := fm.WriteManifest([]*Expediente{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fm, kept, dropped, orphan
+}
+
+func TestOrphanFiles(t *testing.T) {
+	fm, _, _, orphan := newTestStore(t)
+	orphans, err := fm.OrphanFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{orphan}; !reflect.DeepEqual(orphans, want) {
+		t.Errorf("OrphanFiles() = %v, want %v", orphans, want)
+	}
+}
+
+func TestIsContentFile(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{GetSha1("a") + ".pdf", true},
+		{GetSha1("a") + ".bin", true},
+		{GetSha1("a"), false},
+		{"README.md", false},
+		{"CNAME", false},
+		{IndexFilename, false},
+		{ManifestFilename, false},
+		{ManifestCSVFilename, false},
+		{"0123456789abcdef.pdf", false},
+	}
+	for _, test := range tests {
+		if got := isContentFile(test.name); got != test.want {
+			t.Errorf("isContentFile(%q) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	fm, kept, dropped, orphan := newTestStore(t)
+	writeTestFile(t, fm, dropped.DestinationFilename, []byte("<html>error</html>"))
+	err := os.Remove(fm.DestinationPath(kept))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := fm.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, problem := range report.Problems {
+		got[problem.DestinationFilename] = problem.Problem
+	}
+	want := map[string]string{
+		kept.DestinationFilename:    MissingFileProblem,
+		dropped.DestinationFilename: HashMismatchProblem,
+		orphan:                      OrphanFileProblem,
+	}
+	if report.Checked != 2 || !reflect.DeepEqual(got, want) {
+		t.Errorf("Verify() checked %d, problems %v, want 2, %v", report.Checked, got, want)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	fm, kept, dropped, orphan := newTestStore(t)
+	referenced := map[string]bool{kept.SourceURL: true}
+
+	report, err := fm.CollectGarbage(referenced, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.RemovedFiles != 2 {
+		t.Errorf("dry run RemovedFiles = %d, want 2", report.RemovedFiles)
+	}
+	if _, err := os.Stat(path.Join(fm.Directory, orphan)); err != nil {
+		t.Errorf("dry run removed %s", orphan)
+	}
+
+	report, err = fm.CollectGarbage(referenced, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed := make([]string, 0)
+	for _, f := range report.Removed {
+		removed = append(removed, f.DestinationFilename)
+	}
+	sort.Strings(removed)
+	want := []string{dropped.DestinationFilename, orphan}
+	sort.Strings(want)
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed %v, want %v", removed, want)
+	}
+	files, err := os.ReadDir(fm.Directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	left := map[string]bool{}
+	for _, file := range files {
+		left[file.Name()] = true
+	}
+	for _, name := range append(publishedRepoFiles, kept.DestinationFilename, IndexFilename, ManifestFilename, ManifestCSVFilename) {
+		if !left[name] {
+			t.Errorf("%s was removed", name)
+		}
+	}
+	for _, name := range want {
+		if left[name] {
+			t.Errorf("%s was not removed", name)
+		}
+	}
+	idx, err := fm.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := idx.Get(dropped.SourceURL); found {
+		t.Errorf("%s is still in the index", dropped.SourceURL)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	shared "github.com/odia/juscaba/shared"
+	log "github.com/sirupsen/logrus"
+)
+
+const invalidSignatureReason = "invalid-signature"
+const modifiedAfterSigningReason = "modified-after-signing"
+const unknownSignerReason = "signer-not-in-firmantes"
+const unsignedReason = "unsigned"
+
+// SignatureDocument lists the signatures of a documento and what is wrong
+// with them.
+type SignatureDocument struct {
+	URL                string             `json:"url"`
+	Nombre             string             `json:"nombre"`
+	ActuacionID        string             `json:"actuacionId"`
+	NumeroDeExpediente string             `json:"numeroDeExpediente"`
+	Firmantes          string             `json:"firmantes"`
+	Signatures         []shared.Signature `json:"signatures"`
+	Problems           []string           `json:"problems"`
+}
+
+type SignatureReport struct {
+	Checked   int                  `json:"checked"`
+	Signed    int                  `json:"signed"`
+	Flagged   int                  `json:"flagged"`
+	Documents []*SignatureDocument `json:"documents"`
+}
+
+func signatureProblems(act *shared.Actuacion, doc *shared.Documento) []string {
+	problems := make([]string, 0)
+	if len(doc.Signatures) == 0 {
+		if act.Firmantes != "" && doc.SignedByFirmantes() {
+			problems = append(problems, unsignedReason)
+		}
+		return problems
+	}
+	addProblem := func(problem string) {
+		for _, p := range problems {
+			if p == problem {
+				return
+			}
+		}
+		problems = append(problems, problem)
+	}
+	for _, sig := range doc.Signatures {
+		if !sig.Valid {
+			addProblem(invalidSignatureReason)
+		}
+		if sig.ModifiedAfterSigning {
+			addProblem(modifiedAfterSigningReason)
+		}
+		if !shared.MatchesFirmantes(sig.Signer, act.Firmantes) {
+			addProblem(unknownSignerReason)
+		}
+	}
+	return problems
+}
+
+func signatures(argv []string) error {
+	var reportPath string
+	var all bool
+	flags := newFlagSet("signatures")
+	flags.StringVar(&reportPath, "report", "", "json report destination path (default stdout)")
+	flags.BoolVar(&all, "all", false, "list every documento, not only those with problems")
+	flags.Parse(argv)
+
+	report := &SignatureReport{Documents: make([]*SignatureDocument, 0)}
+	for _, p := range flags.Args() {
+		exp, err := shared.ReadExpediente(p)
+		if err != nil {
+			return err
+		}
+		for _, act := range exp.Actuaciones {
+			for _, doc := range act.Documentos {
+				report.Checked++
+				if len(doc.Signatures) > 0 {
+					report.Signed++
+				}
+				problems := signatureProblems(act, doc)
+				if len(problems) > 0 {
+					report.Flagged++
+				} else if !all {
+					continue
+				}
+				report.Documents = append(report.Documents, &SignatureDocument{
+					URL:                doc.URL,
+					Nombre:             doc.Nombre,
+					ActuacionID:        doc.ActuacionID,
+					NumeroDeExpediente: doc.NumeroDeExpediente,
+					Firmantes:          act.Firmantes,
+					Signatures:         doc.Signatures,
+					Problems:           problems,
+				})
+			}
+		}
+	}
+
+	out := os.Stdout
+	if reportPath != "" {
+		var err error
+		out, err = os.Create(reportPath)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"report": reportPath,
+				"error":  err.Error(),
+			}).Error("failed to create report")
+			return err
+		}
+		defer out.Close()
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(report)
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"checked": report.Checked,
+		"signed":  report.Signed,
+		"flagged": report.Flagged,
+	}).Info("checked signatures")
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	shared "github.com/odia/juscaba/shared"
+)
+
+func TestSignatureProblems(t *testing.T) {
+	act := &shared.Actuacion{Firmantes: "PEREZ, Juan Carlos (Juez)"}
+	valid := shared.Signature{Signer: "Juan Carlos Perez", Valid: true}
+	tests := []struct {
+		name string
+		doc  *shared.Documento
+		want []string
+	}{
+		{
+			"signed by a firmante",
+			&shared.Documento{Type: shared.RegularAttachment, Signatures: []shared.Signature{valid}},
+			[]string{},
+		},
+		{
+			"unsigned actuacion",
+			&shared.Documento{Type: shared.RegularAttachment},
+			[]string{unsignedReason},
+		},
+		{
+			"unsigned adjunto",
+			&shared.Documento{Type: shared.AdjuntosAttachment},
+			[]string{},
+		},
+		{
+			"unsigned cedula",
+			&shared.Documento{Type: shared.CedulaAttachment},
+			[]string{},
+		},
+		{
+			"unsigned child",
+			&shared.Documento{Type: shared.RegularAttachment, Parent: "https://example.com/a.pdf"},
+			[]string{},
+		},
+		{
+			"co-signed",
+			&shared.Documento{Type: shared.RegularAttachment, Signatures: []shared.Signature{
+				{Signer: "Juan Carlos Perez", Valid: true, SignedAgain: true},
+				valid,
+			}},
+			[]string{},
+		},
+		{
+			"modified by someone else",
+			&shared.Documento{Type: shared.RegularAttachment, Signatures: []shared.Signature{
+				{Signer: "Maria Gomez", ModifiedAfterSigning: true},
+			}},
+			[]string{invalidSignatureReason, modifiedAfterSigningReason, unknownSignerReason},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := signatureProblems(act, test.doc)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("signatureProblems() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	shared "github.com/odia/juscaba/shared"
+	snapshot "github.com/odia/juscaba/snapshot"
+	log "github.com/sirupsen/logrus"
+)
+
+func snapshotLeaves(fm *shared.FileManager, exp *shared.Expediente, jsonPath string) ([]snapshot.Leaf, error) {
+	leaves := make([]snapshot.Leaf, 0)
+	seen := map[string]bool{}
+	for _, doc := range exp.Documentos() {
+		if seen[doc.URL] {
+			continue
+		}
+		seen[doc.URL] = true
+		sf, err := fm.SavedFileForURL(doc.URL)
+		if err != nil {
+			continue
+		}
+		hash, err := fm.ContentSHA256(sf)
+		if err != nil {
+			continue
+		}
+		leaves = append(leaves, snapshot.Leaf{
+			Kind:   snapshot.DocumentLeaf,
+			Name:   doc.URL,
+			SHA256: hash,
+		})
+	}
+	content, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return nil, err
+	}
+	leaves = append(leaves, snapshot.Leaf{
+		Kind:   snapshot.JSONLeaf,
+		Name:   path.Base(jsonPath),
+		SHA256: fmt.Sprintf("%x", sha256.Sum256(content)),
+	})
+	return leaves, nil
+}
+
+// readSnapshotKey reads the signing key from the secret with the given name.
+func readSnapshotKey(secret string) (ed25519.PrivateKey, error) {
+	seed, err := shared.ReadSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	key, err := snapshot.ParsePrivateKey(seed)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"secret": secret,
+			"error":  err.Error(),
+		}).Error("failed to parse snapshot key")
+		return nil, err
+	}
+	return key, nil
+}
+
+func writeSnapshot(args *arguments) error {
+	leaves, err := snapshotLeaves(args.fm, args.exp, args.jsonPath)
+	if err != nil {
+		return err
+	}
+	s := snapshot.New(leaves, args.snapshotKey)
+	err = snapshot.Write(args.snapshotPath, s)
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"path":      args.snapshotPath,
+		"leaves":    s.TreeSize,
+		"root":      s.Root,
+		"publicKey": s.PublicKey,
+	}).Info("wrote signed snapshot")
+	return nil
+}
+
+func verifySnapshot(argv []string) error {
+	var snapshotPath, proofPath, proofOut, filePath, kind, name, publicKey string
+	flags := newFlagSet("verify-snapshot")
+	flags.StringVar(&snapshotPath, "snapshot", "", "snapshot path")
+	flags.StringVar(&proofPath, "proof", "", "inclusion proof path, to verify without the snapshot")
+	flags.StringVar(&proofOut, "proof-out", "", "write the inclusion proof of the file to this path")
+	flags.StringVar(&filePath, "file", "", "document or json to verify")
+	flags.StringVar(&kind, "kind", snapshot.DocumentLeaf, "kind of file (document or json)")
+	flags.StringVar(&name, "url", "", "source url of the document (defaults to the filename for json)")
+	flags.StringVar(&publicKey, "public-key", "", "hex ed25519 public key of the signer, as published by the mirror (required)")
+	flags.Parse(argv)
+
+	if publicKey == "" {
+		log.Error("-public-key is required, the key embedded in the snapshot proves nothing")
+		return snapshot.NoPublicKey
+	}
+
+	var content []byte
+	if filePath != "" {
+		var err error
+		content, err = os.ReadFile(filePath)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"file":  filePath,
+				"error": err.Error(),
+			}).Error("failed to read file")
+			return err
+		}
+		if name == "" && kind == snapshot.JSONLeaf {
+			name = path.Base(filePath)
+		}
+	}
+
+	proof := &snapshot.Proof{}
+	switch {
+	case proofPath != "":
+		err := snapshot.Read(proofPath, proof)
+		if err != nil {
+			return err
+		}
+	case snapshotPath != "":
+		s := &snapshot.Snapshot{}
+		err := snapshot.Read(snapshotPath, s)
+		if err != nil {
+			return err
+		}
+		err = s.Verify(publicKey)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"snapshot": snapshotPath,
+				"error":    err.Error(),
+			}).Error("invalid snapshot")
+			return err
+		}
+		if name == "" {
+			log.WithFields(log.Fields{
+				"snapshot": snapshotPath,
+				"root":     s.Root,
+				"leaves":   s.TreeSize,
+			}).Info("snapshot is valid")
+			return nil
+		}
+		proof, err = s.Proof(kind, name)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"kind": kind,
+				"name": name,
+			}).Error("file is not part of the snapshot")
+			return err
+		}
+	default:
+		log.Error("either -snapshot or -proof is required")
+		return errors.New("no snapshot or proof")
+	}
+
+	err := proof.Verify(publicKey, content)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"name":  proof.Leaf.Name,
+			"error": err.Error(),
+		}).Error("inclusion proof failed")
+		return err
+	}
+	log.WithFields(log.Fields{
+		"name":      proof.Leaf.Name,
+		"sha256":    proof.Leaf.SHA256,
+		"created":   proof.Created,
+		"root":      proof.Root,
+		"publicKey": proof.PublicKey,
+	}).Info("inclusion proof is valid")
+	if proofOut != "" {
+		return snapshot.Write(proofOut, proof)
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package snapshot
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const DocumentLeaf = "document"
+const JSONLeaf = "json"
+
+var InvalidSignature = errors.New("snapshot signature is invalid")
+var InvalidProof = errors.New("inclusion proof does not match the snapshot root")
+var LeafNotFound = errors.New("leaf is not part of the snapshot")
+var NoPublicKey = errors.New("the public key of the signer is required")
+
+// Leaf is a document or json output included in a snapshot. Documents are
+// named after their source url and json outputs after their filename.
+type Leaf struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// SignedRoot is the root of the Merkle tree of a crawl, signed with the
+// builder's Ed25519 key.
+type SignedRoot struct {
+	Created   time.Time `json:"created"`
+	TreeSize  int       `json:"treeSize"`
+	Root      string    `json:"root"`
+	PublicKey string    `json:"publicKey"`
+	Signature string    `json:"signature"`
+}
+
+type Snapshot struct {
+	SignedRoot
+	Leaves []Leaf `json:"leaves"`
+}
+
+// Proof lets a third party check that a single leaf is part of a signed
+// snapshot without the rest of the leaves.
+type Proof struct {
+	SignedRoot
+	Leaf  Leaf     `json:"leaf"`
+	Index int      `json:"index"`
+	Path  []string `json:"path"`
+}
+
+// Hashes follow RFC 6962 so leaves and inner nodes can not be confused.
+func hashLeaf(leaf Leaf) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	fmt.Fprintf(h, "%s\x00%s\x00%s", leaf.Kind, leaf.Name, leaf.SHA256)
+	return h.Sum(nil)
+}
+
+func hashChildren(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// split returns the largest power of two smaller than n.
+func split(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+func merkleRoot(hashes [][]byte) []byte {
+	switch len(hashes) {
+	case 0:
+		h := sha256.Sum256(nil)
+		return h[:]
+	case 1:
+		return hashes[0]
+	}
+	k := split(len(hashes))
+	return hashChildren(merkleRoot(hashes[:k]), merkleRoot(hashes[k:]))
+}
+
+func auditPath(m int, hashes [][]byte) [][]byte {
+	if len(hashes) <= 1 {
+		return [][]byte{}
+	}
+	k := split(len(hashes))
+	if m < k {
+		return append(auditPath(m, hashes[:k]), merkleRoot(hashes[k:]))
+	}
+	return append(auditPath(m-k, hashes[k:]), merkleRoot(hashes[:k]))
+}
+
+// rootFromPath recomputes the root of a tree of the given size from a leaf
+// hash and its audit path.
+func rootFromPath(m, size int, leaf []byte, path [][]byte) ([]byte, error) {
+	if size <= 1 {
+		if len(path) != 0 {
+			return nil, InvalidProof
+		}
+		return leaf, nil
+	}
+	if len(path) == 0 {
+		return nil, InvalidProof
+	}
+	sibling := path[len(path)-1]
+	rest := path[:len(path)-1]
+	k := split(size)
+	if m < k {
+		left, err := rootFromPath(m, k, leaf, rest)
+		if err != nil {
+			return nil, err
+		}
+		return hashChildren(left, sibling), nil
+	}
+	right, err := rootFromPath(m-k, size-k, leaf, rest)
+	if err != nil {
+		return nil, err
+	}
+	return hashChildren(sibling, right), nil
+}
+
+func (sr *SignedRoot) message() []byte {
+	return []byte(fmt.Sprintf("juscaba-snapshot-v1\n%s\n%d\n%s\n",
+		sr.Created.UTC().Format(time.RFC3339Nano),
+		sr.TreeSize,
+		sr.Root,
+	))
+}
+
+func (sr *SignedRoot) sign(key ed25519.PrivateKey) {
+	sr.PublicKey = hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	sr.Signature = hex.EncodeToString(ed25519.Sign(key, sr.message()))
+}
+
+// Verify checks that the root is signed by publicKey. The key embedded in
+// the root is not trusted on its own, since anyone can re-sign a modified
+// snapshot with their own key.
+func (sr *SignedRoot) Verify(publicKey string) error {
+	if publicKey == "" {
+		return NoPublicKey
+	}
+	if !strings.EqualFold(publicKey, sr.PublicKey) {
+		return fmt.Errorf("snapshot signed by %s, expected %s", sr.PublicKey, publicKey)
+	}
+	key, err := hex.DecodeString(sr.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return InvalidSignature
+	}
+	signature, err := hex.DecodeString(sr.Signature)
+	if err != nil {
+		return InvalidSignature
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), sr.message(), signature) {
+		return InvalidSignature
+	}
+	return nil
+}
+
+// ParsePrivateKey decodes an Ed25519 seed or private key in hex or base64.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	s = strings.TrimSpace(s)
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		key, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return nil, errors.New("private key is neither hex nor base64")
+	}
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key), nil
+	}
+	return nil, fmt.Errorf("invalid private key length %d", len(key))
+}
+
+func New(leaves []Leaf, key ed25519.PrivateKey) *Snapshot {
+	s := &Snapshot{Leaves: leaves}
+	s.Created = time.Now()
+	s.TreeSize = len(leaves)
+	s.Root = hex.EncodeToString(merkleRoot(s.leafHashes()))
+	s.sign(key)
+	return s
+}
+
+func (s *Snapshot) leafHashes() [][]byte {
+	hashes := make([][]byte, len(s.Leaves))
+	for i, leaf := range s.Leaves {
+		hashes[i] = hashLeaf(leaf)
+	}
+	return hashes
+}
+
+// Verify checks the signature and that the leaves add up to the root.
+func (s *Snapshot) Verify(publicKey string) error {
+	err := s.SignedRoot.Verify(publicKey)
+	if err != nil {
+		return err
+	}
+	if s.TreeSize != len(s.Leaves) || hex.EncodeToString(merkleRoot(s.leafHashes())) != s.Root {
+		return InvalidProof
+	}
+	return nil
+}
+
+func (s *Snapshot) Proof(kind, name string) (*Proof, error) {
+	for i, leaf := range s.Leaves {
+		if leaf.Kind != kind || leaf.Name != name {
+			continue
+		}
+		path := auditPath(i, s.leafHashes())
+		proof := &Proof{
+			SignedRoot: s.SignedRoot,
+			Leaf:       leaf,
+			Index:      i,
+			Path:       make([]string, len(path)),
+		}
+		for j, h := range path {
+			proof.Path[j] = hex.EncodeToString(h)
+		}
+		return proof, nil
+	}
+	return nil, LeafNotFound
+}
+
+// Verify checks the signature of the root, that the leaf is included in it
+// and, when content is not nil, that the content matches the leaf.
+func (p *Proof) Verify(publicKey string, content []byte) error {
+	err := p.SignedRoot.Verify(publicKey)
+	if err != nil {
+		return err
+	}
+	if p.Index < 0 || p.Index >= p.TreeSize {
+		return InvalidProof
+	}
+	if content != nil {
+		h := sha256.Sum256(content)
+		if hex.EncodeToString(h[:]) != p.Leaf.SHA256 {
+			return fmt.Errorf("content hash %x does not match the snapshot (%s)", h, p.Leaf.SHA256)
+		}
+	}
+	path := make([][]byte, len(p.Path))
+	for i, s := range p.Path {
+		path[i], err = hex.DecodeString(s)
+		if err != nil {
+			return InvalidProof
+		}
+	}
+	root, err := rootFromPath(p.Index, p.TreeSize, hashLeaf(p.Leaf), path)
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(root) != p.Root {
+		return InvalidProof
+	}
+	return nil
+}
+
+func Read(p string, v interface{}) error {
+	fp, err := os.Open(p)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Error("failed to open snapshot")
+		return err
+	}
+	defer fp.Close()
+	err = json.NewDecoder(fp).Decode(v)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Error("failed to decode snapshot")
+	}
+	return err
+}
+
+func Write(p string, v interface{}) error {
+	fp, err := os.Create(p)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Error("failed to create snapshot")
+		return err
+	}
+	defer fp.Close()
+	encoder := json.NewEncoder(fp)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
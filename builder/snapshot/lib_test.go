@@ -0,0 +1,192 @@
+package snapshot
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// rfc6962Leaves are the leaf inputs of the test vectors of the RFC 6962
+// reference implementation.
+var rfc6962Leaves = []string{
+	"",
+	"00",
+	"10",
+	"2021",
+	"3031",
+	"40414243",
+	"5051525354555657",
+	"606162636465666768696a6b6c6d6e6f",
+}
+
+// rfc6962Roots are the roots of the trees of the first n leaves.
+var rfc6962Roots = []string{
+	"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+func rfc6962Hashes(t *testing.T) [][]byte {
+	t.Helper()
+	hashes := make([][]byte, len(rfc6962Leaves))
+	for i, leaf := range rfc6962Leaves {
+		data, err := hex.DecodeString(leaf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := sha256.Sum256(append([]byte{0}, data...))
+		hashes[i] = h[:]
+	}
+	return hashes
+}
+
+func TestMerkleRoot(t *testing.T) {
+	hashes := rfc6962Hashes(t)
+	for n, want := range rfc6962Roots {
+		t.Run(fmt.Sprintf("%d leaves", n), func(t *testing.T) {
+			got := hex.EncodeToString(merkleRoot(hashes[:n]))
+			if got != want {
+				t.Errorf("merkleRoot() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestAuditPath(t *testing.T) {
+	hashes := rfc6962Hashes(t)
+	tests := []struct {
+		index int
+		size  int
+		want  []string
+	}{
+		{0, 1, []string{}},
+		{0, 8, []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{5, 8, []string{
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{2, 3, []string{
+			"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		}},
+		{1, 5, []string{
+			"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("leaf %d of %d", test.index, test.size), func(t *testing.T) {
+			path := auditPath(test.index, hashes[:test.size])
+			got := make([]string, len(path))
+			for i, h := range path {
+				got[i] = hex.EncodeToString(h)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("auditPath() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestRootFromPath checks every leaf of every tree size against the root.
+func TestRootFromPath(t *testing.T) {
+	hashes := rfc6962Hashes(t)
+	for size := 1; size <= len(hashes); size++ {
+		for m := 0; m < size; m++ {
+			path := auditPath(m, hashes[:size])
+			root, err := rootFromPath(m, size, hashes[m], path)
+			if err != nil || hex.EncodeToString(root) != rfc6962Roots[size] {
+				t.Errorf("rootFromPath(%d, %d) = %x, %v, want %s", m, size, root, err, rfc6962Roots[size])
+			}
+			if size > 1 {
+				_, err = rootFromPath(m, size, hashes[m], path[1:])
+				if !errors.Is(err, InvalidProof) {
+					t.Errorf("rootFromPath(%d, %d) with a short path error = %v", m, size, err)
+				}
+			}
+		}
+	}
+}
+
+func TestSnapshotProof(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	other := ed25519.NewKeyFromSeed(append(make([]byte, ed25519.SeedSize-1), 1))
+	content := []byte("contenido del documento")
+	sum := sha256.Sum256(content)
+	leaves := []Leaf{
+		{Kind: JSONLeaf, Name: "expediente.json", SHA256: "00"},
+		{Kind: DocumentLeaf, Name: "https://example.com/a.pdf", SHA256: hex.EncodeToString(sum[:])},
+		{Kind: DocumentLeaf, Name: "https://example.com/b.pdf", SHA256: "01"},
+	}
+	s := New(leaves, key)
+	publicKey := s.PublicKey
+	err := s.Verify(publicKey)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if err := s.Verify(""); !errors.Is(err, NoPublicKey) {
+		t.Errorf("Verify() without public key error = %v, want %v", err, NoPublicKey)
+	}
+	// a snapshot re-signed with another key only passes with that key
+	forged := New(leaves, other)
+	if err := forged.Verify(publicKey); err == nil {
+		t.Errorf("Verify() of a snapshot signed with another key succeeded")
+	}
+	_, err = s.Proof(DocumentLeaf, "https://example.com/c.pdf")
+	if !errors.Is(err, LeafNotFound) {
+		t.Errorf("Proof() error = %v, want %v", err, LeafNotFound)
+	}
+	proof, err := s.Proof(DocumentLeaf, "https://example.com/a.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		change  func(p *Proof)
+		content []byte
+		key     string
+		fails   bool
+	}{
+		{"valid", func(p *Proof) {}, content, publicKey, false},
+		{"without content", func(p *Proof) {}, nil, publicKey, false},
+		{"without public key", func(p *Proof) {}, content, "", true},
+		{"other content", func(p *Proof) {}, []byte("otro"), publicKey, true},
+		{"other key", func(p *Proof) {}, content, hex.EncodeToString(other.Public().(ed25519.PublicKey)), true},
+		{"other index", func(p *Proof) { p.Index = 2 }, content, publicKey, true},
+		{"index out of range", func(p *Proof) { p.Index = 3 }, content, publicKey, true},
+		{"other leaf", func(p *Proof) { p.Leaf.Name = "https://example.com/c.pdf" }, nil, publicKey, true},
+		{"short path", func(p *Proof) { p.Path = p.Path[1:] }, content, publicKey, true},
+		{"other root", func(p *Proof) { p.Root = rfc6962Roots[0] }, content, publicKey, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := *proof
+			p.Path = append([]string{}, proof.Path...)
+			test.change(&p)
+			err := p.Verify(test.key, test.content)
+			if (err != nil) != test.fails {
+				t.Errorf("Verify() error = %v, want failure %v", err, test.fails)
+			}
+		})
+	}
+
+	s.Leaves[2].SHA256 = "02"
+	if err := s.Verify(publicKey); !errors.Is(err, InvalidProof) {
+		t.Errorf("Verify() of a changed leaf error = %v, want %v", err, InvalidProof)
+	}
+}
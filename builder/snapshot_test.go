@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	shared "github.com/odia/juscaba/shared"
+	snapshot "github.com/odia/juscaba/snapshot"
+)
+
+// TestReadSnapshotKeyMissing checks that a missing secret is an error, so
+// the build stops before crawling instead of exiting at the end.
+func TestReadSnapshotKeyMissing(t *testing.T) {
+	_, err := readSnapshotKey("juscaba-test-missing-secret")
+	if err == nil {
+		t.Errorf("readSnapshotKey() of a missing secret succeeded")
+	}
+}
+
+func TestWriteSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "expediente.json")
+	err := os.WriteFile(jsonPath, []byte("{}"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	args := &arguments{
+		jsonPath:     jsonPath,
+		fm:           &shared.FileManager{Directory: dir},
+		exp:          &shared.Expediente{Ficha: &shared.Ficha{}},
+		snapshotPath: filepath.Join(dir, "snapshot.json"),
+		snapshotKey:  key,
+	}
+	defer args.fm.Close()
+	err = writeSnapshot(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &snapshot.Snapshot{}
+	err = snapshot.Read(args.snapshotPath, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := hex.EncodeToString(key.Public().(ed25519.PublicKey))
+	if err := s.Verify(publicKey); err != nil || s.TreeSize != 1 {
+		t.Errorf("snapshot = %+v, Verify() error = %v", s, err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	fetcher "github.com/odia/juscaba/fetcher"
+	shared "github.com/odia/juscaba/shared"
+	log "github.com/sirupsen/logrus"
+)
+
+var verifyFailed = errors.New("store verification found problems")
+
+func repairProblem(fm *shared.FileManager, problem *shared.VerifyProblem) {
+	switch problem.Problem {
+	case shared.MissingFileProblem, shared.HashMismatchProblem, shared.InvalidDocumentProblem, shared.InvalidMetadataProblem:
+	default:
+		return
+	}
+	if problem.SourceURL == "" {
+		return
+	}
+	if sf, err := fm.SavedFileForURL(problem.SourceURL); err == nil && sf.Parent != "" {
+		// attachments are saved again when their parent is extracted
+		return
+	}
+	err := fetcher.Refetch(fm, problem.SourceURL)
+	if err != nil {
+		return
+	}
+	sf, err := fm.SavedFileForURL(problem.SourceURL)
+	if err != nil {
+		return
+	}
+	if after := fm.VerifySavedFile(sf); after != nil {
+		log.WithFields(log.Fields{
+			"url":     problem.SourceURL,
+			"problem": after.Problem,
+			"detail":  after.Detail,
+		}).Warn("re-downloaded file is still invalid")
+		return
+	}
+	problem.Repaired = true
+}
+
+func verify(argv []string) error {
+	var pdfsPath, reportPath string
+	var repair bool
+	flags := newFlagSet("verify")
+	flags.StringVar(&pdfsPath, "pdfs", "", "pdfs path")
+	flags.StringVar(&reportPath, "report", "", "json report destination path (default stdout)")
+	flags.BoolVar(&repair, "repair", false, "re-download files that are missing or corrupted")
+	flags.Parse(argv)
+
+	fm := &shared.FileManager{Directory: pdfsPath}
+	defer fm.Close()
+	report, err := fm.Verify()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"pdfs":  pdfsPath,
+			"error": err.Error(),
+		}).Error("failed to verify store")
+		return err
+	}
+	if repair {
+		for _, problem := range report.Problems {
+			repairProblem(fm, problem)
+		}
+	}
+
+	out := os.Stdout
+	if reportPath != "" {
+		out, err = os.Create(reportPath)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"report": reportPath,
+				"error":  err.Error(),
+			}).Error("failed to create report")
+			return err
+		}
+		defer out.Close()
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(report)
+	if err != nil {
+		return err
+	}
+
+	unrepaired := 0
+	for _, problem := range report.Problems {
+		if !problem.Repaired {
+			unrepaired++
+		}
+	}
+	log.WithFields(log.Fields{
+		"checked":    report.Checked,
+		"problems":   len(report.Problems),
+		"unrepaired": unrepaired,
+	}).Info("verified store")
+	if unrepaired > 0 {
+		return verifyFailed
+	}
+	return nil
+}
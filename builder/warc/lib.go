@@ -0,0 +1,313 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const cdxHeader = " CDX N b a m s k r M S V g"
+
+type header struct {
+	name  string
+	value string
+}
+
+type cdxLine struct {
+	key  string
+	line string
+}
+
+// Writer writes gzipped WARC records, one gzip member per record, and keeps
+// a CDX line for each response so the archive can be replayed.
+type Writer struct {
+	mu       sync.Mutex
+	path     string
+	cdxPath  string
+	fp       *os.File
+	offset   int64
+	infoID   string
+	cdxLines []cdxLine
+}
+
+func recordID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func digest(b []byte) string {
+	h := sha1.Sum(b)
+	return "sha1:" + base32.StdEncoding.EncodeToString(h[:])
+}
+
+// NewWriter creates the WARC file at p and the CDX index next to it.
+func NewWriter(p, software string) (*Writer, error) {
+	fp, err := os.Create(p)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  p,
+			"error": err.Error(),
+		}).Error("failed to create warc file")
+		return nil, err
+	}
+	w := &Writer{
+		path:    p,
+		cdxPath: strings.TrimSuffix(strings.TrimSuffix(p, ".gz"), ".warc") + ".cdx",
+		fp:      fp,
+	}
+	w.infoID = recordID()
+	info := fmt.Sprintf("software: %s\r\nformat: WARC File Format 1.1\r\nconformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n", software)
+	_, err = w.writeRecord([]header{
+		{"WARC-Type", "warcinfo"},
+		{"WARC-Record-ID", w.infoID},
+		{"WARC-Date", time.Now().UTC().Format(time.RFC3339)},
+		{"WARC-Filename", path.Base(p)},
+		{"Content-Type", "application/warc-fields"},
+	}, []byte(info))
+	if err != nil {
+		fp.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// writeRecord writes a record and returns its offset and compressed length.
+func (w *Writer) writeRecord(headers []header, block []byte) ([2]int64, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	fmt.Fprintf(gz, "WARC/1.1\r\n")
+	for _, h := range headers {
+		fmt.Fprintf(gz, "%s: %s\r\n", h.name, h.value)
+	}
+	fmt.Fprintf(gz, "Content-Length: %d\r\n\r\n", len(block))
+	gz.Write(block)
+	fmt.Fprintf(gz, "\r\n\r\n")
+	err := gz.Close()
+	if err != nil {
+		return [2]int64{}, err
+	}
+	offset := w.offset
+	n, err := w.fp.Write(buf.Bytes())
+	w.offset += int64(n)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  w.path,
+			"error": err.Error(),
+		}).Error("failed to write warc record")
+	}
+	return [2]int64{offset, int64(n)}, err
+}
+
+func requestBlock(req *http.Request) ([]byte, error) {
+	return httputil.DumpRequestOut(req, true)
+}
+
+// responseBlock serializes the response as it was received. The transport
+// may have decoded the body already, so the length headers are rewritten to
+// match the recorded payload.
+func responseBlock(res *http.Response, body []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/%d.%d %s\r\n", res.ProtoMajor, res.ProtoMinor, res.Status)
+	h := res.Header.Clone()
+	h.Del("Content-Encoding")
+	h.Del("Transfer-Encoding")
+	h.Set("Content-Length", fmt.Sprint(len(body)))
+	h.Write(&buf)
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// surt returns the canonical url key used to sort the CDX index.
+func surt(u *url.URL) string {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	labels := strings.Split(host, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	key := strings.Join(labels, ",")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		key += ":" + port
+	}
+	key += ")" + strings.ToLower(u.EscapedPath())
+	if u.RawQuery != "" {
+		params := strings.Split(strings.ToLower(u.RawQuery), "&")
+		sort.Strings(params)
+		key += "?" + strings.Join(params, "&")
+	}
+	return key
+}
+
+// Record writes a request and its response as a pair of concurrent records.
+func (w *Writer) Record(req *http.Request, res *http.Response, body []byte, date time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	reqBlock, err := requestBlock(req)
+	if err != nil {
+		return err
+	}
+	target := req.URL.String()
+	warcDate := date.UTC().Format(time.RFC3339)
+	responseID := recordID()
+	resBlock := responseBlock(res, body)
+	payloadDigest := digest(body)
+	location, err := w.writeRecord([]header{
+		{"WARC-Type", "response"},
+		{"WARC-Record-ID", responseID},
+		{"WARC-Warcinfo-ID", w.infoID},
+		{"WARC-Date", warcDate},
+		{"WARC-Target-URI", target},
+		{"WARC-Block-Digest", digest(resBlock)},
+		{"WARC-Payload-Digest", payloadDigest},
+		{"Content-Type", "application/http;msgtype=response"},
+	}, resBlock)
+	if err != nil {
+		return err
+	}
+	_, err = w.writeRecord([]header{
+		{"WARC-Type", "request"},
+		{"WARC-Record-ID", recordID()},
+		{"WARC-Warcinfo-ID", w.infoID},
+		{"WARC-Concurrent-To", responseID},
+		{"WARC-Date", warcDate},
+		{"WARC-Target-URI", target},
+		{"WARC-Block-Digest", digest(reqBlock)},
+		{"Content-Type", "application/http;msgtype=request"},
+	}, reqBlock)
+	if err != nil {
+		return err
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mimeType == "" {
+		mimeType = "unk"
+	}
+	redirect := res.Header.Get("Location")
+	if redirect == "" {
+		redirect = "-"
+	}
+	key := surt(req.URL)
+	timestamp := date.UTC().Format("20060102150405")
+	w.cdxLines = append(w.cdxLines, cdxLine{
+		key: key + " " + timestamp,
+		line: strings.Join([]string{
+			key,
+			timestamp,
+			target,
+			mimeType,
+			fmt.Sprint(res.StatusCode),
+			strings.TrimPrefix(payloadDigest, "sha1:"),
+			redirect,
+			"-",
+			fmt.Sprint(location[1]),
+			fmt.Sprint(location[0]),
+			path.Base(w.path),
+		}, " "),
+	})
+	return nil
+}
+
+// Close closes the WARC file and writes the sorted CDX index.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.fp.Close()
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(w.cdxLines, func(i, j int) bool {
+		return w.cdxLines[i].key < w.cdxLines[j].key
+	})
+	fp, err := os.Create(w.cdxPath)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"path":  w.cdxPath,
+			"error": err.Error(),
+		}).Error("failed to create cdx file")
+		return err
+	}
+	defer fp.Close()
+	fmt.Fprintln(fp, cdxHeader)
+	for _, l := range w.cdxLines {
+		fmt.Fprintln(fp, l.line)
+	}
+	log.WithFields(log.Fields{
+		"path":    w.path,
+		"records": len(w.cdxLines),
+	}).Info("wrote warc")
+	return nil
+}
+
+// Transport records every request made through it and its response.
+type Transport struct {
+	Writer    *Writer
+	Transport http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+	date := time.Now()
+	res, err := t.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	recorded := req.Clone(req.Context())
+	if reqBody != nil {
+		recorded.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+	err = t.Writer.Record(recorded, res, body, date)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"url":   req.URL.String(),
+			"error": err.Error(),
+		}).Warn("failed to record request in warc")
+	}
+	return res, nil
+}
+
+// Install records every request made with http.DefaultClient.
+func Install(w *Writer) {
+	transport := http.DefaultClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	http.DefaultClient.Transport = &Transport{
+		Writer:    w,
+		Transport: transport,
+	}
+}
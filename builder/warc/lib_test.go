@@ -0,0 +1,260 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// record is a decoded WARC record and where its gzip member starts and ends.
+type record struct {
+	header textproto.MIMEHeader
+	block  []byte
+	offset int64
+	length int64
+}
+
+// readRecord decodes the record of a single gzip member.
+func readRecord(t *testing.T, member []byte) record {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(member))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz.Multistream(false)
+	content, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(bytes.NewReader(content))
+	version, err := r.ReadString('\n')
+	if err != nil || version != "WARC/1.1\r\n" {
+		t.Fatalf("record version = %q, %v", version, err)
+	}
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, _ := io.ReadAll(r)
+	if len(rest) != length+4 || string(rest[length:]) != "\r\n\r\n" {
+		t.Fatalf("record of %d bytes with Content-Length %d", len(rest)-4, length)
+	}
+	return record{header: header, block: rest[:length]}
+}
+
+// readRecords splits the WARC file in its gzip members, checking that each
+// holds exactly one record.
+func readRecords(t *testing.T, content []byte) []record {
+	t.Helper()
+	records := make([]record, 0)
+	r := bytes.NewReader(content)
+	for r.Len() > 0 {
+		offset := int64(len(content) - r.Len())
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz.Multistream(false)
+		_, err = io.Copy(io.Discard, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		end := int64(len(content) - r.Len())
+		rec := readRecord(t, content[offset:end])
+		rec.offset = offset
+		rec.length = end - offset
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/b.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write([]byte("%PDF-1.4 documento"))
+		case "/a":
+			http.Redirect(w, r, "/b.pdf", http.StatusFound)
+		case "/gzip":
+			// the transport decodes the body, the record keeps it decoded
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			gz.Write([]byte("texto comprimido"))
+			gz.Close()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := path.Join(t.TempDir(), "expediente.warc.gz")
+	w, err := NewWriter(p, "juscaba test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{
+		Transport: &Transport{Writer: w, Transport: &http.Transport{}},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	bodies := map[string]string{}
+	for _, u := range []string{"/b.pdf", "/a", "/gzip", "/missing"} {
+		req, _ := http.NewRequest("GET", server.URL+u, nil)
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		bodies[server.URL+u] = string(body)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := readRecords(t, content)
+	// the warcinfo and a response and a request for each url
+	if len(records) != 9 {
+		t.Fatalf("got %d records, want 9", len(records))
+	}
+	if records[0].header.Get("WARC-Type") != "warcinfo" {
+		t.Errorf("first record type = %q, want warcinfo", records[0].header.Get("WARC-Type"))
+	}
+	idPattern := regexp.MustCompile(`^<urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}>$`)
+	ids := map[string]bool{}
+	responses := map[string]record{}
+	for i, rec := range records {
+		id := rec.header.Get("WARC-Record-ID")
+		if !idPattern.MatchString(id) || ids[id] {
+			t.Errorf("record %d id = %q, want a new uuid urn", i, id)
+		}
+		ids[id] = true
+		if i == 0 {
+			continue
+		}
+		if got := rec.header.Get("WARC-Block-Digest"); got != digest(rec.block) {
+			t.Errorf("record %d block digest = %s, want %s", i, got, digest(rec.block))
+		}
+		if rec.header.Get("WARC-Warcinfo-ID") != records[0].header.Get("WARC-Record-ID") {
+			t.Errorf("record %d does not reference the warcinfo record", i)
+		}
+		switch rec.header.Get("WARC-Type") {
+		case "response":
+			responses[rec.header.Get("WARC-Target-URI")] = rec
+		case "request":
+			// requests follow the response they belong to
+			if rec.header.Get("WARC-Concurrent-To") != records[i-1].header.Get("WARC-Record-ID") {
+				t.Errorf("record %d is not concurrent to the previous response", i)
+			}
+		default:
+			t.Errorf("record %d type = %q", i, rec.header.Get("WARC-Type"))
+		}
+	}
+
+	for target, body := range bodies {
+		rec, found := responses[target]
+		if !found {
+			t.Errorf("no response record for %s", target)
+			continue
+		}
+		res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(rec.block)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload, _ := io.ReadAll(res.Body)
+		if string(payload) != body {
+			t.Errorf("%s payload = %q, want %q", target, payload, body)
+		}
+		if got := rec.header.Get("WARC-Payload-Digest"); got != digest([]byte(body)) {
+			t.Errorf("%s payload digest = %s, want %s", target, got, digest([]byte(body)))
+		}
+		if res.Header.Get("Content-Encoding") != "" || res.ContentLength != int64(len(body)) {
+			t.Errorf("%s headers = %v, want the length of the decoded body", target, res.Header)
+		}
+	}
+
+	cdx, err := os.ReadFile(strings.TrimSuffix(p, ".warc.gz") + ".cdx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(cdx), "\n"), "\n")
+	if lines[0] != cdxHeader {
+		t.Errorf("cdx header = %q, want %q", lines[0], cdxHeader)
+	}
+	lines = lines[1:]
+	if len(lines) != len(bodies) {
+		t.Fatalf("cdx has %d lines, want %d", len(lines), len(bodies))
+	}
+	if !sort.StringsAreSorted(lines) {
+		t.Errorf("cdx lines are not sorted: %q", lines)
+	}
+	wantFields := map[string][]string{
+		server.URL + "/b.pdf":   {"application/pdf", "200", "-"},
+		server.URL + "/a":       {"text/html", "302", "/b.pdf"},
+		server.URL + "/gzip":    {"text/plain", "200", "-"},
+		server.URL + "/missing": {"text/plain", "404", "-"},
+	}
+	for _, line := range lines {
+		// N b a m s k r M S V g
+		fields := strings.Split(line, " ")
+		if len(fields) != 11 {
+			t.Errorf("cdx line %q has %d fields, want 11", line, len(fields))
+			continue
+		}
+		target := fields[2]
+		rec := responses[target]
+		u, err := url.Parse(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := surt(u); fields[0] != want {
+			t.Errorf("%s key = %q, want %q", target, fields[0], want)
+		}
+		if len(fields[1]) != 14 {
+			t.Errorf("%s timestamp = %q", target, fields[1])
+		}
+		got := []string{fields[3], fields[4], fields[6]}
+		if fmt.Sprint(got) != fmt.Sprint(wantFields[target]) {
+			t.Errorf("%s mime, status and redirect = %v, want %v", target, got, wantFields[target])
+		}
+		if "sha1:"+fields[5] != rec.header.Get("WARC-Payload-Digest") || fields[7] != "-" || fields[10] != "expediente.warc.gz" {
+			t.Errorf("%s digest, meta tags and file = %v", target, []string{fields[5], fields[7], fields[10]})
+		}
+		length, _ := strconv.ParseInt(fields[8], 10, 64)
+		offset, _ := strconv.ParseInt(fields[9], 10, 64)
+		if offset != rec.offset || length != rec.length {
+			t.Errorf("%s offset and length = %d %d, want %d %d", target, offset, length, rec.offset, rec.length)
+			continue
+		}
+		// the member at the offset is the response by itself
+		member := readRecord(t, content[offset:offset+length])
+		if !bytes.Equal(member.block, rec.block) || member.header.Get("WARC-Target-URI") != target {
+			t.Errorf("%s member at %d is not its response record", target, offset)
+		}
+	}
+}
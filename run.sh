@@ -3,12 +3,15 @@ set -Eeux
 
 mkdir -p /tmp/juscaba/pdfs
 mkdir -p public/data
+if [ -n "${WARC:-}" ]; then
+    mkdir -p /tmp/juscaba/warc
+fi
 for ((i=1; i<=$#; i++))
 do
     exp=${!i}
     exp_filename=${!i/\//-}
 
-    ./builder "-json=public/data/${exp_filename}.json" -pdfs=/tmp/juscaba/pdfs "-expediente=${exp}" -images=${READ_IMAGES:-true} "-blacklist=${BLACKLIST_REGEX:-}" "-mirror-base-url=${MIRROR_BASE_URL:-}"
+    ./builder "-json=public/data/${exp_filename}.json" -pdfs=/tmp/juscaba/pdfs "-expediente=${exp}" -images=${READ_IMAGES:-true} -ocr-min-chars=${OCR_MIN_CHARS:-20} "-blacklist=${BLACKLIST_REGEX:-}" "-mirror-base-url=${MIRROR_BASE_URL:-}" ${SIGN_SNAPSHOT:+"-snapshot=public/data/${exp_filename}-snapshot.json"} ${WARC:+"-warc=/tmp/juscaba/warc/${exp_filename}.warc.gz"}
 
     pushd ts
     yarn run ts-node create-index.ts ../public/data/${exp_filename}.json ../public/data/${exp_filename}-index.json
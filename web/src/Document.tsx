@@ -4,16 +4,32 @@ import Button from './Button';
 import { SearchData } from './Search';
 import Highlighter from './Highlighter';
 
+export interface PageData {
+  number: number;
+  text: string;
+  source: string;
+}
+
 export interface DocumentData {
   MirrorURL: string;
   URL: string;
   content: string;
+  pages?: PageData[];
 }
 
 export interface DocumentProps {
   data: DocumentData
   search: SearchData
 }
+function downloadURL(data: DocumentData, term?: string): string {
+  if (!term || !data.pages) {
+    return data.MirrorURL;
+  }
+  const re = new RegExp(term, 'i');
+  const page = data.pages.find((p) => p.text.search(re) !== -1);
+  return page ? `${data.MirrorURL}#page=${page.number}` : data.MirrorURL;
+}
+
 function Document({ data, search }: React.PropsWithChildren<DocumentProps>) {
   const [expanded, setExpanded] = useState(false);
 
@@ -23,7 +39,7 @@ function Document({ data, search }: React.PropsWithChildren<DocumentProps>) {
     </div>
     <div>
       <Button onClick={() => setExpanded(!expanded)} selected={expanded}>expandir</Button>
-      <Button href={data.MirrorURL}>descargar</Button>
+      <Button href={downloadURL(data, search.term)}>descargar</Button>
     </div>
   </Box >)
 }